@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+
+	apiresponse "github.com/Kanishk-K/UniteDownloader/Backend/pkg/apiResponse"
+	dynamo "github.com/Kanishk-K/UniteDownloader/Backend/pkg/dynamoClient"
+	s3client "github.com/Kanishk-K/UniteDownloader/Backend/pkg/s3Client"
+	"github.com/Kanishk-K/UniteDownloader/Backend/pkg/videoutil"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/config"
+)
+
+const BUCKET = "lecture-processor"
+
+type BackgroundsService struct {
+	s3Client     s3client.S3Methods
+	dynamoClient dynamo.DynamoMethods
+	isProd       bool
+}
+
+type BackgroundInfo struct {
+	ID          string  `json:"id"`
+	DisplayName string  `json:"displayName"`
+	Duration    float64 `json:"duration"`
+	AspectRatio string  `json:"aspectRatio"`
+	// Status and RejectReason are only set for the user's own uploads
+	Status       string `json:"status,omitempty"`
+	RejectReason string `json:"rejectReason,omitempty"`
+}
+
+type BackgroundsResponse struct {
+	Backgrounds []BackgroundInfo `json:"backgrounds"`
+	Private     []BackgroundInfo `json:"private"`
+}
+
+func NewBackgroundsService(s3Client s3client.S3Methods, dynamoClient dynamo.DynamoMethods) *BackgroundsService {
+	return &BackgroundsService{s3Client: s3Client, dynamoClient: dynamoClient}
+}
+
+func (bs BackgroundsService) handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	resp := events.APIGatewayProxyResponse{
+		Headers: map[string]string{
+			"Content-Type":                 "application/json",
+			"Access-Control-Allow-Origin":  "*",
+			"Access-Control-Allow-Headers": "Content-Type,Authorization",
+		},
+		IsBase64Encoded: false,
+	}
+	// The catalog is read on every request so newly added backgrounds show up immediately
+	catalog, err := videoutil.LoadCatalog(ctx, bs.s3Client, BUCKET)
+	if err != nil {
+		log.Printf("Error loading background catalog: %v", err)
+		apiresponse.APIErrorResponse(500, "Internal Server Error", &resp)
+		return resp, nil
+	}
+	backgroundsResponse := BackgroundsResponse{Backgrounds: []BackgroundInfo{}, Private: []BackgroundInfo{}}
+	for _, background := range catalog.Enabled() {
+		backgroundsResponse.Backgrounds = append(backgroundsResponse.Backgrounds, BackgroundInfo{
+			ID:          background.ID,
+			DisplayName: background.DisplayName,
+			Duration:    background.Duration,
+			AspectRatio: background.AspectRatio,
+		})
+	}
+
+	// Include the caller's own uploads, pending and rejected ones are listed so the extension can show their progress
+	var subject string
+	if bs.isProd {
+		subject = request.RequestContext.Authorizer["claims"].(map[string]any)["cognito:username"].(string)
+	} else {
+		subject = "DEV USER"
+	}
+	userBackgrounds, err := bs.dynamoClient.ListUserBackgrounds(ctx, subject)
+	if err != nil {
+		apiresponse.APIErrorResponse(500, "Internal Server Error", &resp)
+		return resp, nil
+	}
+	for _, background := range userBackgrounds {
+		backgroundsResponse.Private = append(backgroundsResponse.Private, BackgroundInfo{
+			ID:           background.BackgroundID,
+			DisplayName:  background.DisplayName,
+			Duration:     background.Duration,
+			AspectRatio:  background.AspectRatio,
+			Status:       background.Status,
+			RejectReason: background.RejectReason,
+		})
+	}
+	apiresponse.APISuccessResponse(backgroundsResponse, &resp)
+	return resp, nil
+}
+
+func main() {
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	awsSession, err := config.LoadDefaultConfig(
+		context.Background(),
+		config.WithRegion(region),
+	)
+	if err != nil {
+		fmt.Println("Failed to load AWS configuration:", err)
+		return
+	}
+	s3Client := s3client.NewS3Client(awsSession)
+	dynamoClient := dynamo.NewDynamoClient(awsSession)
+	bs := NewBackgroundsService(s3Client, dynamoClient)
+	bs.isProd = os.Getenv("AWS_SAM_LOCAL") != "true"
+	lambda.Start(bs.handler)
+}
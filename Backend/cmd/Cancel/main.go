@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+
+	apiresponse "github.com/Kanishk-K/UniteDownloader/Backend/pkg/apiResponse"
+	dynamo "github.com/Kanishk-K/UniteDownloader/Backend/pkg/dynamoClient"
+	"github.com/Kanishk-K/UniteDownloader/Backend/pkg/tasks"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/hibiken/asynq"
+)
+
+const (
+	TaskNone      = "NONE"
+	TaskDeleted   = "DELETED"
+	TaskCancelled = "CANCELLED"
+)
+
+type CancelService struct {
+	dynamoClient dynamo.DynamoMethods
+	inspector    *asynq.Inspector
+	isProd       bool
+}
+
+/*
+This path is served at DELETE /jobs/{entryID}/videos/{video}. Deleting the request row does not run the TTL
+cleanup, the TTL lambda only handles rows removed by DynamoDB itself.
+*/
+
+// stopTask removes the video's task from whichever queue holds it. A task that is running is cancelled instead,
+// asynq sends it back to be retried and the consumer drops it once it sees the request is gone.
+func (cs CancelService) stopTask(taskID string) (string, error) {
+	for _, queue := range tasks.QueuesByPriority {
+		info, err := cs.inspector.GetTaskInfo(queue, taskID)
+		if errors.Is(err, asynq.ErrTaskNotFound) || errors.Is(err, asynq.ErrQueueNotFound) {
+			continue
+		}
+		if err != nil {
+			return TaskNone, err
+		}
+		if info.State == asynq.TaskStateActive {
+			log.Printf("Cancelling active task %s\n", taskID)
+			return TaskCancelled, cs.inspector.CancelProcessing(taskID)
+		}
+		// Archived and completed tasks are deleted too so the video can be requested again
+		log.Printf("Deleting %s task %s\n", info.State, taskID)
+		return TaskDeleted, cs.inspector.DeleteTask(queue, taskID)
+	}
+	return TaskNone, nil
+}
+
+func (cs CancelService) handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	resp := events.APIGatewayProxyResponse{
+		Headers: map[string]string{
+			"Content-Type":                 "application/json",
+			"Access-Control-Allow-Origin":  "*",
+			"Access-Control-Allow-Headers": "Content-Type,Authorization",
+		},
+		IsBase64Encoded: false,
+	}
+	entryID := request.PathParameters["entryID"]
+	videoID := request.PathParameters["video"]
+	if entryID == "" || videoID == "" {
+		apiresponse.APIErrorResponse(400, "No EntryID or video provided", &resp)
+		return resp, nil
+	}
+	var subject string
+	if cs.isProd {
+		subject = request.RequestContext.Authorizer["claims"].(map[string]any)["cognito:username"].(string)
+	} else {
+		subject = "DEV USER"
+	}
+	log.Print("Subject: ", subject)
+
+	// Step 1: Ensure the request exists and belongs to the user
+	videoRequest, err := cs.dynamoClient.GetVideoRequest(ctx, entryID, videoID)
+	if err != nil {
+		apiresponse.APIErrorResponse(500, "Error getting video request", &resp)
+		return resp, err
+	}
+	if videoRequest == nil {
+		apiresponse.APIErrorResponse(404, "Video request not found", &resp)
+		return resp, nil
+	}
+	if videoRequest.RequestedBy != subject {
+		apiresponse.APIErrorResponse(403, "User did not request this video", &resp)
+		return resp, nil
+	}
+
+	// Step 2: Ensure the video has not been made already, finished videos are removed by their TTL
+	job, err := cs.dynamoClient.GetJob(ctx, entryID)
+	if err != nil {
+		apiresponse.APIErrorResponse(500, "Error getting job info", &resp)
+		return resp, err
+	}
+	if job != nil {
+		if _, ok := job.VideoParts[videoID]; ok || slices.Contains(job.VideosAvailable, videoID) {
+			apiresponse.APIErrorResponse(409, "Video has already been generated", &resp)
+			return resp, nil
+		}
+	}
+
+	// Step 3: Remove the request, from here on the consumer drops the task even if stopping it below fails
+	err = cs.dynamoClient.DeleteVideoRequest(ctx, entryID, videoID, subject)
+	if err != nil {
+		var ccfe *types.ConditionalCheckFailedException
+		if errors.As(err, &ccfe) {
+			apiresponse.APIErrorResponse(404, "Video request not found", &resp)
+			return resp, nil
+		}
+		apiresponse.APIErrorResponse(500, "Failed to cancel video request", &resp)
+		return resp, err
+	}
+
+	// Step 4: Stop the task
+	taskID := tasks.VideoGenerationTaskID(entryID, videoID)
+	taskStatus, err := cs.stopTask(taskID)
+	if err != nil {
+		log.Printf("Could not stop task %s, the consumer will drop it: %v", taskID, err)
+	}
+	// Close the stage unless the consumer is working on it and fails it itself. A stage stays running while its
+	// task waits to be retried, so it is closed too once that task is gone.
+	from := []string{dynamo.StageStatusPending, dynamo.StageStatusQueued}
+	if err == nil && taskStatus != TaskCancelled {
+		from = append(from, dynamo.StageStatusRunning)
+	}
+	err = cs.dynamoClient.UpdatePipelineStage(ctx, entryID, tasks.VideoStageName(videoID), dynamo.StageStatusFailed, "cancelled by the user", from)
+	if err != nil {
+		var ccfe *types.ConditionalCheckFailedException
+		if !errors.As(err, &ccfe) {
+			log.Printf("Could not close the video stage of %s: %v", videoID, err)
+		}
+	}
+
+	// Step 5: Give back the video counted against the user's quota
+	err = cs.dynamoClient.RefundVideoRender(ctx, subject)
+	if err != nil {
+		log.Printf("Failed to refund video for %s: %v", subject, err)
+	}
+
+	log.Printf("Cancelled %s video for entryID: %s (task %s)\n", videoID, entryID, taskStatus)
+	apiresponse.APISuccessResponse(map[string]any{
+		"entryID": entryID,
+		"video":   videoID,
+		"task":    taskStatus,
+	}, &resp)
+	return resp, nil
+}
+
+func main() {
+	redisOpt := asynq.RedisClientOpt{Addr: os.Getenv("REDIS_URL")}
+	inspector := asynq.NewInspector(redisOpt)
+	defer inspector.Close()
+
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = "us-east-1"
+	}
+	awsSession, err := config.LoadDefaultConfig(
+		context.Background(),
+		config.WithRegion(region),
+	)
+	if err != nil {
+		fmt.Println("Failed to load AWS configuration:", err)
+		return
+	}
+	cs := CancelService{
+		dynamoClient: dynamo.NewDynamoClient(awsSession),
+		inspector:    inspector,
+		isProd:       os.Getenv("AWS_SAM_LOCAL") != "true",
+	}
+	lambda.Start(cs.handler)
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Kanishk-K/UniteDownloader/Backend/pkg/tasks"
+)
+
+const (
+	DEFAULT_CONCURRENCY = 1
+	DEFAULT_QUEUES      = "high:3,medium:2,low:1"
+	// ECS kills the container 120 seconds after SIGTERM, leave time to requeue what is still running
+	DEFAULT_SHUTDOWN_TIMEOUT = 100 * time.Second
+	DEFAULT_HEALTH_ADDR      = ":8080"
+	// The background catalog and clips are checked against S3 this often
+	DEFAULT_CACHE_SYNC_INTERVAL = 10 * time.Minute
+	// Jobs and video requests lost between pipeline steps are looked for on this cron spec
+	DEFAULT_RECONCILE_SCHEDULE = "@every 1h"
+	// RECONCILE_DISABLED as CONSUMER_RECONCILE_SCHEDULE stops this consumer scheduling reconciliation
+	RECONCILE_DISABLED = "off"
+)
+
+// consumerConfig is how the consumer runs, read from the environment.
+type consumerConfig struct {
+	concurrency    int
+	queues         map[string]int
+	strictPriority bool
+	// shutdownTimeout is how long in-flight tasks may run after SIGTERM before they are cancelled and requeued
+	shutdownTimeout time.Duration
+	healthAddr      string
+	// cacheSyncInterval is how often new, changed and removed backgrounds are picked up
+	cacheSyncInterval time.Duration
+	// reconcileSchedule is the cron spec reconciliation is queued on, empty when it is disabled
+	reconcileSchedule string
+}
+
+// loadConfig reads CONSUMER_CONCURRENCY, CONSUMER_QUEUES (e.g. "high:3,medium:2,low:1"), CONSUMER_STRICT_PRIORITY,
+// CONSUMER_SHUTDOWN_TIMEOUT (e.g. "100s"), CONSUMER_CACHE_SYNC_INTERVAL (e.g. "10m"), CONSUMER_RECONCILE_SCHEDULE (e.g. "@every 1h" or "off")
+// and HEALTH_ADDR, falling back to the defaults for any that are unset. The queues must include the ones the pipeline and reconciliation are queued on.
+func loadConfig() (consumerConfig, error) {
+	cfg := consumerConfig{
+		concurrency:       DEFAULT_CONCURRENCY,
+		strictPriority:    true,
+		shutdownTimeout:   DEFAULT_SHUTDOWN_TIMEOUT,
+		healthAddr:        DEFAULT_HEALTH_ADDR,
+		cacheSyncInterval: DEFAULT_CACHE_SYNC_INTERVAL,
+		reconcileSchedule: DEFAULT_RECONCILE_SCHEDULE,
+	}
+	var err error
+	if value := os.Getenv("CONSUMER_CONCURRENCY"); value != "" {
+		cfg.concurrency, err = strconv.Atoi(value)
+		if err != nil || cfg.concurrency < 1 {
+			return cfg, fmt.Errorf("CONSUMER_CONCURRENCY must be a positive integer, got %q", value)
+		}
+	}
+	queues := os.Getenv("CONSUMER_QUEUES")
+	if queues == "" {
+		queues = DEFAULT_QUEUES
+	}
+	cfg.queues, err = parseQueues(queues)
+	if err != nil {
+		return cfg, err
+	}
+	// Narration and every pipeline step are queued on the high queue, jobs would stall without it
+	if _, ok := cfg.queues[tasks.QueueHigh]; !ok {
+		return cfg, fmt.Errorf("CONSUMER_QUEUES must include the %s queue, got %q", tasks.QueueHigh, queues)
+	}
+	if value := os.Getenv("CONSUMER_STRICT_PRIORITY"); value != "" {
+		cfg.strictPriority, err = strconv.ParseBool(value)
+		if err != nil {
+			return cfg, fmt.Errorf("CONSUMER_STRICT_PRIORITY must be a boolean, got %q", value)
+		}
+	}
+	if value := os.Getenv("CONSUMER_SHUTDOWN_TIMEOUT"); value != "" {
+		cfg.shutdownTimeout, err = time.ParseDuration(value)
+		if err != nil || cfg.shutdownTimeout <= 0 {
+			return cfg, fmt.Errorf("CONSUMER_SHUTDOWN_TIMEOUT must be a positive duration, got %q", value)
+		}
+	}
+	if value := os.Getenv("CONSUMER_CACHE_SYNC_INTERVAL"); value != "" {
+		cfg.cacheSyncInterval, err = time.ParseDuration(value)
+		if err != nil || cfg.cacheSyncInterval <= 0 {
+			return cfg, fmt.Errorf("CONSUMER_CACHE_SYNC_INTERVAL must be a positive duration, got %q", value)
+		}
+	}
+	if value := os.Getenv("CONSUMER_RECONCILE_SCHEDULE"); value == RECONCILE_DISABLED {
+		cfg.reconcileSchedule = ""
+	} else if value != "" {
+		// The scheduler checks the spec when the task is registered
+		cfg.reconcileSchedule = value
+	}
+	if _, ok := cfg.queues[tasks.QueueLow]; !ok && cfg.reconcileSchedule != "" {
+		return cfg, fmt.Errorf("CONSUMER_QUEUES must include the %s queue reconciliation is queued on, or set CONSUMER_RECONCILE_SCHEDULE to %q", tasks.QueueLow, RECONCILE_DISABLED)
+	}
+	if value := os.Getenv("HEALTH_ADDR"); value != "" {
+		cfg.healthAddr = value
+	}
+	return cfg, nil
+}
+
+// parseQueues reads queue weights written as "name:weight,name:weight".
+func parseQueues(value string) (map[string]int, error) {
+	queues := make(map[string]int)
+	for _, entry := range strings.Split(value, ",") {
+		name, weight, found := strings.Cut(strings.TrimSpace(entry), ":")
+		if !found || name == "" {
+			return nil, fmt.Errorf("CONSUMER_QUEUES entries must be name:weight, got %q", entry)
+		}
+		priority, err := strconv.Atoi(weight)
+		if err != nil || priority < 1 {
+			return nil, fmt.Errorf("queue %s must have a positive weight, got %q", name, weight)
+		}
+		queues[name] = priority
+	}
+	return queues, nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os/exec"
+	"sync/atomic"
+
+	"github.com/Kanishk-K/UniteDownloader/Backend/pkg/videoutil"
+	"github.com/hibiken/asynq"
+)
+
+// healthServer answers the container health checks.
+// /healthz fails when the worker cannot do any work (no Redis or no ffmpeg) so ECS replaces it,
+// /readyz also fails while background clips are missing or the worker is draining, and reports the asset cache.
+type healthServer struct {
+	srv      *asynq.Server
+	assets   *videoutil.AssetCache
+	draining atomic.Bool
+}
+
+type healthResponse struct {
+	Status string                 `json:"status"`
+	Checks map[string]string      `json:"checks"`
+	Cache  *videoutil.CacheStatus `json:"cache,omitempty"`
+}
+
+func newHealthServer(srv *asynq.Server, assets *videoutil.AssetCache) *healthServer {
+	return &healthServer{srv: srv, assets: assets}
+}
+
+func (h *healthServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		h.respond(w, h.checkLive(), nil)
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		checks := h.checkLive()
+		cache := h.assets.Status()
+		checks["backgrounds"] = "ok"
+		if !cache.Ready() {
+			checks["backgrounds"] = fmt.Sprintf("%d of %d background clips are cached", cache.Cached, cache.Backgrounds)
+		}
+		checks["draining"] = "ok"
+		if h.draining.Load() {
+			checks["draining"] = "shutting down"
+		}
+		h.respond(w, checks, &cache)
+	})
+	return mux
+}
+
+// checkLive checks what every task needs, Redis to fetch it and ffmpeg to run it.
+func (h *healthServer) checkLive() map[string]string {
+	checks := map[string]string{"redis": "ok", "ffmpeg": "ok"}
+	if err := h.srv.Ping(); err != nil {
+		checks["redis"] = err.Error()
+	}
+	for _, binary := range []string{"ffmpeg", "ffprobe"} {
+		if _, err := exec.LookPath(binary); err != nil {
+			checks["ffmpeg"] = err.Error()
+		}
+	}
+	return checks
+}
+
+func (h *healthServer) respond(w http.ResponseWriter, checks map[string]string, cache *videoutil.CacheStatus) {
+	response := healthResponse{Status: "ok", Checks: checks, Cache: cache}
+	status := http.StatusOK
+	for _, result := range checks {
+		if result != "ok" {
+			response.Status = "unhealthy"
+			status = http.StatusServiceUnavailable
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Failed to write health response: %v", err)
+	}
+}
@@ -2,30 +2,49 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
 
 	cognitoclient "github.com/Kanishk-K/UniteDownloader/Backend/pkg/cognitoClient"
 	dynamo "github.com/Kanishk-K/UniteDownloader/Backend/pkg/dynamoClient"
+	"github.com/Kanishk-K/UniteDownloader/Backend/pkg/ffmpeg"
 	s3client "github.com/Kanishk-K/UniteDownloader/Backend/pkg/s3Client"
 	sesclient "github.com/Kanishk-K/UniteDownloader/Backend/pkg/sesClient"
+	subtitleclient "github.com/Kanishk-K/UniteDownloader/Backend/pkg/subtitleClient"
 	"github.com/Kanishk-K/UniteDownloader/Backend/pkg/tasks"
+	"github.com/Kanishk-K/UniteDownloader/Backend/pkg/videoutil"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/hibiken/asynq"
 )
 
+// HEALTH_SHUTDOWN_TIMEOUT is how long open health checks may take to finish once the tasks have drained
+const HEALTH_SHUTDOWN_TIMEOUT = 5 * time.Second
+
+// Every consumer schedules reconciliation, RECONCILE_UNIQUE keeps more than one run from being queued at a time
+const RECONCILE_UNIQUE = 50 * time.Minute
+
 func main() {
+	cfg, err := loadConfig()
+	if err != nil {
+		log.Fatalf("invalid consumer configuration: %v", err)
+	}
+	log.Printf("Running %d workers on queues %v (strict priority: %t)", cfg.concurrency, cfg.queues, cfg.strictPriority)
+	redisOpt := asynq.RedisClientOpt{Addr: os.Getenv("REDIS_URL")}
 	srv := asynq.NewServer(
-		asynq.RedisClientOpt{Addr: os.Getenv("REDIS_URL")},
+		redisOpt,
 		asynq.Config{
-			Concurrency: 1,
-			Queues: map[string]int{
-				"high":   3,
-				"medium": 2,
-				"low":    1,
-			},
-			StrictPriority: true,
+			Concurrency:     cfg.concurrency,
+			Queues:          cfg.queues,
+			StrictPriority:  cfg.strictPriority,
+			ShutdownTimeout: cfg.shutdownTimeout,
 		},
 	)
 	// Initialize the service
@@ -47,11 +66,92 @@ func main() {
 	sesClient := sesclient.NewSESClient(awsSession)
 	cognitoClient := cognitoclient.NewCognitoClient(awsSession)
 
-	vg := tasks.NewGenerateVideoProcess(s3Client, dynamoClient, sesClient, cognitoClient)
+	catalog, err := videoutil.LoadCatalog(context.Background(), s3Client, tasks.BUCKET)
+	if err != nil {
+		log.Fatalf("could not load background catalog: %v", err)
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		log.Fatalf("could not get working directory: %v", err)
+	}
+	assets := videoutil.NewAssetCache(s3Client, tasks.BUCKET, filepath.Join(dir, "static"), catalog)
+
+	// Filter graphs depend on which release of ffmpeg is installed in the image
+	ffmpegVersion, err := ffmpeg.DetectVersion()
+	if err != nil {
+		log.Fatalf("could not detect ffmpeg version: %v", err)
+	}
+	log.Printf("Using ffmpeg %s", ffmpegVersion)
+	// Narration times its subtitles against the audio with ffprobe
+	if _, err := exec.LookPath("ffprobe"); err != nil {
+		log.Fatalf("could not find ffprobe: %v", err)
+	}
+
+	vg := tasks.NewGenerateVideoProcess(s3Client, dynamoClient, sesClient, cognitoClient, assets, ffmpegVersion)
+	np := tasks.NewNarrationProcess(s3Client, dynamoClient, subtitleclient.NewSubtitleClient())
+	ib := tasks.NewIngestBackgroundProcess(s3Client, dynamoClient)
+	queue := asynq.NewClient(redisOpt)
+	defer queue.Close()
+	inspector := asynq.NewInspector(redisOpt)
+	defer inspector.Close()
+	pp := tasks.NewPipelineProcess(s3Client, dynamoClient, sesClient, cognitoClient, queue, inspector)
+	rp := tasks.NewReconcileProcess(s3Client, dynamoClient, queue, inspector)
+
+	// Answer health checks while the backgrounds download
+	health := newHealthServer(srv, assets)
+	healthServer := &http.Server{Addr: cfg.healthAddr, Handler: health.Handler()}
+	go func() {
+		if err := healthServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("could not run health server: %v", err)
+		}
+	}()
+
+	// Tasks download any clip the first sync missed, so a failed sync doesn't stop the consumer
+	if err := assets.Sync(context.Background()); err != nil {
+		log.Printf("Initial asset cache sync failed: %v", err)
+	}
+	syncCtx, stopSync := context.WithCancel(context.Background())
+	defer stopSync()
+	go assets.Run(syncCtx, cfg.cacheSyncInterval)
 
 	mux := asynq.NewServeMux()
-	mux.HandleFunc(tasks.VideoGenerationTask, vg.HandleVideoGenerationTask)
-	if err := srv.Run(mux); err != nil {
+	// Stage tasks keep their job's pipeline up to date and advance it once they finish
+	mux.HandleFunc(tasks.VideoGenerationTask, pp.StageHandler(tasks.VideoStageOf, vg.HandleVideoGenerationTask))
+	mux.HandleFunc(tasks.NarrationTask, pp.StageHandler(tasks.NarrationStageOf, np.HandleNarrationTask))
+	mux.HandleFunc(tasks.PipelineAdvanceTask, pp.HandlePipelineAdvanceTask)
+	mux.HandleFunc(tasks.PipelineRetryTask, pp.HandlePipelineRetryTask)
+	mux.HandleFunc(tasks.BackgroundIngestTask, ib.HandleBackgroundIngestTask)
+	mux.HandleFunc(tasks.ReconcileTask, rp.HandleReconcileTask)
+	if err := srv.Start(mux); err != nil {
 		log.Fatalf("could not run server: %v", err)
 	}
+
+	scheduler := asynq.NewScheduler(redisOpt, nil)
+	if cfg.reconcileSchedule != "" {
+		_, err = scheduler.Register(cfg.reconcileSchedule, tasks.NewReconcileTask(), asynq.Queue(tasks.QueueLow), asynq.MaxRetry(1), asynq.Unique(RECONCILE_UNIQUE))
+		if err != nil {
+			log.Fatalf("invalid reconcile schedule %q: %v", cfg.reconcileSchedule, err)
+		}
+		log.Printf("Scheduling reconciliation %s", cfg.reconcileSchedule)
+	}
+	if err := scheduler.Start(); err != nil {
+		log.Fatalf("could not run scheduler: %v", err)
+	}
+
+	// ECS sends SIGTERM before stopping the container, finish what is running and leave the rest queued
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	received := <-signals
+	log.Printf("Received %v, draining in-flight tasks for up to %v", received, cfg.shutdownTimeout)
+	health.draining.Store(true)
+	scheduler.Shutdown()
+	// Tasks still running after the shutdown timeout are cancelled and asynq requeues them
+	srv.Shutdown()
+	stopSync()
+	ctx, cancel := context.WithTimeout(context.Background(), HEALTH_SHUTDOWN_TIMEOUT)
+	defer cancel()
+	if err := healthServer.Shutdown(ctx); err != nil {
+		log.Printf("Failed to stop health server: %v", err)
+	}
+	log.Println("Consumer stopped")
 }
@@ -5,9 +5,11 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"slices"
 
 	apiresponse "github.com/Kanishk-K/UniteDownloader/Backend/pkg/apiResponse"
 	dynamo "github.com/Kanishk-K/UniteDownloader/Backend/pkg/dynamoClient"
+	"github.com/Kanishk-K/UniteDownloader/Backend/pkg/videoutil"
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/aws/aws-sdk-go-v2/config"
@@ -25,7 +27,7 @@ func NewExistsService(dynamoClient dynamo.DynamoMethods) *ExistsService {
 	return &ExistsService{dynamoClient: dynamoClient}
 }
 
-func (es ExistsService) handler(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+func (es ExistsService) handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 	resp := events.APIGatewayProxyResponse{
 		Headers: map[string]string{
 			"Content-Type":                 "application/json",
@@ -39,7 +41,7 @@ func (es ExistsService) handler(request events.APIGatewayProxyRequest) (events.A
 		apiresponse.APIErrorResponse(400, "No EntryID provided", &resp)
 		return resp, nil
 	}
-	jobInfo, err := es.dynamoClient.GetJob(entryID)
+	jobInfo, err := es.dynamoClient.GetJob(ctx, entryID)
 	if err != nil {
 		log.Println("Error getting job info: ", err)
 		apiresponse.APIErrorResponse(500, "Error getting job info", &resp)
@@ -49,10 +51,57 @@ func (es ExistsService) handler(request events.APIGatewayProxyRequest) (events.A
 		if jobInfo.VideosAvailable != nil {
 			respBody["videosAvailable"] = jobInfo.VideosAvailable
 		}
+		if len(jobInfo.VideoParts) > 0 {
+			videoParts := make(map[string][]string, len(jobInfo.VideoParts))
+			for videoID, count := range jobInfo.VideoParts {
+				videoParts[videoID] = videoutil.PartKeys(entryID, videoID, count)
+			}
+			respBody["videoParts"] = videoParts
+		}
 	} else {
 		apiresponse.APIErrorResponse(404, "Job not found", &resp)
 		return resp, nil
 	}
+	// Report how far along the videos that are still being generated are
+	videoRequests, err := es.dynamoClient.GetVideoRequests(ctx, entryID)
+	if err != nil {
+		log.Println("Error getting video requests: ", err)
+		apiresponse.APIErrorResponse(500, "Error getting job info", &resp)
+		return resp, nil
+	}
+	videoProgress := make(map[string]int)
+	videoFailures := make(map[string]*dynamo.VideoFailure)
+	videoPreviews := make(map[string]*dynamo.VideoPreviews)
+	videoStreams := make(map[string]string)
+	for _, videoRequest := range videoRequests {
+		if slices.Contains(jobInfo.VideosAvailable, videoRequest.RequestedVideo) || jobInfo.VideoParts[videoRequest.RequestedVideo] > 0 {
+			// Videos made before previews existed, or whose previews failed, have none
+			if videoRequest.Previews != nil {
+				videoPreviews[videoRequest.RequestedVideo] = videoRequest.Previews
+			}
+			// HLS videos have no MP4, players open the master playlist instead
+			if videoRequest.Format == videoutil.FORMAT_HLS {
+				videoStreams[videoRequest.RequestedVideo] = videoutil.HLSMasterKey(entryID, videoRequest.RequestedVideo)
+			}
+		} else {
+			videoProgress[videoRequest.RequestedVideo] = videoRequest.Progress
+			if videoRequest.Failure != nil {
+				videoFailures[videoRequest.RequestedVideo] = videoRequest.Failure
+			}
+		}
+	}
+	if len(videoProgress) > 0 {
+		respBody["videoProgress"] = videoProgress
+	}
+	if len(videoFailures) > 0 {
+		respBody["videoFailures"] = videoFailures
+	}
+	if len(videoPreviews) > 0 {
+		respBody["videoPreviews"] = videoPreviews
+	}
+	if len(videoStreams) > 0 {
+		respBody["videoStreams"] = videoStreams
+	}
 	apiresponse.APISuccessResponse(respBody, &resp)
 	return resp, nil
 }
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/Kanishk-K/UniteDownloader/Backend/pkg/tasks"
+	"github.com/Kanishk-K/UniteDownloader/Backend/pkg/videoutil"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/hibiken/asynq"
+)
+
+type IngestService struct {
+	jobQueue *asynq.Client
+}
+
+/*
+This path should be protected by the following S3 notification filter:
+{
+  "events": ["s3:ObjectCreated:*"],
+  "filter_prefix": "uploads/"
+}
+*/
+
+// processRecord queues the uploaded clip for the consumer to check and transcode.
+func (is IngestService) processRecord(ctx context.Context, record events.S3EventRecord) error {
+	userID, backgroundID, err := videoutil.ParseUserUploadKey(record.S3.Object.URLDecodedKey)
+	if err != nil {
+		log.Printf("Ignoring object: %v", err)
+		return nil
+	}
+	log.Printf("Processing upload %s for %s\n", backgroundID, userID)
+	task, err := tasks.NewBackgroundIngestTask(userID, backgroundID)
+	if err != nil {
+		log.Printf("Could not create the task: %s\n", err)
+		return err
+	}
+	_, err = is.jobQueue.EnqueueContext(
+		ctx,
+		task,
+		// Users wait on uploads in the extension so they go ahead of video generation
+		asynq.Queue(tasks.QueueHigh),
+		asynq.MaxRetry(3),
+		asynq.TaskID(fmt.Sprintf("ingest:%s:%s", userID, backgroundID)),
+		asynq.Retention(time.Hour*24),
+	)
+	if err != nil && !errors.Is(err, asynq.ErrTaskIDConflict) {
+		log.Printf("Could not enqueue the task: %s\n", err)
+		return err
+	}
+	log.Printf("Enqueued the task for upload: %s\n", backgroundID)
+	return nil
+}
+
+func (is IngestService) handler(ctx context.Context, request events.S3Event) error {
+	var errs []error
+	for _, record := range request.Records {
+		if err := is.processRecord(ctx, record); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func main() {
+	// Initialize the service
+	client := asynq.NewClient(asynq.RedisClientOpt{Addr: os.Getenv("REDIS_URL")})
+	if client == nil {
+		log.Printf("Could not connect to Redis")
+		return
+	}
+	defer client.Close()
+	is := IngestService{jobQueue: client}
+	lambda.Start(is.handler)
+}
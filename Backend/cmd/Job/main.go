@@ -10,12 +10,16 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strings"
+	"time"
 
 	apiresponse "github.com/Kanishk-K/UniteDownloader/Backend/pkg/apiResponse"
 	dynamo "github.com/Kanishk-K/UniteDownloader/Backend/pkg/dynamoClient"
 	"github.com/Kanishk-K/UniteDownloader/Backend/pkg/jobutil"
 	kalturaclient "github.com/Kanishk-K/UniteDownloader/Backend/pkg/kalturaClient"
 	s3client "github.com/Kanishk-K/UniteDownloader/Backend/pkg/s3Client"
+	subtitleclient "github.com/Kanishk-K/UniteDownloader/Backend/pkg/subtitleClient"
+	"github.com/Kanishk-K/UniteDownloader/Backend/pkg/videoutil"
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/aws/aws-sdk-go-v2/config"
@@ -26,32 +30,58 @@ import (
 
 const BUCKET = "lecture-processor"
 
+// MAX_COURSE_LENGTH keeps the course name on one line of the intro card
+const MAX_COURSE_LENGTH = 60
+
 const (
 	StatusNew     = "NEW"
 	StatusExists  = "EXISTS"
 	StatusSkipped = "SKIPPED"
 )
 
+// Sliding window limits on how often a user may submit and how many videos they may request
+var (
+	SUBMISSION_LIMIT = dynamo.RateLimit{Name: "submissions", Limit: 5, Window: time.Minute}
+	VIDEO_LIMIT      = dynamo.RateLimit{Name: "videos", Limit: 20, Window: 24 * time.Hour}
+)
+
 type JobSchedulerService struct {
 	dynamoClient dynamo.DynamoMethods
 	s3Client     s3client.S3Methods
 	LLMClient    *openai.Client
+	catalog      *videoutil.Catalog
 	isProd       bool
 }
 
-var validVideoChoices = map[string]bool{
-	"":               true,
-	"subway_surfers": true,
-	"minecraft":      true,
-	"baking":         true,
-	"makeup":         true,
-	"sand":           true,
-	"soap":           true,
+// dialogueSchema is the structured output format for podcast scripts, it mirrors subtitleclient.Dialogue.
+var dialogueSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"turns": map[string]interface{}{
+			"type": "array",
+			"items": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"speaker": map[string]interface{}{
+						"type": "string",
+						"enum": []string{subtitleclient.SPEAKER_HOST, subtitleclient.SPEAKER_EXPERT},
+					},
+					"text": map[string]interface{}{
+						"type": "string",
+					},
+				},
+				"required":             []string{"speaker", "text"},
+				"additionalProperties": false,
+			},
+		},
+	},
+	"required":             []string{"turns"},
+	"additionalProperties": false,
 }
 
-func validateRequest(requestBody *jobutil.JobQueueRequest) error {
-	// Step 1: Ensure the background video is from one of the available options
-	if _, ok := validVideoChoices[requestBody.BackgroundVideo]; !ok {
+func (jss JobSchedulerService) validateRequest(ctx context.Context, requestBody *jobutil.JobQueueRequest, subject string) error {
+	// Step 1: Ensure the background video (if any) is an enabled catalog entry or one of the user's own uploads
+	if requestBody.BackgroundVideo != "" && !jss.isSelectableBackground(ctx, requestBody.BackgroundVideo, subject) {
 		return fmt.Errorf("background video is not from an authorized source %s", requestBody.BackgroundVideo)
 	}
 	// Step 2: Ensure the entry ID is not empty
@@ -62,10 +92,91 @@ func validateRequest(requestBody *jobutil.JobQueueRequest) error {
 	if requestBody.Title == "" {
 		return errors.New("title is empty")
 	}
+	// Step 4: Ensure the summary style is one we can narrate
+	if requestBody.SummaryStyle == "" {
+		requestBody.SummaryStyle = jobutil.SummaryStyleNarrator
+	}
+	if requestBody.SummaryStyle != jobutil.SummaryStyleNarrator && requestBody.SummaryStyle != jobutil.SummaryStylePodcast {
+		return fmt.Errorf("summary style is not supported %s", requestBody.SummaryStyle)
+	}
+	// Step 5: Ensure the background music (if any) is from the music catalog and its track has been uploaded,
+	// narration can't be mixed without it
+	if requestBody.BackgroundMusic != "" {
+		track, ok := subtitleclient.MUSIC_CATALOG[requestBody.BackgroundMusic]
+		if !ok {
+			return fmt.Errorf("background music is not from an authorized source %s", requestBody.BackgroundMusic)
+		}
+		if !jss.hasMusicTrack(ctx, track) {
+			return fmt.Errorf("background music is not available %s", requestBody.BackgroundMusic)
+		}
+	}
+	// Step 6: Ensure the output preset exists
+	if requestBody.Preset == "" {
+		requestBody.Preset = videoutil.DEFAULT_PRESET
+	}
+	if _, err := videoutil.GetPreset(requestBody.Preset); err != nil {
+		return err
+	}
+	// Step 7: Ensure the output format exists
+	format, err := videoutil.GetFormat(requestBody.Format)
+	if err != nil {
+		return err
+	}
+	requestBody.Format = format
+	// Step 8: Ensure parts (if any) have a length short-form platforms accept, they are only rendered as MP4
+	if requestBody.PartLength != 0 {
+		if requestBody.PartLength < videoutil.MIN_PART_LENGTH || requestBody.PartLength > videoutil.MAX_PART_LENGTH {
+			return fmt.Errorf("part length must be between %d and %d seconds", videoutil.MIN_PART_LENGTH, videoutil.MAX_PART_LENGTH)
+		}
+		if requestBody.Format != videoutil.FORMAT_MP4 {
+			return fmt.Errorf("parts are not supported for format %s", requestBody.Format)
+		}
+	}
+	// Step 9: Ensure the overlays are ones we can draw
+	overlays, err := videoutil.NormalizeOverlays(requestBody.Overlays)
+	if err != nil {
+		return err
+	}
+	requestBody.Overlays = overlays
+	requestBody.Course = strings.TrimSpace(requestBody.Course)
+	if len(requestBody.Course) > MAX_COURSE_LENGTH {
+		return fmt.Errorf("course must be at most %d characters", MAX_COURSE_LENGTH)
+	}
+	// Step 10: Ensure the subtitle mode exists, caption tracks are only muxed into single MP4 videos
+	subtitles, err := videoutil.GetSubtitles(requestBody.Subtitles)
+	if err != nil {
+		return err
+	}
+	requestBody.Subtitles = subtitles
+	if requestBody.Subtitles == videoutil.SUBTITLES_SOFT && (requestBody.Format != videoutil.FORMAT_MP4 || requestBody.PartLength != 0) {
+		return fmt.Errorf("soft subtitles are only supported for single MP4 videos")
+	}
 
 	return nil
 }
 
+// isSelectableBackground reports whether the user may render on the background.
+func (jss JobSchedulerService) isSelectableBackground(ctx context.Context, backgroundID string, subject string) bool {
+	if !videoutil.IsUserBackground(backgroundID) {
+		return jss.catalog.IsSelectable(backgroundID)
+	}
+	userBackground, err := jss.dynamoClient.GetUserBackground(ctx, subject, backgroundID)
+	if err != nil {
+		return false
+	}
+	return userBackground != nil && userBackground.Status == dynamo.BackgroundStatusReady
+}
+
+// hasMusicTrack reports whether the track's file is in the bucket.
+func (jss JobSchedulerService) hasMusicTrack(ctx context.Context, track subtitleclient.MusicTrack) bool {
+	_, err := jss.s3Client.HeadFile(ctx, BUCKET, track.S3Key)
+	if err != nil {
+		log.Printf("Music track %s is missing from %s: %v", track.ID, track.S3Key, err)
+		return false
+	}
+	return true
+}
+
 func downloadTranscript(downloadLink string) (*string, error) {
 	// Download the transcript
 	resp, err := http.Get(downloadLink)
@@ -85,8 +196,8 @@ func downloadTranscript(downloadLink string) (*string, error) {
 	return &transcriptData, nil
 }
 
-func (jss JobSchedulerService) generateNotes(transcriptData *string, entryID string) error {
-	chatCompletion, err := jss.LLMClient.Chat.Completions.New(context.Background(), openai.ChatCompletionNewParams{
+func (jss JobSchedulerService) generateNotes(ctx context.Context, transcriptData *string, entryID string) error {
+	chatCompletion, err := jss.LLMClient.Chat.Completions.New(ctx, openai.ChatCompletionNewParams{
 		Messages: openai.F([]openai.ChatCompletionMessageParamUnion{
 			openai.SystemMessage(
 				"You are an assistant that generates notes for a lecture from a transcript.\n" +
@@ -111,7 +222,7 @@ func (jss JobSchedulerService) generateNotes(transcriptData *string, entryID str
 		return err
 	}
 	output := chatCompletion.Choices[0].Message.Content
-	err = jss.s3Client.UploadFile(BUCKET, fmt.Sprintf("assets/%s/Notes.md", entryID), bytes.NewReader([]byte(output)), "text/markdown")
+	err = jss.s3Client.UploadFile(ctx, BUCKET, fmt.Sprintf("assets/%s/Notes.md", entryID), bytes.NewReader([]byte(output)), "text/markdown")
 	if err != nil {
 		log.Printf("Failed to upload notes: %v", err)
 		return err
@@ -119,8 +230,8 @@ func (jss JobSchedulerService) generateNotes(transcriptData *string, entryID str
 	return nil
 }
 
-func (jss JobSchedulerService) generateSummary(transcriptData *string, entryID string) error {
-	chatCompletion, err := jss.LLMClient.Chat.Completions.New(context.Background(), openai.ChatCompletionNewParams{
+func (jss JobSchedulerService) generateSummary(ctx context.Context, transcriptData *string, entryID string) error {
+	chatCompletion, err := jss.LLMClient.Chat.Completions.New(ctx, openai.ChatCompletionNewParams{
 		Messages: openai.F([]openai.ChatCompletionMessageParamUnion{
 			openai.SystemMessage(
 				"You are an assistant that summarizes university lectures.\n" +
@@ -145,7 +256,7 @@ func (jss JobSchedulerService) generateSummary(transcriptData *string, entryID s
 		return err
 	}
 	output := chatCompletion.Choices[0].Message.Content
-	err = jss.s3Client.UploadFile(BUCKET, fmt.Sprintf("assets/%s/Summary.txt", entryID), bytes.NewReader([]byte(output)), "text/plain")
+	err = jss.s3Client.UploadFile(ctx, BUCKET, fmt.Sprintf("assets/%s/Summary.txt", entryID), bytes.NewReader([]byte(output)), "text/plain")
 	if err != nil {
 		log.Printf("Failed to upload notes: %v", err)
 		return err
@@ -153,7 +264,70 @@ func (jss JobSchedulerService) generateSummary(transcriptData *string, entryID s
 	return nil
 }
 
-func (jss JobSchedulerService) handler(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+func (jss JobSchedulerService) generateDialogue(ctx context.Context, transcriptData *string, entryID string) error {
+	chatCompletion, err := jss.LLMClient.Chat.Completions.New(ctx, openai.ChatCompletionNewParams{
+		Messages: openai.F([]openai.ChatCompletionMessageParamUnion{
+			openai.SystemMessage(
+				"You are an assistant that turns university lectures into a podcast conversation.\n" +
+					"\n" +
+					"GOALS:\n" +
+					"- The host is curious and asks the questions a student would ask.\n" +
+					"- The expert explains each concept in detail using simple language.\n" +
+					"- Express abstract ideas in an accessible manner.\n" +
+					"\n" +
+					"IMPORTANT: Only use plain spoken text in each turn. No bullet points, code, or structured sections.\n" +
+					"IMPORTANT: The host always speaks first. Speakers must be either \"host\" or \"expert\".\n" +
+					"IMPORTANT: Do not include a preface in your response, just the conversation.\n" +
+					"\n" +
+					"TRANSCRIPT:\n",
+			),
+			openai.UserMessage(*transcriptData),
+		}),
+		Model: openai.F(openai.ChatModelGPT4oMini),
+		ResponseFormat: openai.F[openai.ChatCompletionNewParamsResponseFormatUnion](
+			openai.ResponseFormatJSONSchemaParam{
+				Type: openai.F(openai.ResponseFormatJSONSchemaTypeJSONSchema),
+				JSONSchema: openai.F(openai.ResponseFormatJSONSchemaJSONSchemaParam{
+					Name:   openai.F("podcast_dialogue"),
+					Schema: openai.F[interface{}](dialogueSchema),
+					Strict: openai.Bool(true),
+				}),
+			},
+		),
+	})
+	if err != nil {
+		log.Printf("API call to generate dialogue failed: %v", err)
+		return err
+	}
+	output := chatCompletion.Choices[0].Message.Content
+	var dialogue subtitleclient.Dialogue
+	err = json.Unmarshal([]byte(output), &dialogue)
+	if err != nil {
+		log.Printf("Failed to decode dialogue: %v", err)
+		return err
+	}
+	err = subtitleclient.ValidateDialogue(&dialogue)
+	if err != nil {
+		log.Printf("Generated dialogue was not valid: %v", err)
+		return err
+	}
+	err = jss.s3Client.UploadFile(ctx, BUCKET, fmt.Sprintf("assets/%s/Dialogue.json", entryID), bytes.NewReader([]byte(output)), "application/json")
+	if err != nil {
+		log.Printf("Failed to upload dialogue: %v", err)
+		return err
+	}
+	return nil
+}
+
+// releaseRateLimit gives back an event taken against a limit, failing to only leaves the user one event short.
+func (jss JobSchedulerService) releaseRateLimit(ctx context.Context, subject string, limit dynamo.RateLimit, event *dynamo.RateLimitEvent) {
+	err := jss.dynamoClient.ReleaseRateLimit(ctx, subject, limit, *event)
+	if err != nil {
+		log.Printf("Failed to give back %s event for %s: %v", limit.Name, subject, err)
+	}
+}
+
+func (jss JobSchedulerService) handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 	resp := events.APIGatewayProxyResponse{
 		Headers: map[string]string{
 			"Content-Type":                 "application/json",
@@ -169,7 +343,14 @@ func (jss JobSchedulerService) handler(request events.APIGatewayProxyRequest) (e
 		apiresponse.APIErrorResponse(500, "Failed to decode request body", &resp)
 		return resp, nil
 	}
-	err = validateRequest(&requestBody)
+	var subject string
+	if jss.isProd {
+		subject = request.RequestContext.Authorizer["claims"].(map[string]any)["cognito:username"].(string)
+	} else {
+		subject = "DEV USER"
+	}
+	log.Print("Subject: ", subject)
+	err = jss.validateRequest(ctx, &requestBody, subject)
 	if err != nil {
 		apiresponse.APIErrorResponse(500, "Submitted request was not valid", &resp)
 		return resp, nil
@@ -177,15 +358,66 @@ func (jss JobSchedulerService) handler(request events.APIGatewayProxyRequest) (e
 	/*
 		Buisness logic goes here
 	*/
-	var subject string
-	if jss.isProd {
-		subject = request.RequestContext.Authorizer["claims"].(map[string]any)["cognito:username"].(string)
-	} else {
-		subject = "DEV USER"
+	// Take the rate limits before anything is created, the ones already taken are given back if a later one is over
+	limits := []dynamo.RateLimit{SUBMISSION_LIMIT}
+	if requestBody.BackgroundVideo != "" {
+		limits = append(limits, VIDEO_LIMIT)
 	}
-	log.Print("Subject: ", subject)
+	var limitEvents []*dynamo.RateLimitEvent
+	for _, limit := range limits {
+		event, retryAfter, err := jss.dynamoClient.TakeRateLimit(ctx, subject, limit)
+		if err != nil || retryAfter > 0 {
+			for i, taken := range limitEvents {
+				jss.releaseRateLimit(ctx, subject, limits[i], taken)
+			}
+		}
+		if err != nil {
+			apiresponse.APIErrorResponse(500, "Failed to check rate limits", &resp)
+			return resp, err
+		}
+		if retryAfter > 0 {
+			log.Printf("%s is over the %s limit, retry after %v", subject, limit.Name, retryAfter)
+			apiresponse.APIRateLimitResponse(retryAfter, fmt.Sprintf("Too many %s, try again later", limit.Name), &resp)
+			return resp, nil
+		}
+		limitEvents = append(limitEvents, event)
+	}
+	// The video limit only counts new video requests, it is given back unless one is created
+	var videoEvent *dynamo.RateLimitEvent
+	if requestBody.BackgroundVideo != "" {
+		videoEvent = limitEvents[1]
+		defer func() {
+			if videoEvent != nil {
+				jss.releaseRateLimit(ctx, subject, VIDEO_LIMIT, videoEvent)
+			}
+		}()
+	}
+
+	// Videos count against their own quota, it is given back unless a new video request is created
+	videoCharged := false
+	if requestBody.BackgroundVideo != "" {
+		err = jss.dynamoClient.AddVideoRenderToUser(ctx, subject)
+		if err != nil {
+			var ccfe *types.ConditionalCheckFailedException
+			if errors.As(err, &ccfe) {
+				apiresponse.APIErrorResponse(403, "Video quota used up", &resp)
+				return resp, nil
+			}
+			apiresponse.APIErrorResponse(500, "Failed to check video quota", &resp)
+			return resp, err
+		}
+		videoCharged = true
+		defer func() {
+			if videoCharged {
+				if err := jss.dynamoClient.RefundVideoRender(ctx, subject); err != nil {
+					log.Printf("Failed to refund video for %s: %v", subject, err)
+				}
+			}
+		}()
+	}
+
 	// Add the job if it doesn't exist
-	err = jss.dynamoClient.CreateJobIfNotExists(requestBody.EntryID, requestBody.Title, subject)
+	err = jss.dynamoClient.CreateJobIfNotExists(ctx, requestBody.EntryID, requestBody.Title, requestBody.Course, subject, requestBody.SummaryStyle, requestBody.BackgroundMusic)
 	if err != nil {
 		var ccfe *types.ConditionalCheckFailedException
 		if errors.As(err, &ccfe) {
@@ -199,71 +431,100 @@ func (jss JobSchedulerService) handler(request events.APIGatewayProxyRequest) (e
 		}
 	} else {
 		respBody["contentGeneration"] = StatusNew
-		err = jss.dynamoClient.AddScheduledJobToUser(subject, requestBody.EntryID)
+		err = jss.dynamoClient.AddScheduledJobToUser(ctx, subject, requestBody.EntryID)
 		if err != nil {
-			_ = jss.dynamoClient.DeleteJobByUser(requestBody.EntryID, subject)
-			apiresponse.APIErrorResponse(500, "User not permitted to create more requests", &resp)
+			_ = jss.dynamoClient.DeleteJobByUser(ctx, requestBody.EntryID, subject)
+			var ccfe *types.ConditionalCheckFailedException
+			if errors.As(err, &ccfe) {
+				apiresponse.APIErrorResponse(403, "User not permitted to create more requests", &resp)
+			} else {
+				apiresponse.APIErrorResponse(500, "User not permitted to create more requests", &resp)
+			}
 			return resp, nil
 		}
 
-		transcriptLink, err := kalturaclient.GetTranscriptLink(requestBody.EntryID)
+		transcriptAsset, err := kalturaclient.GetTranscriptAsset(requestBody.EntryID)
 		if err != nil {
-			_ = jss.dynamoClient.DeleteJobByUser(requestBody.EntryID, subject)
-			_ = jss.dynamoClient.DeregisterJobFromUser(subject, requestBody.EntryID)
+			_ = jss.dynamoClient.DeleteJobByUser(ctx, requestBody.EntryID, subject)
+			_ = jss.dynamoClient.DeregisterJobFromUser(ctx, subject, requestBody.EntryID)
 			apiresponse.APIErrorResponse(500, "Failed to get transcript link", &resp)
+			return resp, nil
+		}
+		// The queue puts videos of recently released lectures first
+		err = jss.dynamoClient.RecordLectureRelease(ctx, requestBody.EntryID, time.Unix(transcriptAsset.CreatedAt, 0))
+		if err != nil {
+			log.Printf("Failed to record lecture release for %s: %v", requestBody.EntryID, err)
 		}
-		transcriptString, err := downloadTranscript(transcriptLink)
+		transcriptString, err := downloadTranscript(kalturaclient.BuildTranscriptLinkURL(transcriptAsset.ID))
 		if err != nil {
-			_ = jss.dynamoClient.DeleteJobByUser(requestBody.EntryID, subject)
-			_ = jss.dynamoClient.DeregisterJobFromUser(subject, requestBody.EntryID)
+			_ = jss.dynamoClient.DeleteJobByUser(ctx, requestBody.EntryID, subject)
+			_ = jss.dynamoClient.DeregisterJobFromUser(ctx, subject, requestBody.EntryID)
 			apiresponse.APIErrorResponse(500, "Failed to download transcript", &resp)
 			return resp, err
 		}
 
 		if len(*transcriptString) > 480000 {
 			// Transcript is too long, reject the request
-			_ = jss.dynamoClient.DeleteJobByUser(requestBody.EntryID, subject)
-			_ = jss.dynamoClient.DeregisterJobFromUser(subject, requestBody.EntryID)
+			_ = jss.dynamoClient.DeleteJobByUser(ctx, requestBody.EntryID, subject)
+			_ = jss.dynamoClient.DeregisterJobFromUser(ctx, subject, requestBody.EntryID)
 			apiresponse.APIErrorResponse(500, "Transcript is too long", &resp)
 			return resp, nil
 		}
 
 		var errGroup errgroup.Group
 		errGroup.Go(func() error {
-			return jss.generateNotes(transcriptString, requestBody.EntryID)
+			return jss.generateNotes(ctx, transcriptString, requestBody.EntryID)
 		})
 		errGroup.Go(func() error {
-			return jss.generateSummary(transcriptString, requestBody.EntryID)
+			return jss.generateSummary(ctx, transcriptString, requestBody.EntryID)
 		})
+		if requestBody.SummaryStyle == jobutil.SummaryStylePodcast {
+			errGroup.Go(func() error {
+				return jss.generateDialogue(ctx, transcriptString, requestBody.EntryID)
+			})
+		}
 		if err := errGroup.Wait(); err != nil {
-			_ = jss.dynamoClient.DeleteJobByUser(requestBody.EntryID, subject)
-			_ = jss.dynamoClient.DeregisterJobFromUser(subject, requestBody.EntryID)
+			_ = jss.dynamoClient.DeleteJobByUser(ctx, requestBody.EntryID, subject)
+			_ = jss.dynamoClient.DeregisterJobFromUser(ctx, subject, requestBody.EntryID)
 			apiresponse.APIErrorResponse(500, "Failed to generate notes or summary", &resp)
 			return resp, err
 		}
 	}
 
 	if requestBody.BackgroundVideo != "" {
-		respBody["videoGeneration"] = StatusNew
-		// Request subtitle generation
-		err = jss.dynamoClient.GenerateSubtitles(requestBody.EntryID, requestBody.BackgroundVideo)
-		if err != nil {
-			apiresponse.APIErrorResponse(500, "Failed to update job status", &resp)
-			return resp, err
+		videoOptions := dynamo.VideoOptions{
+			BackgroundVideo: requestBody.BackgroundVideo,
+			Preset:          requestBody.Preset,
+			Format:          requestBody.Format,
+			PartLength:      requestBody.PartLength,
+			Overlays:        requestBody.Overlays,
+			Subtitles:       requestBody.Subtitles,
 		}
-
-		// Request video generation
-		err = jss.dynamoClient.CreateVideoRequest(requestBody.EntryID, requestBody.BackgroundVideo, subject)
+		respBody["videoGeneration"] = StatusNew
+		videoID := videoutil.VideoID(videoOptions)
+		respBody["videoID"] = videoID
+		// Request video generation, the pipeline narrates the job before rendering it
+		err = jss.dynamoClient.CreateVideoRequest(ctx, requestBody.EntryID, videoID, videoOptions, subject)
 		if err != nil {
 			var ccfe *types.ConditionalCheckFailedException
 			if errors.As(err, &ccfe) {
-				// Video requested previously
-				respBody["videoGeneration"] = StatusExists
+				// Video requested previously, run it again if it failed for good
+				err = jss.dynamoClient.ReplaceFailedVideoRequest(ctx, requestBody.EntryID, videoID, videoOptions, subject)
+				if errors.As(err, &ccfe) {
+					respBody["videoGeneration"] = StatusExists
+				} else if err != nil {
+					apiresponse.APIErrorResponse(500, "Failed to retry video request", &resp)
+					return resp, err
+				}
 			} else {
 				apiresponse.APIErrorResponse(500, "Failed to create new video request", &resp)
 				return resp, err
 			}
 		}
+		if respBody["videoGeneration"] == StatusNew {
+			videoCharged = false
+			videoEvent = nil
+		}
 	} else {
 		respBody["videoGeneration"] = StatusSkipped
 	}
@@ -292,10 +553,17 @@ func main() {
 
 	LLMClient := openai.NewClient()
 
+	catalog, err := videoutil.LoadCatalog(context.Background(), s3Client, BUCKET)
+	if err != nil {
+		fmt.Println("Failed to load background catalog:", err)
+		return
+	}
+
 	jss := JobSchedulerService{
 		dynamoClient: dynamoClient,
 		s3Client:     s3Client,
 		LLMClient:    LLMClient,
+		catalog:      catalog,
 	}
 
 	jss.isProd = os.Getenv("AWS_SAM_LOCAL") != "true"
@@ -18,10 +18,10 @@ type PostSignUpService struct {
 	dynamoClient dynamo.DynamoMethods
 }
 
-func (psus PostSignUpService) handler(request events.CognitoEventUserPoolsPostConfirmation) (events.CognitoEventUserPoolsPostConfirmation, error) {
+func (psus PostSignUpService) handler(ctx context.Context, request events.CognitoEventUserPoolsPostConfirmation) (events.CognitoEventUserPoolsPostConfirmation, error) {
 	log.Println(request.CognitoEventUserPoolsHeader.UserName)
 	// Add the user to the DynamoDB table
-	err := psus.dynamoClient.CreateUserIfNotExists(request.CognitoEventUserPoolsHeader.UserName)
+	err := psus.dynamoClient.CreateUserIfNotExists(ctx, request.CognitoEventUserPoolsHeader.UserName)
 	if err != nil {
 		var ccfe *types.ConditionalCheckFailedException
 		if errors.As(err, &ccfe) {
@@ -1,93 +1,122 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
-	"time"
 
+	dynamo "github.com/Kanishk-K/UniteDownloader/Backend/pkg/dynamoClient"
 	"github.com/Kanishk-K/UniteDownloader/Backend/pkg/tasks"
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/hibiken/asynq"
 )
 
 type QueueService struct {
-	jobQueue *asynq.Client
+	jobQueue     *asynq.Client
+	inspector    *asynq.Inspector
+	dynamoClient dynamo.DynamoMethods
 }
 
 /*
-This path should be protected by the following dynamodb filter:
+This path should be protected by the following dynamodb filters, the second matches a failed request being replaced:
 {
   "eventName": ["INSERT"],
 }
-*/
-
-func (qs QueueService) getPriority(EntryID string) asynq.Option {
-	// Get the priority of the task based on the entryID
-	return asynq.Queue("low")
+{
+  "eventName": ["MODIFY"],
+  "dynamodb": {
+    "OldImage": {"failure": {"M": {"retryable": {"BOOL": [false]}}}},
+    "NewImage": {"failure": [{"exists": false}]}
+  }
 }
+*/
 
-func (qs QueueService) handler(request events.DynamoDBEvent) (events.DynamoDBEventResponse, error) {
-	resp := events.DynamoDBEventResponse{}
-	// Print the request for debugging
-	entryID := request.Records[0].Change.NewImage["entryID"].String()
-	backgroundVideo := request.Records[0].Change.NewImage["requestedVideo"].String()
-	requestedBy := request.Records[0].Change.NewImage["requestedBy"].String()
-	if entryID == "" || backgroundVideo == "" {
-		log.Printf("EntryID or background video is empty")
-		resp.BatchItemFailures = []events.DynamoDBBatchItemFailure{
-			{
-				ItemIdentifier: request.Records[0].EventID,
-			},
-		}
-		return resp, fmt.Errorf("entryID or background video is empty")
-	}
-	priority := qs.getPriority(entryID)
-	log.Printf("Processing request for entryID: %s\n", entryID)
-	log.Printf("Request: %v\n", request.Records[0])
-
-	log.Printf("Background video: %s\n", backgroundVideo)
-	log.Printf("Priority: %s\n", priority)
-	task, err := tasks.NewVideoGenerationTask(entryID, requestedBy, backgroundVideo)
+// processRecord adds a single video request to its job's pipeline.
+func (qs QueueService) processRecord(ctx context.Context, record events.DynamoDBEventRecord) error {
+	videoRequest, err := dynamo.DecodeVideoRequestImage(record.Change.NewImage)
 	if err != nil {
-		log.Printf("Could not create the task: %s\n", err)
-		resp.BatchItemFailures = []events.DynamoDBBatchItemFailure{
-			{
-				ItemIdentifier: request.Records[0].EventID,
-			},
-		}
-		return resp, err
+		log.Printf("Failed to decode video request image: %v", err)
+		return err
+	}
+	priority, reason := qs.getPriority(ctx, videoRequest)
+	if videoRequest.BackgroundVideo == "" {
+		// Requests made before output presets only stored the background video
+		videoRequest.BackgroundVideo = videoRequest.RequestedVideo
 	}
-	_, err = qs.jobQueue.Enqueue(
-		task,
-		priority,
-		asynq.MaxRetry(3),
-		asynq.TaskID(fmt.Sprintf("%s:%s", entryID, backgroundVideo)),
-		asynq.Unique(time.Hour*24),
-		asynq.Retention(time.Hour*24*7),
+	log.Printf(
+		"Processing request for entryID: %s video=%s background=%s preset=%s format=%s partLength=%d overlays=%v subtitles=%s priority=%s reason=%q\n",
+		videoRequest.EntryID, videoRequest.RequestedVideo, videoRequest.BackgroundVideo, videoRequest.Preset, videoRequest.Format,
+		videoRequest.PartLength, videoRequest.Overlays, videoRequest.Subtitles, priority, reason,
 	)
+	// The request is read back from the table when its stage is queued, so store its priority first
+	err = qs.dynamoClient.RecordVideoPriority(ctx, videoRequest.EntryID, videoRequest.RequestedVideo, priority, reason)
+	if err != nil {
+		log.Printf("Could not record the priority for entryID %s: %v", videoRequest.EntryID, err)
+		return err
+	}
+	job, err := qs.dynamoClient.GetJob(ctx, videoRequest.EntryID)
+	if err != nil {
+		log.Printf("Could not get the job for entryID %s: %v", videoRequest.EntryID, err)
+		return err
+	}
+	if job == nil {
+		log.Printf("Job %s no longer exists, dropping the request\n", videoRequest.EntryID)
+		return nil
+	}
+	// The video is rendered once the job's narration is done, the pipeline runs both in order
+	err = tasks.StartPipeline(ctx, qs.dynamoClient, qs.jobQueue, job, *videoRequest)
 	if err != nil {
-		log.Printf("Could not enqueue the task: %s\n", err)
-		resp.BatchItemFailures = []events.DynamoDBBatchItemFailure{
-			{
-				ItemIdentifier: request.Records[0].EventID,
-			},
+		log.Printf("Could not start the pipeline: %s\n", err)
+		return err
+	}
+	log.Printf("Started the pipeline for entryID: %s\n", videoRequest.EntryID)
+	return nil
+}
+
+func (qs QueueService) handler(ctx context.Context, request events.DynamoDBEvent) (events.DynamoDBEventResponse, error) {
+	resp := events.DynamoDBEventResponse{}
+	for _, record := range request.Records {
+		if err := qs.processRecord(ctx, record); err != nil {
+			log.Printf("Failed to process record %s: %v", record.EventID, err)
+			resp.BatchItemFailures = append(resp.BatchItemFailures, events.DynamoDBBatchItemFailure{
+				ItemIdentifier: record.EventID,
+			})
 		}
-		return resp, nil
 	}
-	log.Printf("Enqueued the task for entryID: %s\n", entryID)
 	return resp, nil
 }
 
 func main() {
 	// Initialize the service
-	client := asynq.NewClient(asynq.RedisClientOpt{Addr: os.Getenv("REDIS_URL")})
+	redisOpt := asynq.RedisClientOpt{Addr: os.Getenv("REDIS_URL")}
+	client := asynq.NewClient(redisOpt)
 	if client == nil {
 		log.Printf("Could not connect to Redis")
 		return
 	}
 	defer client.Close()
-	qs := QueueService{jobQueue: client}
+	inspector := asynq.NewInspector(redisOpt)
+	defer inspector.Close()
+
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = "us-east-1"
+	}
+	awsSession, err := config.LoadDefaultConfig(
+		context.Background(),
+		config.WithRegion(region),
+	)
+	if err != nil {
+		fmt.Println("Failed to load AWS configuration:", err)
+		return
+	}
+	qs := QueueService{
+		jobQueue:     client,
+		inspector:    inspector,
+		dynamoClient: dynamo.NewDynamoClient(awsSession),
+	}
 	lambda.Start(qs.handler)
 }
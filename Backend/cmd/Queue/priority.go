@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	dynamo "github.com/Kanishk-K/UniteDownloader/Backend/pkg/dynamoClient"
+	"github.com/Kanishk-K/UniteDownloader/Backend/pkg/tasks"
+	"github.com/hibiken/asynq"
+)
+
+const (
+	// Lectures released within RELEASE_WINDOW are likely being studied right now
+	RELEASE_WINDOW = 48 * time.Hour
+	// Users who requested HEAVY_USAGE videos within USAGE_WINDOW wait behind everyone else
+	USAGE_WINDOW = 24 * time.Hour
+	HEAVY_USAGE  = 5
+	// A queue with QUEUE_DEPTH_LIMIT pending tasks is skipped for the one below it if that one is shorter
+	QUEUE_DEPTH_LIMIT = 20
+)
+
+// getPriority picks the queue for a video request and explains why. Each signal that can't be read is
+// skipped, a request is never held back because its priority couldn't be worked out.
+func (qs QueueService) getPriority(ctx context.Context, videoRequest *dynamo.VideoRequestDocument) (string, string) {
+	// level indexes tasks.QueuesByPriority
+	level := 0
+	var reasons []string
+
+	// Step 1: Paying users start a level up
+	user, err := qs.dynamoClient.GetUser(ctx, videoRequest.RequestedBy)
+	if err != nil {
+		reasons = append(reasons, "tier unknown")
+	} else if user != nil && user.Tier == dynamo.UserTierPro {
+		level++
+		reasons = append(reasons, "pro tier")
+	} else {
+		reasons = append(reasons, "free tier")
+	}
+
+	// Step 2: New lectures go up a level
+	job, err := qs.dynamoClient.GetJob(ctx, videoRequest.EntryID)
+	if err != nil {
+		reasons = append(reasons, "release unknown")
+	} else if job != nil && job.ReleasedOn != "" {
+		releasedOn, err := time.Parse(dynamo.DATE_FORMAT, job.ReleasedOn)
+		if err != nil {
+			log.Printf("Invalid release date %q for %s: %v", job.ReleasedOn, videoRequest.EntryID, err)
+		} else if age := time.Since(releasedOn); age < RELEASE_WINDOW {
+			level++
+			reasons = append(reasons, fmt.Sprintf("lecture released %s ago", age.Round(time.Minute)))
+		}
+	}
+
+	// Step 3: Heavy users go down a level so one user can't fill the queue
+	recent, err := qs.dynamoClient.CountVideoRequestsSince(ctx, videoRequest.RequestedBy, time.Now().Add(-USAGE_WINDOW))
+	if err != nil {
+		reasons = append(reasons, "usage unknown")
+	} else if recent >= HEAVY_USAGE {
+		level--
+		reasons = append(reasons, fmt.Sprintf("%d videos in %.0fh", recent, USAGE_WINDOW.Hours()))
+	}
+	level = max(0, min(level, len(tasks.QueuesByPriority)-1))
+
+	// Step 4: Move down from a backed up queue when the next one has room, the consumer weights let it be served sooner
+	if level > 0 {
+		pending := qs.pendingTasks(tasks.QueuesByPriority[level])
+		below := qs.pendingTasks(tasks.QueuesByPriority[level-1])
+		if pending >= QUEUE_DEPTH_LIMIT && below < QUEUE_DEPTH_LIMIT {
+			reasons = append(reasons, fmt.Sprintf("%s has %d pending", tasks.QueuesByPriority[level], pending))
+			level--
+		}
+	}
+	return tasks.QueuesByPriority[level], strings.Join(reasons, ", ")
+}
+
+// pendingTasks is how many tasks are waiting in a queue, a queue that was never used has none.
+func (qs QueueService) pendingTasks(queue string) int {
+	info, err := qs.inspector.GetQueueInfo(queue)
+	if err != nil {
+		if !errors.Is(err, asynq.ErrQueueNotFound) {
+			log.Printf("Could not inspect queue %s: %v", queue, err)
+		}
+		return 0
+	}
+	return info.Pending
+}
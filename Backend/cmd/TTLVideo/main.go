@@ -7,6 +7,7 @@ import (
 
 	dynamo "github.com/Kanishk-K/UniteDownloader/Backend/pkg/dynamoClient"
 	s3client "github.com/Kanishk-K/UniteDownloader/Backend/pkg/s3Client"
+	"github.com/Kanishk-K/UniteDownloader/Backend/pkg/videoutil"
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/aws/aws-sdk-go-v2/config"
@@ -17,50 +18,75 @@ This path should be protected by the following dynamodb filter:
 
 	{
 	  "eventName": ["REMOVE"],
+	  "userIdentity": {
+	    "type": ["Service"],
+	    "principalId": ["dynamodb.amazonaws.com"]
+	  }
 	}
+
+Rows deleted by the API, such as cancelled or replaced requests, are not expired videos and are skipped.
 */
 const BUCKET = "lecture-processor"
 
+// TTL_PRINCIPAL is the stream identity of removals made by DynamoDB's time to live
+const TTL_PRINCIPAL = "dynamodb.amazonaws.com"
+
 type TTLVideoService struct {
 	dynamoClient dynamo.DynamoMethods
 	s3Client     s3client.S3Methods
 }
 
-func (tvs *TTLVideoService) handler(request events.DynamoDBEvent) (events.DynamoDBEventResponse, error) {
-	resp := events.DynamoDBEventResponse{}
-	entryID := request.Records[0].Change.OldImage["entryID"].String()
-	requestedVideo := request.Records[0].Change.OldImage["requestedVideo"].String()
-	if entryID == "" || requestedVideo == "" {
-		fmt.Println("EntryID or requested video is empty")
-		resp.BatchItemFailures = []events.DynamoDBBatchItemFailure{
-			{
-				ItemIdentifier: request.Records[0].EventID,
-			},
-		}
-		return resp, fmt.Errorf("entryID or requested video is empty")
+// processRecord removes an expired video from its job and from S3.
+func (tvs *TTLVideoService) processRecord(ctx context.Context, record events.DynamoDBEventRecord) error {
+	if record.UserIdentity == nil || record.UserIdentity.Type != "Service" || record.UserIdentity.PrincipalID != TTL_PRINCIPAL {
+		fmt.Printf("Skipping record %s, it was not removed by the TTL\n", record.EventID)
+		return nil
+	}
+	videoRequest, err := dynamo.DecodeVideoRequestImage(record.Change.OldImage)
+	if err != nil {
+		fmt.Printf("Could not decode video request image: %s\n", err)
+		return err
+	}
+	fmt.Printf("Removing %s video for entryID: %s\n", videoRequest.RequestedVideo, videoRequest.EntryID)
+	if videoRequest.PartLength > 0 {
+		err = tvs.dynamoClient.RemoveVideoPartsFromJob(ctx, videoRequest.EntryID, videoRequest.RequestedVideo)
+	} else {
+		err = tvs.dynamoClient.RemoveVideoFromJob(ctx, videoRequest.EntryID, videoRequest.RequestedVideo)
 	}
-	fmt.Printf("Removing %s video for entryID: %s\n", requestedVideo, entryID)
-	err := tvs.dynamoClient.RemoveVideoFromJob(entryID, requestedVideo)
 	if err != nil {
 		fmt.Printf("Could not remove video from job: %s\n", err)
-		resp.BatchItemFailures = []events.DynamoDBBatchItemFailure{
-			{
-				ItemIdentifier: request.Records[0].EventID,
-			},
+		return err
+	}
+	// The poster and preview are deleted with the video, deleting ones that were never made is not an error
+	for _, key := range videoutil.VideoAssetKeys(videoRequest.EntryID, videoRequest.RequestedVideo) {
+		err = tvs.s3Client.DeleteFile(ctx, BUCKET, key)
+		if err != nil {
+			fmt.Printf("Could not delete %s: %s\n", key, err)
+			return err
 		}
-		return resp, err
 	}
-	err = tvs.s3Client.DeleteFile(BUCKET, fmt.Sprintf("assets/%s/%s.mp4", entryID, requestedVideo))
-	if err != nil {
-		fmt.Printf("Could not delete the video: %s\n", err)
-		resp.BatchItemFailures = []events.DynamoDBBatchItemFailure{
-			{
-				ItemIdentifier: request.Records[0].EventID,
-			},
+	// HLS streams and parts are stored as many files under the video's prefix
+	if videoRequest.Format == videoutil.FORMAT_HLS || videoRequest.PartLength > 0 {
+		prefix := videoutil.VideoPrefix(videoRequest.EntryID, videoRequest.RequestedVideo)
+		err = tvs.s3Client.DeletePrefix(ctx, BUCKET, prefix)
+		if err != nil {
+			fmt.Printf("Could not delete the files under %s: %s\n", prefix, err)
+			return err
+		}
+	}
+	fmt.Printf("Successfully deleted %s video for entryID: %s\n", videoRequest.RequestedVideo, videoRequest.EntryID)
+	return nil
+}
+
+func (tvs *TTLVideoService) handler(ctx context.Context, request events.DynamoDBEvent) (events.DynamoDBEventResponse, error) {
+	resp := events.DynamoDBEventResponse{}
+	for _, record := range request.Records {
+		if err := tvs.processRecord(ctx, record); err != nil {
+			resp.BatchItemFailures = append(resp.BatchItemFailures, events.DynamoDBBatchItemFailure{
+				ItemIdentifier: record.EventID,
+			})
 		}
-		return resp, err
 	}
-	fmt.Printf("Successfully deleted %s video for entryID: %s\n", requestedVideo, entryID)
 	return resp, nil
 }
 
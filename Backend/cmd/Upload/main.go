@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	apiresponse "github.com/Kanishk-K/UniteDownloader/Backend/pkg/apiResponse"
+	dynamo "github.com/Kanishk-K/UniteDownloader/Backend/pkg/dynamoClient"
+	s3client "github.com/Kanishk-K/UniteDownloader/Backend/pkg/s3Client"
+	"github.com/Kanishk-K/UniteDownloader/Backend/pkg/videoutil"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/config"
+)
+
+const BUCKET = "lecture-processor"
+
+const (
+	MAX_USER_BACKGROUNDS    = 10
+	MAX_DISPLAY_NAME_LENGTH = 40
+	UPLOAD_URL_EXPIRY       = 15 * time.Minute
+)
+
+type UploadService struct {
+	s3Client     s3client.S3Methods
+	dynamoClient dynamo.DynamoMethods
+	isProd       bool
+}
+
+type UploadRequest struct {
+	DisplayName string `json:"displayName"`
+	ContentType string `json:"contentType"`
+}
+
+type UploadResponse struct {
+	BackgroundID string `json:"backgroundID"`
+	UploadURL    string `json:"uploadURL"`
+	ContentType  string `json:"contentType"`
+	ExpiresIn    int    `json:"expiresIn"`
+}
+
+func NewUploadService(s3Client s3client.S3Methods, dynamoClient dynamo.DynamoMethods) *UploadService {
+	return &UploadService{s3Client: s3Client, dynamoClient: dynamoClient}
+}
+
+func validateRequest(requestBody *UploadRequest) error {
+	// Step 1: Ensure the clip has a name to show in the background picker
+	requestBody.DisplayName = strings.TrimSpace(requestBody.DisplayName)
+	if requestBody.DisplayName == "" || len(requestBody.DisplayName) > MAX_DISPLAY_NAME_LENGTH {
+		return fmt.Errorf("display name must be between 1 and %d characters", MAX_DISPLAY_NAME_LENGTH)
+	}
+	// Step 2: Ensure the container is one the consumer can read
+	if !slices.Contains(videoutil.UPLOAD_CONTENT_TYPES, requestBody.ContentType) {
+		return fmt.Errorf("content type is not supported %s", requestBody.ContentType)
+	}
+	return nil
+}
+
+// handler registers a pending background for the user and returns a presigned URL to upload the clip to.
+// Once the upload lands in S3 the Ingest lambda queues it for the consumer to check and transcode.
+func (us UploadService) handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	resp := events.APIGatewayProxyResponse{
+		Headers: map[string]string{
+			"Content-Type":                 "application/json",
+			"Access-Control-Allow-Origin":  "*",
+			"Access-Control-Allow-Headers": "Content-Type,Authorization",
+		},
+		IsBase64Encoded: false,
+	}
+	requestBody := UploadRequest{}
+	err := json.Unmarshal([]byte(request.Body), &requestBody)
+	if err != nil {
+		apiresponse.APIErrorResponse(400, "Failed to decode request body", &resp)
+		return resp, nil
+	}
+	err = validateRequest(&requestBody)
+	if err != nil {
+		log.Printf("Invalid upload request: %v", err)
+		apiresponse.APIErrorResponse(400, "Submitted request was not valid", &resp)
+		return resp, nil
+	}
+	var subject string
+	if us.isProd {
+		subject = request.RequestContext.Authorizer["claims"].(map[string]any)["cognito:username"].(string)
+	} else {
+		subject = "DEV USER"
+	}
+
+	existing, err := us.dynamoClient.ListUserBackgrounds(ctx, subject)
+	if err != nil {
+		apiresponse.APIErrorResponse(500, "Failed to read backgrounds", &resp)
+		return resp, nil
+	}
+	if len(existing) >= MAX_USER_BACKGROUNDS {
+		apiresponse.APIErrorResponse(403, "User not permitted to upload more backgrounds", &resp)
+		return resp, nil
+	}
+
+	backgroundID, err := videoutil.NewUserBackgroundID()
+	if err != nil {
+		log.Printf("Failed to create background ID: %v", err)
+		apiresponse.APIErrorResponse(500, "Internal Server Error", &resp)
+		return resp, nil
+	}
+	err = us.dynamoClient.CreateUserBackground(ctx, subject, backgroundID, requestBody.DisplayName)
+	if err != nil {
+		apiresponse.APIErrorResponse(500, "Failed to register background", &resp)
+		return resp, nil
+	}
+	uploadURL, err := us.s3Client.PresignUpload(ctx, BUCKET, videoutil.UserUploadKey(subject, backgroundID), requestBody.ContentType, UPLOAD_URL_EXPIRY)
+	if err != nil {
+		log.Printf("Failed to presign upload: %v", err)
+		apiresponse.APIErrorResponse(500, "Failed to create upload URL", &resp)
+		return resp, nil
+	}
+
+	apiresponse.APISuccessResponse(UploadResponse{
+		BackgroundID: backgroundID,
+		UploadURL:    uploadURL,
+		ContentType:  requestBody.ContentType,
+		ExpiresIn:    int(UPLOAD_URL_EXPIRY.Seconds()),
+	}, &resp)
+	return resp, nil
+}
+
+func main() {
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	awsSession, err := config.LoadDefaultConfig(
+		context.Background(),
+		config.WithRegion(region),
+	)
+	if err != nil {
+		fmt.Println("Failed to load AWS configuration:", err)
+		return
+	}
+	s3Client := s3client.NewS3Client(awsSession)
+	dynamoClient := dynamo.NewDynamoClient(awsSession)
+	us := NewUploadService(s3Client, dynamoClient)
+	us.isProd = os.Getenv("AWS_SAM_LOCAL") != "true"
+	lambda.Start(us.handler)
+}
@@ -4,6 +4,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"math"
+	"strconv"
+	"time"
 
 	"github.com/aws/aws-lambda-go/events"
 )
@@ -23,3 +26,12 @@ func APIErrorResponse(status int, message string, resp *events.APIGatewayProxyRe
 	resp.StatusCode = status
 	resp.Body = fmt.Sprintf(`{"message": "%s"}`, message)
 }
+
+// APIRateLimitResponse tells the client to wait retryAfter, rounded up to whole seconds, before trying again.
+func APIRateLimitResponse(retryAfter time.Duration, message string, resp *events.APIGatewayProxyResponse) {
+	APIErrorResponse(429, message, resp)
+	if resp.Headers == nil {
+		resp.Headers = make(map[string]string)
+	}
+	resp.Headers["Retry-After"] = strconv.Itoa(int(math.Ceil(retryAfter.Seconds())))
+}
@@ -10,7 +10,7 @@ import (
 )
 
 type CognitoMethods interface {
-	GetEmailFromUsername(username string) (string, error)
+	GetEmailFromUsername(ctx context.Context, username string) (string, error)
 }
 
 type CognitoClient struct {
@@ -23,11 +23,11 @@ func NewCognitoClient(awsSession aws.Config) CognitoMethods {
 	}
 }
 
-func (cc *CognitoClient) GetEmailFromUsername(username string) (string, error) {
+func (cc *CognitoClient) GetEmailFromUsername(ctx context.Context, username string) (string, error) {
 	if os.Getenv("COGNITO_POOL") == "" {
 		return "", fmt.Errorf("environment variable (COGNITO_POOL) not set")
 	}
-	userInfo, err := cc.client.AdminGetUser(context.Background(), &cognitoidentityprovider.AdminGetUserInput{
+	userInfo, err := cc.client.AdminGetUser(ctx, &cognitoidentityprovider.AdminGetUserInput{
 		Username:   aws.String(username),
 		UserPoolId: aws.String(os.Getenv("COGNITO_POOL")),
 	})
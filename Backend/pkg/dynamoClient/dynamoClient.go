@@ -2,7 +2,10 @@ package dynamo
 
 import (
 	"context"
+	"errors"
 	"log"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -13,21 +16,49 @@ import (
 
 type DynamoMethods interface {
 	// User modification methods
-	CreateUserIfNotExists(userID string) error
-	AddScheduledJobToUser(userID string, entryID string) error
-	DeregisterJobFromUser(userID string, entryID string) error
+	CreateUserIfNotExists(ctx context.Context, userID string) error
+	AddScheduledJobToUser(ctx context.Context, userID string, entryID string) error
+	DeregisterJobFromUser(ctx context.Context, userID string, entryID string) error
+	GetUser(ctx context.Context, userID string) (*UserDocument, error)
+	AddVideoRenderToUser(ctx context.Context, userID string) error
+	RefundVideoRender(ctx context.Context, userID string) error
+	TakeRateLimit(ctx context.Context, userID string, limit RateLimit) (*RateLimitEvent, time.Duration, error)
+	ReleaseRateLimit(ctx context.Context, userID string, limit RateLimit, event RateLimitEvent) error
 
 	// Job modification methods
-	CreateJobIfNotExists(entryID string, title string, generatedBy string) error
-	DeleteJobByUser(entryID string, userID string) error
-	GenerateSubtitles(entryID string, videoID string) error
-	AddVideoToJob(entryID string, videoID string) (*dynamodb.UpdateItemOutput, error)
-	RemoveVideoFromJob(entryID string, videoID string) error
-	GetJob(entryID string) (*JobDocument, error)
+	CreateJobIfNotExists(ctx context.Context, entryID string, title string, course string, generatedBy string, summaryStyle string, backgroundMusic string) error
+	DeleteJobByUser(ctx context.Context, entryID string, userID string) error
+	GenerateSubtitles(ctx context.Context, entryID string, videoID string) error
+	AddVideoToJob(ctx context.Context, entryID string, videoID string) (*dynamodb.UpdateItemOutput, error)
+	RemoveVideoFromJob(ctx context.Context, entryID string, videoID string) error
+	AddVideoPartsToJob(ctx context.Context, entryID string, videoID string, parts int) (*dynamodb.UpdateItemOutput, error)
+	RemoveVideoPartsFromJob(ctx context.Context, entryID string, videoID string) error
+	GetJob(ctx context.Context, entryID string) (*JobDocument, error)
+	RecordLectureRelease(ctx context.Context, entryID string, releasedOn time.Time) error
+	ScanJobs(ctx context.Context) ([]JobDocument, error)
+	AddPipelineStage(ctx context.Context, entryID string, name string, stage PipelineStage) error
+	UpdatePipelineStage(ctx context.Context, entryID string, name string, status string, detail string, from []string) error
 
 	// Video request methods
-	CreateVideoRequest(entryID string, requestedVideo string, requestedBy string) error
-	EntityVideoNumber(entryID string) (int, error)
+	CreateVideoRequest(ctx context.Context, entryID string, videoID string, options VideoOptions, requestedBy string) error
+	ReplaceFailedVideoRequest(ctx context.Context, entryID string, videoID string, options VideoOptions, requestedBy string) error
+	EntityVideoNumber(ctx context.Context, entryID string) (int, error)
+	GetVideoRequests(ctx context.Context, entryID string) ([]VideoRequestDocument, error)
+	GetVideoRequest(ctx context.Context, entryID string, videoID string) (*VideoRequestDocument, error)
+	DeleteVideoRequest(ctx context.Context, entryID string, videoID string, requestedBy string) error
+	ScanVideoRequests(ctx context.Context) ([]VideoRequestDocument, error)
+	UpdateVideoProgress(ctx context.Context, entryID string, videoID string, progress int) error
+	RecordVideoFailure(ctx context.Context, entryID string, videoID string, failure VideoFailure) error
+	RecordVideoPreviews(ctx context.Context, entryID string, videoID string, previews VideoPreviews) error
+	RecordVideoPriority(ctx context.Context, entryID string, videoID string, priority string, reason string) error
+	CountVideoRequestsSince(ctx context.Context, userID string, since time.Time) (int, error)
+
+	// User background methods
+	CreateUserBackground(ctx context.Context, userID string, backgroundID string, displayName string) error
+	GetUserBackground(ctx context.Context, userID string, backgroundID string) (*UserBackgroundDocument, error)
+	ListUserBackgrounds(ctx context.Context, userID string) ([]UserBackgroundDocument, error)
+	CompleteUserBackground(ctx context.Context, userID string, backgroundID string, s3Key string, duration float64, aspectRatio string) error
+	RejectUserBackground(ctx context.Context, userID string, backgroundID string, reason string) error
 }
 
 type DynamoClient struct {
@@ -40,19 +71,20 @@ func NewDynamoClient(awsSession aws.Config) DynamoMethods {
 	}
 }
 
-func (dc *DynamoClient) CreateUserIfNotExists(userID string) error {
+func (dc *DynamoClient) CreateUserIfNotExists(ctx context.Context, userID string) error {
 	userData, err := attributevalue.MarshalMap(
 		UserDocument{
 			UserID:               userID,
 			CreatedOn:            time.Now().Format("2006-01-02 15:04:05"),
 			PermittedGenerations: 50,
+			PermittedVideos:      DEFAULT_PERMITTED_VIDEOS,
 		},
 	)
 	if err != nil {
 		log.Println("Error marshalling user data: ", err)
 		return err
 	}
-	_, err = dc.client.PutItem(context.Background(), &dynamodb.PutItemInput{
+	_, err = dc.client.PutItem(ctx, &dynamodb.PutItemInput{
 		TableName:           aws.String("Users"),
 		Item:                userData,
 		ConditionExpression: aws.String("attribute_not_exists(userID)"),
@@ -64,8 +96,8 @@ func (dc *DynamoClient) CreateUserIfNotExists(userID string) error {
 	return nil
 }
 
-func (dc *DynamoClient) AddScheduledJobToUser(userID string, entryID string) error {
-	_, err := dc.client.UpdateItem(context.Background(), &dynamodb.UpdateItemInput{
+func (dc *DynamoClient) AddScheduledJobToUser(ctx context.Context, userID string, entryID string) error {
+	_, err := dc.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
 		TableName: aws.String("Users"),
 		Key: map[string]types.AttributeValue{
 			"userID": &types.AttributeValueMemberS{
@@ -90,8 +122,212 @@ func (dc *DynamoClient) AddScheduledJobToUser(userID string, entryID string) err
 	return nil
 }
 
-func (dc *DynamoClient) DeregisterJobFromUser(userID string, entryID string) error {
-	_, err := dc.client.UpdateItem(context.Background(), &dynamodb.UpdateItemInput{
+// AddVideoRenderToUser counts a video against the user's quota, a ConditionalCheckFailedException means it is used up.
+func (dc *DynamoClient) AddVideoRenderToUser(ctx context.Context, userID string) error {
+	_, err := dc.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String("Users"),
+		Key: map[string]types.AttributeValue{
+			"userID": &types.AttributeValueMemberS{
+				Value: userID,
+			},
+		},
+		UpdateExpression: aws.String("ADD videoRenders :one"),
+		ConditionExpression: aws.String("attribute_exists(userID) AND (" +
+			"(attribute_not_exists(permittedVideos) AND (attribute_not_exists(videoRenders) OR videoRenders < :default)) OR " +
+			"(attribute_not_exists(videoRenders) AND permittedVideos > :zero) OR " +
+			"videoRenders < permittedVideos)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":one": &types.AttributeValueMemberN{
+				Value: "1",
+			},
+			":zero": &types.AttributeValueMemberN{
+				Value: "0",
+			},
+			":default": &types.AttributeValueMemberN{
+				Value: strconv.Itoa(DEFAULT_PERMITTED_VIDEOS),
+			},
+		},
+	})
+	if err != nil {
+		log.Println("Error updating user data: ", err)
+		return err
+	}
+	return nil
+}
+
+// RefundVideoRender gives back a video counted by AddVideoRenderToUser that was never made.
+func (dc *DynamoClient) RefundVideoRender(ctx context.Context, userID string) error {
+	_, err := dc.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String("Users"),
+		Key: map[string]types.AttributeValue{
+			"userID": &types.AttributeValueMemberS{
+				Value: userID,
+			},
+		},
+		UpdateExpression:    aws.String("ADD videoRenders :minusOne"),
+		ConditionExpression: aws.String("videoRenders > :zero"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":minusOne": &types.AttributeValueMemberN{
+				Value: "-1",
+			},
+			":zero": &types.AttributeValueMemberN{
+				Value: "0",
+			},
+		},
+	})
+	if err != nil {
+		log.Println("Error updating user data: ", err)
+		return err
+	}
+	return nil
+}
+
+func rateLimitKey(userID string, limit RateLimit) string {
+	return userID + "#" + limit.Name
+}
+
+// rateLimitSlots returns the limit's taken slots, slots never taken or already deleted by the TTL are missing.
+func (dc *DynamoClient) rateLimitSlots(ctx context.Context, limitKey string) ([]RateLimitEvent, error) {
+	result, err := dc.client.Query(ctx, &dynamodb.QueryInput{
+		TableName: aws.String("RateLimits"),
+		KeyConditions: map[string]types.Condition{
+			"limitKey": {
+				ComparisonOperator: types.ComparisonOperatorEq,
+				AttributeValueList: []types.AttributeValue{
+					&types.AttributeValueMemberS{
+						Value: limitKey,
+					},
+				},
+			},
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		log.Println("Error querying rate limit: ", err)
+		return nil, err
+	}
+	var events []RateLimitEvent
+	err = attributevalue.UnmarshalListOfMaps(result.Items, &events)
+	if err != nil {
+		log.Println("Error unmarshalling rate limit events: ", err)
+		return nil, err
+	}
+	return events, nil
+}
+
+// freeRateLimitSlot returns the first slot that was never taken or whose event has left the window. When every slot
+// is held it returns how long until the oldest event leaves the window instead, at least a second.
+func freeRateLimitSlot(events []RateLimitEvent, limit RateLimit, now time.Time) (int, time.Duration) {
+	cutoff := now.Add(-limit.Window).UnixMilli()
+	taken := make(map[int]int64, len(events))
+	for _, event := range events {
+		taken[event.Slot] = event.At
+	}
+	oldest := now.UnixMilli()
+	for slot := range limit.Limit {
+		at, ok := taken[slot]
+		if !ok || at <= cutoff {
+			return slot, 0
+		}
+		oldest = min(oldest, at)
+	}
+	return -1, max(time.UnixMilli(oldest).Add(limit.Window).Sub(now), time.Second)
+}
+
+// TakeRateLimit counts an event against a limit if it allows one, otherwise it returns how long the user has to wait.
+// The event is written on the condition that its slot is still free, so requests made together can't go over the limit.
+func (dc *DynamoClient) TakeRateLimit(ctx context.Context, userID string, limit RateLimit) (*RateLimitEvent, time.Duration, error) {
+	limitKey := rateLimitKey(userID, limit)
+	for range RATE_LIMIT_ATTEMPTS {
+		now := time.Now()
+		cutoff := now.Add(-limit.Window).UnixMilli()
+		// Step 1: Find a slot that was never taken or whose event has left the window
+		events, err := dc.rateLimitSlots(ctx, limitKey)
+		if err != nil {
+			return nil, 0, err
+		}
+		slot, retryAfter := freeRateLimitSlot(events, limit, now)
+		if retryAfter > 0 {
+			return nil, retryAfter, nil
+		}
+
+		// Step 2: Take the slot unless another request took it first
+		event := RateLimitEvent{Slot: slot, At: now.UnixMilli()}
+		_, err = dc.client.PutItem(ctx, &dynamodb.PutItemInput{
+			TableName: aws.String("RateLimits"),
+			Item: map[string]types.AttributeValue{
+				"limitKey": &types.AttributeValueMemberS{
+					Value: limitKey,
+				},
+				"slot": &types.AttributeValueMemberN{
+					Value: strconv.Itoa(event.Slot),
+				},
+				"at": &types.AttributeValueMemberN{
+					Value: strconv.FormatInt(event.At, 10),
+				},
+				// Events are deleted by TTL once they have left the window
+				"expiry": &types.AttributeValueMemberN{
+					Value: strconv.FormatInt(now.Add(limit.Window).Unix(), 10),
+				},
+			},
+			ConditionExpression: aws.String("attribute_not_exists(limitKey) OR #at <= :cutoff"),
+			ExpressionAttributeNames: map[string]string{
+				"#at": "at",
+			},
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":cutoff": &types.AttributeValueMemberN{
+					Value: strconv.FormatInt(cutoff, 10),
+				},
+			},
+		})
+		if err == nil {
+			return &event, 0, nil
+		}
+		var ccfe *types.ConditionalCheckFailedException
+		if !errors.As(err, &ccfe) {
+			log.Println("Error recording rate limit event: ", err)
+			return nil, 0, err
+		}
+	}
+	// Requests made together kept taking the free slots first, the limit is as good as used up
+	return nil, time.Second, nil
+}
+
+// ReleaseRateLimit gives back an event taken by TakeRateLimit, a slot that has been taken again since is left alone.
+func (dc *DynamoClient) ReleaseRateLimit(ctx context.Context, userID string, limit RateLimit, event RateLimitEvent) error {
+	_, err := dc.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String("RateLimits"),
+		Key: map[string]types.AttributeValue{
+			"limitKey": &types.AttributeValueMemberS{
+				Value: rateLimitKey(userID, limit),
+			},
+			"slot": &types.AttributeValueMemberN{
+				Value: strconv.Itoa(event.Slot),
+			},
+		},
+		ConditionExpression: aws.String("#at = :at"),
+		ExpressionAttributeNames: map[string]string{
+			"#at": "at",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":at": &types.AttributeValueMemberN{
+				Value: strconv.FormatInt(event.At, 10),
+			},
+		},
+	})
+	if err != nil {
+		var ccfe *types.ConditionalCheckFailedException
+		if errors.As(err, &ccfe) {
+			return nil
+		}
+		log.Println("Error releasing rate limit event: ", err)
+		return err
+	}
+	return nil
+}
+
+func (dc *DynamoClient) DeregisterJobFromUser(ctx context.Context, userID string, entryID string) error {
+	_, err := dc.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
 		TableName: aws.String("Users"),
 		Key: map[string]types.AttributeValue{
 			"userID": &types.AttributeValueMemberS{
@@ -113,21 +349,24 @@ func (dc *DynamoClient) DeregisterJobFromUser(userID string, entryID string) err
 	return nil
 }
 
-func (dc *DynamoClient) CreateJobIfNotExists(entryID string, title string, generatedBy string) error {
+func (dc *DynamoClient) CreateJobIfNotExists(ctx context.Context, entryID string, title string, course string, generatedBy string, summaryStyle string, backgroundMusic string) error {
 	jobData, err := attributevalue.MarshalMap(
 		JobDocument{
 			EntryID:            entryID,
 			Title:              title,
+			Course:             course,
 			GeneratedOn:        time.Now().Format("2006-01-02 15:04:05"),
 			GeneratedBy:        generatedBy,
 			SubtitlesGenerated: false,
+			SummaryStyle:       summaryStyle,
+			BackgroundMusic:    backgroundMusic,
 		},
 	)
 	if err != nil {
 		log.Println("Error marshalling job data: ", err)
 		return err
 	}
-	_, err = dc.client.PutItem(context.Background(), &dynamodb.PutItemInput{
+	_, err = dc.client.PutItem(ctx, &dynamodb.PutItemInput{
 		TableName:           aws.String("Jobs"),
 		Item:                jobData,
 		ConditionExpression: aws.String("attribute_not_exists(entryID)"),
@@ -139,8 +378,8 @@ func (dc *DynamoClient) CreateJobIfNotExists(entryID string, title string, gener
 	return nil
 }
 
-func (dc *DynamoClient) DeleteJobByUser(entryID string, userID string) error {
-	_, err := dc.client.DeleteItem(context.Background(), &dynamodb.DeleteItemInput{
+func (dc *DynamoClient) DeleteJobByUser(ctx context.Context, entryID string, userID string) error {
+	_, err := dc.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
 		TableName: aws.String("Jobs"),
 		Key: map[string]types.AttributeValue{
 			"entryID": &types.AttributeValueMemberS{
@@ -161,8 +400,8 @@ func (dc *DynamoClient) DeleteJobByUser(entryID string, userID string) error {
 	return nil
 }
 
-func (dc *DynamoClient) GenerateSubtitles(entryID string, videoID string) error {
-	_, err := dc.client.UpdateItem(context.Background(), &dynamodb.UpdateItemInput{
+func (dc *DynamoClient) GenerateSubtitles(ctx context.Context, entryID string, videoID string) error {
+	_, err := dc.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
 		TableName: aws.String("Jobs"),
 		Key: map[string]types.AttributeValue{
 			"entryID": &types.AttributeValueMemberS{
@@ -184,8 +423,8 @@ func (dc *DynamoClient) GenerateSubtitles(entryID string, videoID string) error
 	return nil
 }
 
-func (dc *DynamoClient) AddVideoToJob(entryID string, videoID string) (*dynamodb.UpdateItemOutput, error) {
-	update, err := dc.client.UpdateItem(context.Background(), &dynamodb.UpdateItemInput{
+func (dc *DynamoClient) AddVideoToJob(ctx context.Context, entryID string, videoID string) (*dynamodb.UpdateItemOutput, error) {
+	update, err := dc.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
 		TableName: aws.String("Jobs"),
 		Key: map[string]types.AttributeValue{
 			"entryID": &types.AttributeValueMemberS{
@@ -208,8 +447,8 @@ func (dc *DynamoClient) AddVideoToJob(entryID string, videoID string) (*dynamodb
 	return update, nil
 }
 
-func (dc *DynamoClient) RemoveVideoFromJob(entryID string, videoID string) error {
-	_, err := dc.client.UpdateItem(context.Background(), &dynamodb.UpdateItemInput{
+func (dc *DynamoClient) RemoveVideoFromJob(ctx context.Context, entryID string, videoID string) error {
+	_, err := dc.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
 		TableName: aws.String("Jobs"),
 		Key: map[string]types.AttributeValue{
 			"entryID": &types.AttributeValueMemberS{
@@ -231,8 +470,207 @@ func (dc *DynamoClient) RemoveVideoFromJob(entryID string, videoID string) error
 	return nil
 }
 
-func (dc *DynamoClient) GetJob(entryID string) (*JobDocument, error) {
-	result, err := dc.client.GetItem(context.Background(), &dynamodb.GetItemInput{
+// AddVideoPartsToJob lists a video that was split into parts by how many parts it has. The video has no file of its
+// own so it is left out of videosAvailable.
+func (dc *DynamoClient) AddVideoPartsToJob(ctx context.Context, entryID string, videoID string, parts int) (*dynamodb.UpdateItemOutput, error) {
+	// Step 1: A nested attribute can only be set once the map holding it exists
+	_, err := dc.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String("Jobs"),
+		Key: map[string]types.AttributeValue{
+			"entryID": &types.AttributeValueMemberS{
+				Value: entryID,
+			},
+		},
+		UpdateExpression:    aws.String("SET videoParts = if_not_exists(videoParts, :empty)"),
+		ConditionExpression: aws.String("attribute_exists(entryID)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":empty": &types.AttributeValueMemberM{
+				Value: map[string]types.AttributeValue{},
+			},
+		},
+	})
+	if err != nil {
+		log.Printf("Error updating job data: %v", err)
+		return nil, err
+	}
+	// Step 2: List the video's parts
+	update, err := dc.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String("Jobs"),
+		Key: map[string]types.AttributeValue{
+			"entryID": &types.AttributeValueMemberS{
+				Value: entryID,
+			},
+		},
+		UpdateExpression:    aws.String("SET videoParts.#videoID = :parts"),
+		ConditionExpression: aws.String("attribute_exists(entryID)"),
+		ExpressionAttributeNames: map[string]string{
+			"#videoID": videoID,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":parts": &types.AttributeValueMemberN{
+				Value: strconv.Itoa(parts),
+			},
+		},
+		ReturnValues: types.ReturnValueAllNew,
+	})
+	if err != nil {
+		log.Printf("Error updating job data: %v", err)
+		return nil, err
+	}
+	return update, nil
+}
+
+// RemoveVideoPartsFromJob removes a video's part count from the job.
+func (dc *DynamoClient) RemoveVideoPartsFromJob(ctx context.Context, entryID string, videoID string) error {
+	_, err := dc.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String("Jobs"),
+		Key: map[string]types.AttributeValue{
+			"entryID": &types.AttributeValueMemberS{
+				Value: entryID,
+			},
+		},
+		UpdateExpression: aws.String("REMOVE videoParts.#videoID"),
+		// Videos that never finished were never given a part count
+		ConditionExpression: aws.String("attribute_exists(videoParts)"),
+		ExpressionAttributeNames: map[string]string{
+			"#videoID": videoID,
+		},
+	})
+	if err != nil {
+		var ccfe *types.ConditionalCheckFailedException
+		if errors.As(err, &ccfe) {
+			return nil
+		}
+		log.Printf("Error updating job data: %v", err)
+		return err
+	}
+	return nil
+}
+
+// AddPipelineStage adds a stage to the job's pipeline, a stage that is already there is left as it is.
+func (dc *DynamoClient) AddPipelineStage(ctx context.Context, entryID string, name string, stage PipelineStage) error {
+	stageData, err := attributevalue.Marshal(stage)
+	if err != nil {
+		log.Println("Error marshalling pipeline stage: ", err)
+		return err
+	}
+	// Step 1: A nested attribute can only be set once the map holding it exists
+	_, err = dc.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String("Jobs"),
+		Key: map[string]types.AttributeValue{
+			"entryID": &types.AttributeValueMemberS{
+				Value: entryID,
+			},
+		},
+		UpdateExpression:    aws.String("SET pipeline = if_not_exists(pipeline, :empty)"),
+		ConditionExpression: aws.String("attribute_exists(entryID)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":empty": &types.AttributeValueMemberM{
+				Value: map[string]types.AttributeValue{},
+			},
+		},
+	})
+	if err != nil {
+		log.Printf("Error updating job data: %v", err)
+		return err
+	}
+	// Step 2: Add the stage
+	_, err = dc.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String("Jobs"),
+		Key: map[string]types.AttributeValue{
+			"entryID": &types.AttributeValueMemberS{
+				Value: entryID,
+			},
+		},
+		UpdateExpression:    aws.String("SET pipeline.#stage = if_not_exists(pipeline.#stage, :stage)"),
+		ConditionExpression: aws.String("attribute_exists(entryID)"),
+		ExpressionAttributeNames: map[string]string{
+			"#stage": name,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":stage": stageData,
+		},
+	})
+	if err != nil {
+		log.Printf("Error updating job data: %v", err)
+		return err
+	}
+	return nil
+}
+
+// pipelineStageUpdate builds the expressions that move a stage. DynamoDB rejects names and values the expressions
+// don't use, so each one is only added alongside the clause that needs it.
+func pipelineStageUpdate(name string, status string, detail string, from []string, now time.Time) (string, string, map[string]string, map[string]types.AttributeValue) {
+	names := map[string]string{
+		"#stage":     name,
+		"#status":    "status",
+		"#error":     "error",
+		"#updatedOn": "updatedOn",
+	}
+	values := map[string]types.AttributeValue{
+		":status": &types.AttributeValueMemberS{
+			Value: status,
+		},
+		":now": &types.AttributeValueMemberS{
+			Value: now.Format(DATE_FORMAT),
+		},
+	}
+	update := "SET pipeline.#stage.#status = :status, pipeline.#stage.#updatedOn = :now"
+	if detail != "" {
+		update += ", pipeline.#stage.#error = :detail"
+		values[":detail"] = &types.AttributeValueMemberS{
+			Value: detail,
+		}
+	}
+	if status == StageStatusRunning {
+		update += " ADD pipeline.#stage.#attempts :one"
+		names["#attempts"] = "attempts"
+		values[":one"] = &types.AttributeValueMemberN{
+			Value: "1",
+		}
+	}
+	if detail == "" {
+		update += " REMOVE pipeline.#stage.#error"
+	}
+	condition := "attribute_exists(pipeline.#stage)"
+	if len(from) > 0 {
+		placeholders := make([]string, len(from))
+		for i, fromStatus := range from {
+			placeholders[i] = ":from" + strconv.Itoa(i)
+			values[placeholders[i]] = &types.AttributeValueMemberS{
+				Value: fromStatus,
+			}
+		}
+		condition = "pipeline.#stage.#status IN (" + strings.Join(placeholders, ", ") + ")"
+	}
+	return update, condition, names, values
+}
+
+// UpdatePipelineStage moves a stage to status, recording detail as its error when not empty. The stage must be in
+// one of the from statuses (any status if from is empty), a ConditionalCheckFailedException means it was not.
+func (dc *DynamoClient) UpdatePipelineStage(ctx context.Context, entryID string, name string, status string, detail string, from []string) error {
+	update, condition, names, values := pipelineStageUpdate(name, status, detail, from, time.Now())
+	_, err := dc.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String("Jobs"),
+		Key: map[string]types.AttributeValue{
+			"entryID": &types.AttributeValueMemberS{
+				Value: entryID,
+			},
+		},
+		UpdateExpression:          aws.String(update),
+		ConditionExpression:       aws.String(condition),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+	})
+	if err != nil {
+		log.Printf("Error updating pipeline stage %s: %v", name, err)
+		return err
+	}
+	return nil
+}
+
+func (dc *DynamoClient) GetJob(ctx context.Context, entryID string) (*JobDocument, error) {
+	result, err := dc.client.GetItem(ctx, &dynamodb.GetItemInput{
 		TableName: aws.String("Jobs"),
 		Key: map[string]types.AttributeValue{
 			"entryID": &types.AttributeValueMemberS{
@@ -256,11 +694,37 @@ func (dc *DynamoClient) GetJob(entryID string) (*JobDocument, error) {
 	return &job, nil
 }
 
-func (dc *DynamoClient) CreateVideoRequest(entryID string, requestedVideo string, requestedBy string) error {
+// RecordLectureRelease stores when the lecture was published, which the queue uses to prioritize new lectures.
+func (dc *DynamoClient) RecordLectureRelease(ctx context.Context, entryID string, releasedOn time.Time) error {
+	_, err := dc.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String("Jobs"),
+		Key: map[string]types.AttributeValue{
+			"entryID": &types.AttributeValueMemberS{
+				Value: entryID,
+			},
+		},
+		UpdateExpression:    aws.String("SET releasedOn = :releasedOn"),
+		ConditionExpression: aws.String("attribute_exists(entryID)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":releasedOn": &types.AttributeValueMemberS{
+				Value: releasedOn.Format(DATE_FORMAT),
+			},
+		},
+	})
+	if err != nil {
+		log.Printf("Error recording lecture release: %v", err)
+		return err
+	}
+	return nil
+}
+
+// newVideoRequestItem builds a fresh video request row, made by the caller just now.
+func newVideoRequestItem(entryID string, videoID string, options VideoOptions, requestedBy string) (map[string]types.AttributeValue, error) {
 	videoRequestData, err := attributevalue.MarshalMap(
 		VideoRequestDocument{
 			EntryID:        entryID,
-			RequestedVideo: requestedVideo,
+			RequestedVideo: videoID,
+			VideoOptions:   options,
 			RequestedOn:    time.Now().Format("2006-01-02 15:04:05"),
 			RequestedBy:    requestedBy,
 			VideoExpiry:    int(time.Now().Add(time.Hour * 24 * 30).Unix()),
@@ -268,9 +732,17 @@ func (dc *DynamoClient) CreateVideoRequest(entryID string, requestedVideo string
 	)
 	if err != nil {
 		log.Println("Error marshalling video request data: ", err)
+		return nil, err
+	}
+	return videoRequestData, nil
+}
+
+func (dc *DynamoClient) CreateVideoRequest(ctx context.Context, entryID string, videoID string, options VideoOptions, requestedBy string) error {
+	videoRequestData, err := newVideoRequestItem(entryID, videoID, options, requestedBy)
+	if err != nil {
 		return err
 	}
-	_, err = dc.client.PutItem(context.Background(), &dynamodb.PutItemInput{
+	_, err = dc.client.PutItem(ctx, &dynamodb.PutItemInput{
 		TableName:           aws.String("VideoRequests"),
 		Item:                videoRequestData,
 		ConditionExpression: aws.String("attribute_not_exists(entryID) AND attribute_not_exists(requestedVideo)"),
@@ -282,8 +754,34 @@ func (dc *DynamoClient) CreateVideoRequest(entryID string, requestedVideo string
 	return nil
 }
 
-func (dc *DynamoClient) EntityVideoNumber(entryID string) (int, error) {
-	result, err := dc.client.Query(context.Background(), &dynamodb.QueryInput{
+// ReplaceFailedVideoRequest requests a video again once its last attempt failed for good. The old request is
+// overwritten by a new one in a single conditional put, so the row is never lost, and the stream sees a MODIFY
+// that drops the failure and queues it. A ConditionalCheckFailedException means the video exists or is still
+// being worked on.
+func (dc *DynamoClient) ReplaceFailedVideoRequest(ctx context.Context, entryID string, videoID string, options VideoOptions, requestedBy string) error {
+	videoRequestData, err := newVideoRequestItem(entryID, videoID, options, requestedBy)
+	if err != nil {
+		return err
+	}
+	_, err = dc.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String("VideoRequests"),
+		Item:                videoRequestData,
+		ConditionExpression: aws.String("failure.retryable = :false"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":false": &types.AttributeValueMemberBOOL{
+				Value: false,
+			},
+		},
+	})
+	if err != nil {
+		log.Println("Error replacing failed video request: ", err)
+		return err
+	}
+	return nil
+}
+
+func (dc *DynamoClient) EntityVideoNumber(ctx context.Context, entryID string) (int, error) {
+	result, err := dc.client.Query(ctx, &dynamodb.QueryInput{
 		TableName: aws.String("VideoRequests"),
 		KeyConditions: map[string]types.Condition{
 			"entryID": {
@@ -302,3 +800,470 @@ func (dc *DynamoClient) EntityVideoNumber(entryID string) (int, error) {
 	}
 	return len(result.Items), nil
 }
+
+func (dc *DynamoClient) GetVideoRequests(ctx context.Context, entryID string) ([]VideoRequestDocument, error) {
+	result, err := dc.client.Query(ctx, &dynamodb.QueryInput{
+		TableName: aws.String("VideoRequests"),
+		KeyConditions: map[string]types.Condition{
+			"entryID": {
+				ComparisonOperator: types.ComparisonOperatorEq,
+				AttributeValueList: []types.AttributeValue{
+					&types.AttributeValueMemberS{
+						Value: entryID,
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		log.Println("Error querying video requests: ", err)
+		return nil, err
+	}
+	var videoRequests []VideoRequestDocument
+	err = attributevalue.UnmarshalListOfMaps(result.Items, &videoRequests)
+	if err != nil {
+		log.Println("Error unmarshalling video requests: ", err)
+		return nil, err
+	}
+	return videoRequests, nil
+}
+
+// GetVideoRequest returns a single video request, or nil if it was never made or has been removed.
+func (dc *DynamoClient) GetVideoRequest(ctx context.Context, entryID string, videoID string) (*VideoRequestDocument, error) {
+	result, err := dc.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String("VideoRequests"),
+		Key: map[string]types.AttributeValue{
+			"entryID": &types.AttributeValueMemberS{
+				Value: entryID,
+			},
+			"requestedVideo": &types.AttributeValueMemberS{
+				Value: videoID,
+			},
+		},
+	})
+	if err != nil {
+		log.Println("Error getting video request: ", err)
+		return nil, err
+	}
+	if result.Item == nil {
+		return nil, nil
+	}
+	var videoRequest VideoRequestDocument
+	err = attributevalue.UnmarshalMap(result.Item, &videoRequest)
+	if err != nil {
+		log.Println("Error unmarshalling video request: ", err)
+		return nil, err
+	}
+	return &videoRequest, nil
+}
+
+// DeleteVideoRequest removes a video request on behalf of the user who made it, a ConditionalCheckFailedException
+// means the request is gone or belongs to someone else.
+func (dc *DynamoClient) DeleteVideoRequest(ctx context.Context, entryID string, videoID string, requestedBy string) error {
+	_, err := dc.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String("VideoRequests"),
+		Key: map[string]types.AttributeValue{
+			"entryID": &types.AttributeValueMemberS{
+				Value: entryID,
+			},
+			"requestedVideo": &types.AttributeValueMemberS{
+				Value: videoID,
+			},
+		},
+		ConditionExpression: aws.String("requestedBy = :requestedBy"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":requestedBy": &types.AttributeValueMemberS{
+				Value: requestedBy,
+			},
+		},
+	})
+	if err != nil {
+		log.Println("Error deleting video request: ", err)
+		return err
+	}
+	return nil
+}
+
+func (dc *DynamoClient) UpdateVideoProgress(ctx context.Context, entryID string, videoID string, progress int) error {
+	_, err := dc.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String("VideoRequests"),
+		Key: map[string]types.AttributeValue{
+			"entryID": &types.AttributeValueMemberS{
+				Value: entryID,
+			},
+			"requestedVideo": &types.AttributeValueMemberS{
+				Value: videoID,
+			},
+		},
+		UpdateExpression:    aws.String("SET progress = :progress REMOVE failure"),
+		ConditionExpression: aws.String("attribute_exists(entryID)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":progress": &types.AttributeValueMemberN{
+				Value: strconv.Itoa(progress),
+			},
+		},
+	})
+	if err != nil {
+		log.Printf("Error updating video request progress: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (dc *DynamoClient) RecordVideoFailure(ctx context.Context, entryID string, videoID string, failure VideoFailure) error {
+	failureData, err := attributevalue.Marshal(failure)
+	if err != nil {
+		log.Println("Error marshalling video failure: ", err)
+		return err
+	}
+	_, err = dc.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String("VideoRequests"),
+		Key: map[string]types.AttributeValue{
+			"entryID": &types.AttributeValueMemberS{
+				Value: entryID,
+			},
+			"requestedVideo": &types.AttributeValueMemberS{
+				Value: videoID,
+			},
+		},
+		UpdateExpression:    aws.String("SET failure = :failure"),
+		ConditionExpression: aws.String("attribute_exists(entryID)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":failure": failureData,
+		},
+	})
+	if err != nil {
+		log.Printf("Error recording video failure: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (dc *DynamoClient) CreateUserBackground(ctx context.Context, userID string, backgroundID string, displayName string) error {
+	backgroundData, err := attributevalue.MarshalMap(
+		UserBackgroundDocument{
+			UserID:       userID,
+			BackgroundID: backgroundID,
+			DisplayName:  displayName,
+			Status:       BackgroundStatusPending,
+			CreatedOn:    time.Now().Format("2006-01-02 15:04:05"),
+		},
+	)
+	if err != nil {
+		log.Println("Error marshalling user background data: ", err)
+		return err
+	}
+	_, err = dc.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String("UserBackgrounds"),
+		Item:                backgroundData,
+		ConditionExpression: aws.String("attribute_not_exists(userID) AND attribute_not_exists(backgroundID)"),
+	})
+	if err != nil {
+		log.Println("Error putting user background data: ", err)
+		return err
+	}
+	return nil
+}
+
+func (dc *DynamoClient) GetUserBackground(ctx context.Context, userID string, backgroundID string) (*UserBackgroundDocument, error) {
+	result, err := dc.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String("UserBackgrounds"),
+		Key: map[string]types.AttributeValue{
+			"userID": &types.AttributeValueMemberS{
+				Value: userID,
+			},
+			"backgroundID": &types.AttributeValueMemberS{
+				Value: backgroundID,
+			},
+		},
+	})
+	if err != nil {
+		log.Println("Error getting user background data: ", err)
+		return nil, err
+	}
+	if result.Item == nil {
+		return nil, nil
+	}
+	var background UserBackgroundDocument
+	err = attributevalue.UnmarshalMap(result.Item, &background)
+	if err != nil {
+		log.Println("Error unmarshalling user background data: ", err)
+		return nil, err
+	}
+	return &background, nil
+}
+
+func (dc *DynamoClient) ListUserBackgrounds(ctx context.Context, userID string) ([]UserBackgroundDocument, error) {
+	result, err := dc.client.Query(ctx, &dynamodb.QueryInput{
+		TableName: aws.String("UserBackgrounds"),
+		KeyConditions: map[string]types.Condition{
+			"userID": {
+				ComparisonOperator: types.ComparisonOperatorEq,
+				AttributeValueList: []types.AttributeValue{
+					&types.AttributeValueMemberS{
+						Value: userID,
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		log.Println("Error querying user backgrounds: ", err)
+		return nil, err
+	}
+	var backgrounds []UserBackgroundDocument
+	err = attributevalue.UnmarshalListOfMaps(result.Items, &backgrounds)
+	if err != nil {
+		log.Println("Error unmarshalling user backgrounds: ", err)
+		return nil, err
+	}
+	return backgrounds, nil
+}
+
+func (dc *DynamoClient) CompleteUserBackground(ctx context.Context, userID string, backgroundID string, s3Key string, duration float64, aspectRatio string) error {
+	_, err := dc.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String("UserBackgrounds"),
+		Key: map[string]types.AttributeValue{
+			"userID": &types.AttributeValueMemberS{
+				Value: userID,
+			},
+			"backgroundID": &types.AttributeValueMemberS{
+				Value: backgroundID,
+			},
+		},
+		UpdateExpression:    aws.String("SET #status = :ready, s3Key = :s3Key, #duration = :duration, aspectRatio = :aspectRatio"),
+		ConditionExpression: aws.String("#status = :pending"),
+		ExpressionAttributeNames: map[string]string{
+			"#status":   "status",
+			"#duration": "duration",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":ready": &types.AttributeValueMemberS{
+				Value: BackgroundStatusReady,
+			},
+			":pending": &types.AttributeValueMemberS{
+				Value: BackgroundStatusPending,
+			},
+			":s3Key": &types.AttributeValueMemberS{
+				Value: s3Key,
+			},
+			":duration": &types.AttributeValueMemberN{
+				Value: strconv.FormatFloat(duration, 'f', 3, 64),
+			},
+			":aspectRatio": &types.AttributeValueMemberS{
+				Value: aspectRatio,
+			},
+		},
+	})
+	if err != nil {
+		log.Printf("Error updating user background data: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (dc *DynamoClient) RejectUserBackground(ctx context.Context, userID string, backgroundID string, reason string) error {
+	_, err := dc.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String("UserBackgrounds"),
+		Key: map[string]types.AttributeValue{
+			"userID": &types.AttributeValueMemberS{
+				Value: userID,
+			},
+			"backgroundID": &types.AttributeValueMemberS{
+				Value: backgroundID,
+			},
+		},
+		UpdateExpression:    aws.String("SET #status = :rejected, rejectReason = :reason"),
+		ConditionExpression: aws.String("#status = :pending"),
+		ExpressionAttributeNames: map[string]string{
+			"#status": "status",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":rejected": &types.AttributeValueMemberS{
+				Value: BackgroundStatusRejected,
+			},
+			":pending": &types.AttributeValueMemberS{
+				Value: BackgroundStatusPending,
+			},
+			":reason": &types.AttributeValueMemberS{
+				Value: reason,
+			},
+		},
+	})
+	if err != nil {
+		log.Printf("Error updating user background data: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (dc *DynamoClient) RecordVideoPreviews(ctx context.Context, entryID string, videoID string, previews VideoPreviews) error {
+	previewData, err := attributevalue.Marshal(previews)
+	if err != nil {
+		log.Println("Error marshalling video previews: ", err)
+		return err
+	}
+	_, err = dc.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String("VideoRequests"),
+		Key: map[string]types.AttributeValue{
+			"entryID": &types.AttributeValueMemberS{
+				Value: entryID,
+			},
+			"requestedVideo": &types.AttributeValueMemberS{
+				Value: videoID,
+			},
+		},
+		UpdateExpression:    aws.String("SET previews = :previews"),
+		ConditionExpression: aws.String("attribute_exists(entryID)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":previews": previewData,
+		},
+	})
+	if err != nil {
+		log.Printf("Error recording video previews: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (dc *DynamoClient) GetUser(ctx context.Context, userID string) (*UserDocument, error) {
+	result, err := dc.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String("Users"),
+		Key: map[string]types.AttributeValue{
+			"userID": &types.AttributeValueMemberS{
+				Value: userID,
+			},
+		},
+	})
+	if err != nil {
+		log.Println("Error getting user data: ", err)
+		return nil, err
+	}
+	if result.Item == nil {
+		return nil, nil
+	}
+	var user UserDocument
+	err = attributevalue.UnmarshalMap(result.Item, &user)
+	if err != nil {
+		log.Println("Error unmarshalling user data: ", err)
+		return nil, err
+	}
+	return &user, nil
+}
+
+// RecordVideoPriority stores which queue a video request was sent to and why.
+func (dc *DynamoClient) RecordVideoPriority(ctx context.Context, entryID string, videoID string, priority string, reason string) error {
+	_, err := dc.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String("VideoRequests"),
+		Key: map[string]types.AttributeValue{
+			"entryID": &types.AttributeValueMemberS{
+				Value: entryID,
+			},
+			"requestedVideo": &types.AttributeValueMemberS{
+				Value: videoID,
+			},
+		},
+		UpdateExpression:    aws.String("SET priority = :priority, priorityReason = :reason"),
+		ConditionExpression: aws.String("attribute_exists(entryID)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":priority": &types.AttributeValueMemberS{
+				Value: priority,
+			},
+			":reason": &types.AttributeValueMemberS{
+				Value: reason,
+			},
+		},
+	})
+	if err != nil {
+		log.Printf("Error recording video priority: %v", err)
+		return err
+	}
+	return nil
+}
+
+// CountVideoRequestsSince counts the videos a user has requested since the given time, using the requestedBy index.
+func (dc *DynamoClient) CountVideoRequestsSince(ctx context.Context, userID string, since time.Time) (int, error) {
+	count := 0
+	var startKey map[string]types.AttributeValue
+	for {
+		result, err := dc.client.Query(ctx, &dynamodb.QueryInput{
+			TableName: aws.String("VideoRequests"),
+			IndexName: aws.String("requestedBy-index"),
+			KeyConditions: map[string]types.Condition{
+				"requestedBy": {
+					ComparisonOperator: types.ComparisonOperatorEq,
+					AttributeValueList: []types.AttributeValue{
+						&types.AttributeValueMemberS{
+							Value: userID,
+						},
+					},
+				},
+				"requestedOn": {
+					ComparisonOperator: types.ComparisonOperatorGe,
+					AttributeValueList: []types.AttributeValue{
+						&types.AttributeValueMemberS{
+							Value: since.Format(DATE_FORMAT),
+						},
+					},
+				},
+			},
+			Select:            types.SelectCount,
+			ExclusiveStartKey: startKey,
+		})
+		if err != nil {
+			log.Println("Error counting video requests: ", err)
+			return 0, err
+		}
+		count += int(result.Count)
+		if len(result.LastEvaluatedKey) == 0 {
+			return count, nil
+		}
+		startKey = result.LastEvaluatedKey
+	}
+}
+
+// ScanJobs reads every job, it is only meant for maintenance tasks.
+func (dc *DynamoClient) ScanJobs(ctx context.Context) ([]JobDocument, error) {
+	var jobs []JobDocument
+	paginator := dynamodb.NewScanPaginator(dc.client, &dynamodb.ScanInput{
+		TableName: aws.String("Jobs"),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			log.Println("Error scanning jobs: ", err)
+			return nil, err
+		}
+		var pageJobs []JobDocument
+		err = attributevalue.UnmarshalListOfMaps(page.Items, &pageJobs)
+		if err != nil {
+			log.Println("Error unmarshalling jobs: ", err)
+			return nil, err
+		}
+		jobs = append(jobs, pageJobs...)
+	}
+	return jobs, nil
+}
+
+// ScanVideoRequests reads every video request, it is only meant for maintenance tasks.
+func (dc *DynamoClient) ScanVideoRequests(ctx context.Context) ([]VideoRequestDocument, error) {
+	var videoRequests []VideoRequestDocument
+	paginator := dynamodb.NewScanPaginator(dc.client, &dynamodb.ScanInput{
+		TableName: aws.String("VideoRequests"),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			log.Println("Error scanning video requests: ", err)
+			return nil, err
+		}
+		var pageRequests []VideoRequestDocument
+		err = attributevalue.UnmarshalListOfMaps(page.Items, &pageRequests)
+		if err != nil {
+			log.Println("Error unmarshalling video requests: ", err)
+			return nil, err
+		}
+		videoRequests = append(videoRequests, pageRequests...)
+	}
+	return videoRequests, nil
+}
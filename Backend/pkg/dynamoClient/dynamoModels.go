@@ -1,25 +1,154 @@
 package dynamo
 
+import (
+	"time"
+)
+
+// DATE_FORMAT is how dates are stored on items, it sorts in time order
+const DATE_FORMAT = "2006-01-02 15:04:05"
+
+const (
+	UserTierFree = "free"
+	UserTierPro  = "pro"
+)
+
+// DEFAULT_PERMITTED_VIDEOS is the video quota of users created before it was stored on the user
+const DEFAULT_PERMITTED_VIDEOS = 100
+
 type UserDocument struct {
 	UserID               string   `dynamodbav:"userID"`
 	CreatedOn            string   `dynamodbav:"createdOn"`
 	PermittedGenerations int      `dynamodbav:"permittedGenerations"`
 	ScheduledJobs        []string `dynamodbav:"scheduledJobs,stringset,omitempty"`
+	// Tier is set by hand for users whose videos are queued ahead of others, missing means UserTierFree
+	Tier string `dynamodbav:"tier,omitempty"`
+	// Notes jobs count against PermittedGenerations, videos count against PermittedVideos in VideoRenders.
+	// Users created before video quotas have no PermittedVideos and get DEFAULT_PERMITTED_VIDEOS.
+	PermittedVideos int `dynamodbav:"permittedVideos,omitempty"`
+	VideoRenders    int `dynamodbav:"videoRenders,omitempty"`
+}
+
+// RateLimit allows Limit events per user in any Window. Each event holds one of the limit's Limit slots in the
+// RateLimits table until it leaves the window.
+type RateLimit struct {
+	Name   string
+	Limit  int
+	Window time.Duration
+}
+
+// RATE_LIMIT_ATTEMPTS is how many times TakeRateLimit looks for a free slot when requests made together take them first
+const RATE_LIMIT_ATTEMPTS = 3
+
+// RateLimitEvent is an event counted against a limit, At is when it was taken in Unix milliseconds.
+type RateLimitEvent struct {
+	Slot int   `dynamodbav:"slot"`
+	At   int64 `dynamodbav:"at"`
 }
 
 type JobDocument struct {
 	EntryID            string   `dynamodbav:"entryID"`
 	Title              string   `dynamodbav:"title"`
+	Course             string   `dynamodbav:"course,omitempty"`
 	GeneratedOn        string   `dynamodbav:"generatedOn"`
 	GeneratedBy        string   `dynamodbav:"generatedBy"`
 	SubtitlesGenerated bool     `dynamodbav:"subtitlesGenerated"`
+	SummaryStyle       string   `dynamodbav:"summaryStyle,omitempty"`
+	BackgroundMusic    string   `dynamodbav:"backgroundMusic,omitempty"`
 	VideosAvailable    []string `dynamodbav:"videosAvailable,stringset,omitempty"`
+	// VideoParts is how many parts each video split into short-form parts has, see videoutil.PartKeys
+	VideoParts map[string]int `dynamodbav:"videoParts,omitempty"`
+	// ReleasedOn is when Kaltura published the lecture's transcript, jobs from before it was recorded have none
+	ReleasedOn string `dynamodbav:"releasedOn,omitempty"`
+	// Pipeline is the state of each stage of the job keyed by stage name, see tasks.PipelineProcess
+	Pipeline map[string]PipelineStage `dynamodbav:"pipeline,omitempty"`
+}
+
+// A stage is pending until its task is queued, and is done or failed once asynq will not run it again
+const (
+	StageStatusPending = "PENDING"
+	StageStatusQueued  = "QUEUED"
+	StageStatusRunning = "RUNNING"
+	StageStatusDone    = "DONE"
+	StageStatusFailed  = "FAILED"
+)
+
+// PipelineStage is one step of a job's pipeline. Inputs and Outputs are S3 keys or prefixes, a stage only
+// starts once every stage it depends on is done and its inputs exist.
+type PipelineStage struct {
+	Status    string   `dynamodbav:"status" json:"status"`
+	DependsOn []string `dynamodbav:"dependsOn,omitempty" json:"dependsOn,omitempty"`
+	Inputs    []string `dynamodbav:"inputs,omitempty" json:"inputs,omitempty"`
+	Outputs   []string `dynamodbav:"outputs,omitempty" json:"outputs,omitempty"`
+	// Attempts counts how many times the stage has started running
+	Attempts  int    `dynamodbav:"attempts" json:"attempts"`
+	Error     string `dynamodbav:"error,omitempty" json:"error,omitempty"`
+	UpdatedOn string `dynamodbav:"updatedOn" json:"updatedOn"`
+}
+
+// VideoOptions is everything a user chooses about a rendered video, requests with the same options share a video.
+// The values are checked and named by videoutil.
+type VideoOptions struct {
+	BackgroundVideo string `json:"backgroundVideo" dynamodbav:"backgroundVideo,omitempty"`
+	Preset          string `json:"preset" dynamodbav:"preset,omitempty"`
+	// Format is videoutil.FORMAT_MP4 or FORMAT_HLS, requests made before HLS output have none
+	Format string `json:"format,omitempty" dynamodbav:"format,omitempty"`
+	// PartLength is the target length in seconds of each part, 0 renders a single video
+	PartLength int `json:"partLength,omitempty" dynamodbav:"partLength,omitempty"`
+	// Overlays are drawn over the narration, see videoutil.OVERLAYS
+	Overlays []string `json:"overlays,omitempty" dynamodbav:"overlays,omitempty"`
+	// Subtitles is videoutil.SUBTITLES_BURNED or SUBTITLES_SOFT, requests made before soft subtitles have none
+	Subtitles string `json:"subtitles,omitempty" dynamodbav:"subtitles,omitempty"`
 }
 
 type VideoRequestDocument struct {
-	EntryID        string `dynamodbav:"entryID"`
+	EntryID string `dynamodbav:"entryID"`
+	// RequestedVideo is the video ID, see videoutil.VideoID
 	RequestedVideo string `dynamodbav:"requestedVideo"`
-	RequestedOn    string `dynamodbav:"requestedOn"`
-	RequestedBy    string `dynamodbav:"requestedBy"`
-	VideoExpiry    int    `dynamodbav:"videoExpiry"`
+	VideoOptions
+	RequestedOn string `dynamodbav:"requestedOn"`
+	RequestedBy string `dynamodbav:"requestedBy"`
+	VideoExpiry int    `dynamodbav:"videoExpiry"`
+	// Progress is the percent of the video encoded so far, written by the consumer
+	Progress int `dynamodbav:"progress,omitempty"`
+	// Failure describes the latest attempt if it failed, it is cleared once a new attempt makes progress
+	Failure *VideoFailure `dynamodbav:"failure,omitempty"`
+	// Previews is set by the consumer before the video is listed on its job
+	Previews *VideoPreviews `dynamodbav:"previews,omitempty"`
+	// Priority is the queue the request was sent to and PriorityReason the signals that chose it
+	Priority       string `dynamodbav:"priority,omitempty"`
+	PriorityReason string `dynamodbav:"priorityReason,omitempty"`
+}
+
+// VideoPreviews are the S3 keys of the images generated alongside a video, either may be missing.
+type VideoPreviews struct {
+	Poster  string `dynamodbav:"poster,omitempty" json:"poster,omitempty"`
+	Preview string `dynamodbav:"preview,omitempty" json:"preview,omitempty"`
+}
+
+// VideoFailure records why generating a video failed.
+type VideoFailure struct {
+	Class     string `dynamodbav:"class" json:"class"`
+	Detail    string `dynamodbav:"detail" json:"detail"`
+	Retryable bool   `dynamodbav:"retryable" json:"retryable"`
+	Attempt   int    `dynamodbav:"attempt" json:"attempt"`
+	FailedOn  string `dynamodbav:"failedOn" json:"failedOn"`
+}
+
+const (
+	BackgroundStatusPending  = "PENDING"
+	BackgroundStatusReady    = "READY"
+	BackgroundStatusRejected = "REJECTED"
+)
+
+// UserBackgroundDocument is a background clip uploaded by a user, only that user may select it.
+type UserBackgroundDocument struct {
+	UserID       string  `dynamodbav:"userID"`
+	BackgroundID string  `dynamodbav:"backgroundID"`
+	DisplayName  string  `dynamodbav:"displayName"`
+	Status       string  `dynamodbav:"status"`
+	CreatedOn    string  `dynamodbav:"createdOn"`
+	S3Key        string  `dynamodbav:"s3Key,omitempty"`
+	Duration     float64 `dynamodbav:"duration,omitempty"`
+	AspectRatio  string  `dynamodbav:"aspectRatio,omitempty"`
+	RejectReason string  `dynamodbav:"rejectReason,omitempty"`
 }
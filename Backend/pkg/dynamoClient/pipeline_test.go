@@ -0,0 +1,103 @@
+package dynamo
+
+import (
+	"maps"
+	"regexp"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+var placeholderPattern = regexp.MustCompile(`[#:][A-Za-z0-9]+`)
+
+func TestPipelineStageUpdate(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	cases := []struct {
+		status    string
+		detail    string
+		from      []string
+		update    string
+		condition string
+		names     []string
+		values    []string
+	}{
+		{
+			status:    StageStatusPending,
+			from:      []string{StageStatusQueued},
+			update:    "SET pipeline.#stage.#status = :status, pipeline.#stage.#updatedOn = :now REMOVE pipeline.#stage.#error",
+			condition: "pipeline.#stage.#status IN (:from0)",
+			names:     []string{"#error", "#stage", "#status", "#updatedOn"},
+			values:    []string{":from0", ":now", ":status"},
+		},
+		{
+			status:    StageStatusQueued,
+			from:      []string{StageStatusPending},
+			update:    "SET pipeline.#stage.#status = :status, pipeline.#stage.#updatedOn = :now REMOVE pipeline.#stage.#error",
+			condition: "pipeline.#stage.#status IN (:from0)",
+			names:     []string{"#error", "#stage", "#status", "#updatedOn"},
+			values:    []string{":from0", ":now", ":status"},
+		},
+		{
+			status:    StageStatusRunning,
+			from:      []string{StageStatusQueued, StageStatusRunning},
+			update:    "SET pipeline.#stage.#status = :status, pipeline.#stage.#updatedOn = :now ADD pipeline.#stage.#attempts :one REMOVE pipeline.#stage.#error",
+			condition: "pipeline.#stage.#status IN (:from0, :from1)",
+			names:     []string{"#attempts", "#error", "#stage", "#status", "#updatedOn"},
+			values:    []string{":from0", ":from1", ":now", ":one", ":status"},
+		},
+		{
+			status:    StageStatusDone,
+			from:      []string{StageStatusRunning},
+			update:    "SET pipeline.#stage.#status = :status, pipeline.#stage.#updatedOn = :now REMOVE pipeline.#stage.#error",
+			condition: "pipeline.#stage.#status IN (:from0)",
+			names:     []string{"#error", "#stage", "#status", "#updatedOn"},
+			values:    []string{":from0", ":now", ":status"},
+		},
+		{
+			status:    StageStatusFailed,
+			detail:    "missing input",
+			update:    "SET pipeline.#stage.#status = :status, pipeline.#stage.#updatedOn = :now, pipeline.#stage.#error = :detail",
+			condition: "attribute_exists(pipeline.#stage)",
+			names:     []string{"#error", "#stage", "#status", "#updatedOn"},
+			values:    []string{":detail", ":now", ":status"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.status, func(t *testing.T) {
+			update, condition, names, values := pipelineStageUpdate("video:abc", c.status, c.detail, c.from, now)
+			if update != c.update {
+				t.Errorf("update = %q, want %q", update, c.update)
+			}
+			if condition != c.condition {
+				t.Errorf("condition = %q, want %q", condition, c.condition)
+			}
+			gotNames := slices.Sorted(maps.Keys(names))
+			if !slices.Equal(gotNames, c.names) {
+				t.Errorf("names = %v, want %v", gotNames, c.names)
+			}
+			gotValues := slices.Sorted(maps.Keys(values))
+			if !slices.Equal(gotValues, c.values) {
+				t.Errorf("values = %v, want %v", gotValues, c.values)
+			}
+			// DynamoDB rejects a name or value the expressions don't use, and one they use but don't define
+			used := placeholderPattern.FindAllString(update+" "+condition, -1)
+			for _, placeholder := range used {
+				_, isName := names[placeholder]
+				_, isValue := values[placeholder]
+				if !isName && !isValue {
+					t.Errorf("%s is used but not defined", placeholder)
+				}
+			}
+			for _, placeholder := range append(gotNames, gotValues...) {
+				if !slices.Contains(used, placeholder) {
+					t.Errorf("%s is defined but not used", placeholder)
+				}
+			}
+			if now, ok := values[":now"].(*types.AttributeValueMemberS); !ok || now.Value != "2026-10-19 12:00:00" {
+				t.Errorf(":now = %v", values[":now"])
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package dynamo
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFreeRateLimitSlot(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	limit := RateLimit{Name: "submissions", Limit: 3, Window: time.Minute}
+	ago := func(d time.Duration) int64 {
+		return now.Add(-d).UnixMilli()
+	}
+	cases := []struct {
+		name       string
+		events     []RateLimitEvent
+		slot       int
+		retryAfter time.Duration
+	}{
+		{
+			name: "no events",
+			slot: 0,
+		},
+		{
+			name:   "first free slot",
+			events: []RateLimitEvent{{Slot: 0, At: ago(time.Second)}, {Slot: 2, At: ago(time.Second)}},
+			slot:   1,
+		},
+		{
+			name:   "event left the window",
+			events: []RateLimitEvent{{Slot: 0, At: ago(time.Second)}, {Slot: 1, At: ago(time.Minute)}, {Slot: 2, At: ago(time.Second)}},
+			slot:   1,
+		},
+		{
+			name:       "every slot held",
+			events:     []RateLimitEvent{{Slot: 0, At: ago(10 * time.Second)}, {Slot: 1, At: ago(40 * time.Second)}, {Slot: 2, At: ago(time.Second)}},
+			slot:       -1,
+			retryAfter: 20 * time.Second,
+		},
+		{
+			name:       "waits at least a second",
+			events:     []RateLimitEvent{{Slot: 0, At: ago(59900 * time.Millisecond)}, {Slot: 1, At: ago(time.Second)}, {Slot: 2, At: ago(time.Second)}},
+			slot:       -1,
+			retryAfter: time.Second,
+		},
+		{
+			// Slots left over from a higher limit don't count
+			name:   "slot beyond the limit",
+			events: []RateLimitEvent{{Slot: 0, At: ago(time.Second)}, {Slot: 1, At: ago(time.Second)}, {Slot: 3, At: ago(time.Second)}},
+			slot:   2,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			slot, retryAfter := freeRateLimitSlot(c.events, limit, now)
+			if slot != c.slot || retryAfter != c.retryAfter {
+				t.Errorf("freeRateLimitSlot = (%d, %v), want (%d, %v)", slot, retryAfter, c.slot, c.retryAfter)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package dynamo
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// FromStreamImage converts a NewImage/OldImage from a DynamoDB stream event into SDK attribute values
+// so the same attributevalue tags used for the tables can decode stream records.
+func FromStreamImage(image map[string]events.DynamoDBAttributeValue) (map[string]types.AttributeValue, error) {
+	item := make(map[string]types.AttributeValue, len(image))
+	for name, value := range image {
+		converted, err := fromStreamAttribute(value)
+		if err != nil {
+			return nil, fmt.Errorf("attribute %s: %w", name, err)
+		}
+		item[name] = converted
+	}
+	return item, nil
+}
+
+func fromStreamAttribute(value events.DynamoDBAttributeValue) (types.AttributeValue, error) {
+	switch value.DataType() {
+	case events.DataTypeString:
+		return &types.AttributeValueMemberS{Value: value.String()}, nil
+	case events.DataTypeNumber:
+		return &types.AttributeValueMemberN{Value: value.Number()}, nil
+	case events.DataTypeBoolean:
+		return &types.AttributeValueMemberBOOL{Value: value.Boolean()}, nil
+	case events.DataTypeBinary:
+		return &types.AttributeValueMemberB{Value: value.Binary()}, nil
+	case events.DataTypeNull:
+		return &types.AttributeValueMemberNULL{Value: true}, nil
+	case events.DataTypeStringSet:
+		return &types.AttributeValueMemberSS{Value: value.StringSet()}, nil
+	case events.DataTypeNumberSet:
+		return &types.AttributeValueMemberNS{Value: value.NumberSet()}, nil
+	case events.DataTypeBinarySet:
+		return &types.AttributeValueMemberBS{Value: value.BinarySet()}, nil
+	case events.DataTypeList:
+		list := make([]types.AttributeValue, 0, len(value.List()))
+		for _, element := range value.List() {
+			converted, err := fromStreamAttribute(element)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, converted)
+		}
+		return &types.AttributeValueMemberL{Value: list}, nil
+	case events.DataTypeMap:
+		converted, err := FromStreamImage(value.Map())
+		if err != nil {
+			return nil, err
+		}
+		return &types.AttributeValueMemberM{Value: converted}, nil
+	default:
+		return nil, fmt.Errorf("unsupported stream attribute type %d", value.DataType())
+	}
+}
+
+// UnmarshalStreamImage decodes a stream image into out using its dynamodbav tags.
+func UnmarshalStreamImage(image map[string]events.DynamoDBAttributeValue, out any) error {
+	item, err := FromStreamImage(image)
+	if err != nil {
+		return err
+	}
+	return attributevalue.UnmarshalMap(item, out)
+}
+
+// DecodeJobImage decodes a Jobs table stream image.
+func DecodeJobImage(image map[string]events.DynamoDBAttributeValue) (*JobDocument, error) {
+	var job JobDocument
+	if err := UnmarshalStreamImage(image, &job); err != nil {
+		return nil, err
+	}
+	if job.EntryID == "" {
+		return nil, fmt.Errorf("job image has no entryID")
+	}
+	return &job, nil
+}
+
+// DecodeVideoRequestImage decodes a VideoRequests table stream image.
+func DecodeVideoRequestImage(image map[string]events.DynamoDBAttributeValue) (*VideoRequestDocument, error) {
+	var videoRequest VideoRequestDocument
+	if err := UnmarshalStreamImage(image, &videoRequest); err != nil {
+		return nil, err
+	}
+	if videoRequest.EntryID == "" || videoRequest.RequestedVideo == "" {
+		return nil, fmt.Errorf("video request image has no entryID or requestedVideo")
+	}
+	return &videoRequest, nil
+}
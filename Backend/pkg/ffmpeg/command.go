@@ -0,0 +1,122 @@
+package ffmpeg
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"time"
+)
+
+// CANCEL_WAIT_DELAY is how long to wait for ffmpeg's output to close after it has been killed.
+const CANCEL_WAIT_DELAY = 5 * time.Second
+
+// Input is a file read by ffmpeg along with the options that apply to it.
+type Input struct {
+	Path    string
+	Options []string
+}
+
+// Loop repeats the input forever, the output is then bounded with -shortest or -t.
+func (i *Input) Loop() *Input {
+	i.Options = append(i.Options, "-stream_loop", "-1")
+	return i
+}
+
+// Seek starts reading the input at the given number of seconds.
+func (i *Input) Seek(seconds float64) *Input {
+	i.Options = append(i.Options, "-ss", fmt.Sprintf("%.3f", seconds))
+	return i
+}
+
+// Format forces the demuxer used to read the input, e.g. "ffmetadata".
+func (i *Input) Format(name string) *Input {
+	i.Options = append(i.Options, "-f", name)
+	return i
+}
+
+// Output is a file written by ffmpeg with the streams mapped into it.
+type Output struct {
+	Path    string
+	Maps    []string
+	Options []string
+}
+
+// Map adds a filter graph pad (e.g. "output") to the output.
+func (o *Output) Map(label string) *Output {
+	o.Maps = append(o.Maps, "["+label+"]")
+	return o
+}
+
+// MapStream adds an input stream (e.g. "1:a") to the output.
+func (o *Output) MapStream(stream string) *Output {
+	o.Maps = append(o.Maps, stream)
+	return o
+}
+
+// With adds output options such as codecs, in order.
+func (o *Output) With(options ...string) *Output {
+	o.Options = append(o.Options, options...)
+	return o
+}
+
+// Command is a single ffmpeg invocation.
+type Command struct {
+	Global  []string
+	Inputs  []*Input
+	Graph   *Graph
+	Outputs []*Output
+}
+
+// New creates a command that overwrites its outputs and only logs errors.
+func New() *Command {
+	return &Command{Global: []string{"-y", "-hide_banner", "-loglevel", "error"}}
+}
+
+// Input adds an input file, its index is len(c.Inputs)-1 once added.
+func (c *Command) Input(path string) *Input {
+	input := &Input{Path: path}
+	c.Inputs = append(c.Inputs, input)
+	return input
+}
+
+// FilterGraph sets the -filter_complex graph.
+func (c *Command) FilterGraph(graph *Graph) *Command {
+	c.Graph = graph
+	return c
+}
+
+// Output adds an output file.
+func (c *Command) Output(path string) *Output {
+	output := &Output{Path: path}
+	c.Outputs = append(c.Outputs, output)
+	return output
+}
+
+// Args builds the argv (without the binary name) in the order ffmpeg expects.
+func (c *Command) Args() []string {
+	args := append([]string{}, c.Global...)
+	for _, input := range c.Inputs {
+		args = append(args, input.Options...)
+		args = append(args, "-i", input.Path)
+	}
+	if c.Graph != nil && len(c.Graph.chains) > 0 {
+		args = append(args, "-filter_complex", c.Graph.String())
+	}
+	for _, output := range c.Outputs {
+		for _, stream := range output.Maps {
+			args = append(args, "-map", stream)
+		}
+		args = append(args, output.Options...)
+		args = append(args, output.Path)
+	}
+	return args
+}
+
+// Cmd prepares the command to run in dir, ffmpeg is killed if ctx is cancelled before it exits.
+func (c *Command) Cmd(ctx context.Context, dir string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "ffmpeg", c.Args()...)
+	cmd.Dir = dir
+	// Don't wait forever on the output pipes of a killed process
+	cmd.WaitDelay = CANCEL_WAIT_DELAY
+	return cmd
+}
@@ -0,0 +1,40 @@
+package ffmpeg
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestCommandArgs(t *testing.T) {
+	cmd := New()
+	cmd.Input("background.mp4").Loop().Seek(12.5)
+	cmd.Input("logo.png")
+	cmd.Input("chapters.txt").Format("ffmetadata")
+	cmd.Input("Audio.aac")
+	cmd.FilterGraph(NewGraph().Add(
+		Pads("0"),
+		Filters(NewFilter("scale", "576", "1024"), NewFilter("ass", "Subtitle.ass")),
+		Pads("output"),
+	))
+	cmd.Output("out.mp4").
+		Map("output").
+		MapStream("3:a").
+		With("-c:v", "libx264", "-crf", "30").
+		With("-map_metadata", "2", "-shortest")
+	cmd.Output("poster.jpg").MapStream("0:v").With("-frames:v", "1")
+
+	checkGolden(t, "command_args.golden", strings.Join(cmd.Args(), "\n")+"\n")
+}
+
+func TestCommandArgsWithoutGraph(t *testing.T) {
+	cmd := New()
+	cmd.Input("in.mp4")
+	cmd.FilterGraph(NewGraph())
+	cmd.Output("out.aac").With("-vn", "-c:a", "copy")
+
+	want := []string{"-y", "-hide_banner", "-loglevel", "error", "-i", "in.mp4", "-vn", "-c:a", "copy", "out.aac"}
+	if got := cmd.Args(); !slices.Equal(got, want) {
+		t.Errorf("Args() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,121 @@
+package ffmpeg
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// STDERR_TAIL_SIZE is how much of the end of ffmpeg's stderr is kept when it fails.
+const STDERR_TAIL_SIZE = 4096
+
+const (
+	FailureMissingInput = "missing_input"
+	FailureInvalidInput = "invalid_input"
+	FailureBadSubtitles = "bad_subtitles"
+	FailureDiskFull     = "disk_full"
+	FailureEncoder      = "encoder_error"
+	FailureKilled       = "killed"
+	FailureUnknown      = "unknown"
+)
+
+// failurePatterns maps stderr messages to a failure class, the first match wins.
+var failurePatterns = []struct {
+	class    string
+	patterns []string
+}{
+	{FailureDiskFull, []string{"No space left on device"}},
+	{FailureBadSubtitles, []string{"Parsed_ass", "filter 'ass'", "libass"}},
+	{FailureMissingInput, []string{"No such file or directory"}},
+	{FailureInvalidInput, []string{"Invalid data found when processing input", "moov atom not found"}},
+	{FailureEncoder, []string{"Error while opening encoder", "Error initializing output stream", "Could not open encoder"}},
+}
+
+// transientFailures are worth another attempt, everything else fails the same way every time.
+var transientFailures = map[string]bool{
+	FailureDiskFull: true,
+	FailureKilled:   true,
+	FailureUnknown:  true,
+}
+
+// ExitError is returned when ffmpeg exits unsuccessfully.
+type ExitError struct {
+	Class string
+	// Tail is the end of ffmpeg's stderr
+	Tail string
+	Err  error
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("ffmpeg failed (%s): %v: %s", e.Class, e.Err, e.LastLine())
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+// Transient reports whether running the same command again might succeed.
+func (e *ExitError) Transient() bool {
+	return transientFailures[e.Class]
+}
+
+// LastLine is the last non-empty line of stderr, usually the most specific error message.
+func (e *ExitError) LastLine() string {
+	lines := strings.Split(strings.TrimSpace(e.Tail), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
+
+// Classify finds the failure class of a run from its exit error and stderr.
+func Classify(err error, stderr string) string {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && !exitErr.Exited() {
+		// Terminated by a signal, most likely the OOM killer
+		return FailureKilled
+	}
+	for _, failure := range failurePatterns {
+		for _, pattern := range failure.patterns {
+			if strings.Contains(stderr, pattern) {
+				return failure.class
+			}
+		}
+	}
+	return FailureUnknown
+}
+
+// newExitError wraps a failed run with its classification.
+func newExitError(err error, stderr *TailBuffer) error {
+	if err == nil {
+		return nil
+	}
+	tail := stderr.String()
+	return &ExitError{Class: Classify(err, tail), Tail: tail, Err: err}
+}
+
+// TailBuffer is an io.Writer that only keeps the last size bytes written to it.
+type TailBuffer struct {
+	mu   sync.Mutex
+	size int
+	buf  []byte
+}
+
+func NewTailBuffer(size int) *TailBuffer {
+	return &TailBuffer{size: size}
+}
+
+func (t *TailBuffer) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.buf = append(t.buf, p...)
+	if len(t.buf) > t.size {
+		t.buf = t.buf[len(t.buf)-t.size:]
+	}
+	return len(p), nil
+}
+
+func (t *TailBuffer) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return string(t.buf)
+}
@@ -0,0 +1,119 @@
+package ffmpeg
+
+import (
+	"strings"
+)
+
+// option is a single filter option, positional options have no key.
+type option struct {
+	key   string
+	value string
+}
+
+// Filter is one filter in a chain, e.g. scale=w=1080:h=1920.
+type Filter struct {
+	name    string
+	options []option
+}
+
+// NewFilter creates a filter with optional positional options, e.g. NewFilter("crop", "1080", "1920").
+func NewFilter(name string, positional ...string) Filter {
+	filter := Filter{name: name}
+	for _, value := range positional {
+		filter.options = append(filter.options, option{value: value})
+	}
+	return filter
+}
+
+// Set returns a copy of the filter with the named option added.
+func (f Filter) Set(key string, value string) Filter {
+	options := make([]option, len(f.options), len(f.options)+1)
+	copy(options, f.options)
+	f.options = append(options, option{key: key, value: value})
+	return f
+}
+
+func (f Filter) String() string {
+	if len(f.options) == 0 {
+		return f.name
+	}
+	parts := make([]string, len(f.options))
+	for i, opt := range f.options {
+		if opt.key == "" {
+			parts[i] = Escape(opt.value)
+		} else {
+			parts[i] = opt.key + "=" + Escape(opt.value)
+		}
+	}
+	return f.name + "=" + strings.Join(parts, ":")
+}
+
+// Escape quotes an option value when it contains characters that the filter graph parser would split on.
+func Escape(value string) string {
+	if !strings.ContainsAny(value, ":,;[]'\\ ") {
+		return value
+	}
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// Chain is a linear run of filters, reading from the input pads and writing to the output pads.
+// Pads are labels from other chains or input streams such as "0" or "1:a".
+type Chain struct {
+	Inputs  []string
+	Filters []Filter
+	Outputs []string
+}
+
+func (c Chain) String() string {
+	var builder strings.Builder
+	for _, input := range c.Inputs {
+		builder.WriteString("[" + input + "]")
+	}
+	filters := make([]string, len(c.Filters))
+	for i, filter := range c.Filters {
+		filters[i] = filter.String()
+	}
+	builder.WriteString(strings.Join(filters, ","))
+	for _, output := range c.Outputs {
+		builder.WriteString("[" + output + "]")
+	}
+	return builder.String()
+}
+
+// Graph is a complex filter graph made of chains.
+type Graph struct {
+	chains []Chain
+}
+
+func NewGraph() *Graph {
+	return &Graph{}
+}
+
+// Add appends a chain that reads inputs, runs filters in order and labels the results outputs.
+func (g *Graph) Add(inputs []string, filters []Filter, outputs []string) *Graph {
+	g.chains = append(g.chains, Chain{Inputs: inputs, Filters: filters, Outputs: outputs})
+	return g
+}
+
+// Chains returns the chains in the order they were added.
+func (g *Graph) Chains() []Chain {
+	return g.chains
+}
+
+func (g *Graph) String() string {
+	chains := make([]string, len(g.chains))
+	for i, chain := range g.chains {
+		chains[i] = chain.String()
+	}
+	return strings.Join(chains, ";")
+}
+
+// Pads is shorthand for a list of pad labels.
+func Pads(labels ...string) []string {
+	return labels
+}
+
+// Filters is shorthand for a list of filters.
+func Filters(filters ...Filter) []Filter {
+	return filters
+}
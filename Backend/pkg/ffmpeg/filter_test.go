@@ -0,0 +1,71 @@
+package ffmpeg
+
+import (
+	"testing"
+)
+
+func TestEscape(t *testing.T) {
+	cases := map[string]string{
+		"Subtitle.ass":         "Subtitle.ass",
+		"oh*dar":               "oh*dar",
+		"/tmp/job 1/sub.ass":   "'/tmp/job 1/sub.ass'",
+		"C:/fonts":             "'C:/fonts'",
+		"a,b":                  "'a,b'",
+		"a;b":                  "'a;b'",
+		"[pad]":                "'[pad]'",
+		`dir\file`:             `'dir\file'`,
+		"it's":                 `'it'\''s'`,
+		"Lecture: it's [done]": `'Lecture: it'\''s [done]'`,
+	}
+	for value, want := range cases {
+		if got := Escape(value); got != want {
+			t.Errorf("Escape(%q) = %q, want %q", value, got, want)
+		}
+	}
+}
+
+func TestFilterString(t *testing.T) {
+	cases := []struct {
+		filter Filter
+		want   string
+	}{
+		{NewFilter("setsar", "1"), "setsar=1"},
+		{NewFilter("null"), "null"},
+		{NewFilter("crop", "1080", "1920"), "crop=1080:1920"},
+		{NewFilter("scale").Set("w", "oh*mdar").Set("h", "ih/12"), "scale=w=oh*mdar:h=ih/12"},
+		{NewFilter("ass", "my subs.ass"), "ass='my subs.ass'"},
+		{NewFilter("drawtext").Set("text", "Part 1: Intro"), "drawtext=text='Part 1: Intro'"},
+	}
+	for _, c := range cases {
+		if got := c.filter.String(); got != c.want {
+			t.Errorf("String() = %q, want %q", got, c.want)
+		}
+	}
+}
+
+func TestFilterSetCopies(t *testing.T) {
+	base := NewFilter("scale", "576", "1024")
+	wide := base.Set("force_original_aspect_ratio", "increase")
+	narrow := base.Set("force_original_aspect_ratio", "decrease")
+	if got := base.String(); got != "scale=576:1024" {
+		t.Errorf("base = %q", got)
+	}
+	if got := wide.String(); got != "scale=576:1024:force_original_aspect_ratio=increase" {
+		t.Errorf("wide = %q", got)
+	}
+	if got := narrow.String(); got != "scale=576:1024:force_original_aspect_ratio=decrease" {
+		t.Errorf("narrow = %q", got)
+	}
+}
+
+func TestGraphString(t *testing.T) {
+	graph := NewGraph().
+		Add(Pads("0"), Filters(NewFilter("scale", "576", "1024"), NewFilter("ass", "sub title.ass")), Pads("subs")).
+		Add(Pads("1"), Filters(NewFilter("format", "rgba"), NewFilter("colorchannelmixer").Set("aa", "0.3")), Pads("logo")).
+		Add(Pads("subs", "logo"), Filters(NewFilter("overlay").Set("x", "W-w-10").Set("y", "10")), Pads("output"))
+
+	checkGolden(t, "graph.golden", graph.String()+"\n")
+	if got := len(graph.Chains()); got != 3 {
+		t.Errorf("len(Chains()) = %d, want 3", got)
+	}
+}
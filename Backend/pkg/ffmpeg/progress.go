@@ -0,0 +1,73 @@
+package ffmpeg
+
+import (
+	"bufio"
+	"io"
+	"log"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Progress is one block of the key=value report ffmpeg writes with -progress.
+type Progress struct {
+	// OutTime is how far into the output ffmpeg has encoded, in seconds
+	OutTime float64
+	Speed   string
+	// Done is set on the final report
+	Done bool
+}
+
+// WithProgress makes ffmpeg write machine readable progress reports to stdout instead of the stats line.
+func (c *Command) WithProgress() *Command {
+	c.Global = append(c.Global, "-progress", "pipe:1", "-nostats")
+	return c
+}
+
+// ReadProgress parses progress reports from r, calling onProgress at the end of every block.
+func ReadProgress(r io.Reader, onProgress func(Progress)) {
+	var progress Progress
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "out_time_us", "out_time_ms":
+			// Despite its name out_time_ms is also in microseconds
+			if microseconds, err := strconv.ParseInt(value, 10, 64); err == nil && microseconds >= 0 {
+				progress.OutTime = float64(microseconds) / 1e6
+			}
+		case "speed":
+			progress.Speed = value
+		case "progress":
+			progress.Done = value == "end"
+			onProgress(progress)
+		}
+	}
+}
+
+// RunWithProgress starts a command built with WithProgress and reports its progress until it exits.
+// A failed run returns an *ExitError holding the end of stderr.
+func RunWithProgress(cmd *exec.Cmd, onProgress func(Progress)) error {
+	stderr := NewTailBuffer(STDERR_TAIL_SIZE)
+	cmd.Stderr = stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		log.Println("Failed to attach to ffmpeg stdout")
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	ReadProgress(stdout, onProgress)
+	return newExitError(cmd.Wait(), stderr)
+}
+
+// Run runs a command to completion, a failed run returns an *ExitError holding the end of stderr.
+func Run(cmd *exec.Cmd) error {
+	stderr := NewTailBuffer(STDERR_TAIL_SIZE)
+	cmd.Stderr = stderr
+	return newExitError(cmd.Run(), stderr)
+}
@@ -0,0 +1,58 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"log"
+	"os/exec"
+	"regexp"
+	"strconv"
+)
+
+// Version is the release of the ffmpeg binary on the PATH.
+// Builds from git have no release number and are treated as newer than every release.
+type Version struct {
+	Major int
+	Minor int
+}
+
+// LATEST is used for git builds, which report a commit instead of a release.
+var LATEST = Version{Major: 1 << 16}
+
+var versionPattern = regexp.MustCompile(`ffmpeg version n?(\d+)\.(\d+)`)
+var gitVersionPattern = regexp.MustCompile(`ffmpeg version (N-|git-)`)
+
+// DetectVersion runs ffmpeg -version and parses the release it reports.
+func DetectVersion() (Version, error) {
+	out, err := exec.Command("ffmpeg", "-version").Output()
+	if err != nil {
+		log.Println("Failed to run ffmpeg -version")
+		return Version{}, err
+	}
+	return ParseVersion(string(out))
+}
+
+// ParseVersion reads the release from the first line of ffmpeg -version, e.g. "ffmpeg version 6.1.1 Copyright ...".
+func ParseVersion(output string) (Version, error) {
+	if gitVersionPattern.MatchString(output) {
+		return LATEST, nil
+	}
+	match := versionPattern.FindStringSubmatch(output)
+	if match == nil {
+		return Version{}, fmt.Errorf("could not find ffmpeg version in %q", output)
+	}
+	major, _ := strconv.Atoi(match[1])
+	minor, _ := strconv.Atoi(match[2])
+	return Version{Major: major, Minor: minor}, nil
+}
+
+// AtLeast reports whether this version is the given release or newer.
+func (v Version) AtLeast(major int, minor int) bool {
+	return v.Major > major || (v.Major == major && v.Minor >= minor)
+}
+
+func (v Version) String() string {
+	if v == LATEST {
+		return "git"
+	}
+	return fmt.Sprintf("%d.%d", v.Major, v.Minor)
+}
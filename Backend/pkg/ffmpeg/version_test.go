@@ -0,0 +1,58 @@
+package ffmpeg
+
+import (
+	"testing"
+)
+
+func TestParseVersion(t *testing.T) {
+	cases := []struct {
+		output string
+		want   Version
+	}{
+		{"ffmpeg version 6.1.1 Copyright (c) 2000-2023 the FFmpeg developers", Version{6, 1}},
+		{"ffmpeg version 7.1 Copyright (c) 2000-2024 the FFmpeg developers", Version{7, 1}},
+		{"ffmpeg version n7.0.2 Copyright (c) 2000-2024 the FFmpeg developers", Version{7, 0}},
+		{"ffmpeg version 6.1.1-3ubuntu5 Copyright (c) 2000-2023 the FFmpeg developers", Version{6, 1}},
+		{"ffmpeg version N-113348-g0a5813fc68 Copyright (c) 2000-2024 the FFmpeg developers", LATEST},
+		{"ffmpeg version git-2024-01-01-abcdef Copyright (c) 2000-2024 the FFmpeg developers", LATEST},
+	}
+	for _, c := range cases {
+		got, err := ParseVersion(c.output)
+		if err != nil {
+			t.Errorf("ParseVersion(%q) failed: %v", c.output, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("ParseVersion(%q) = %v, want %v", c.output, got, c.want)
+		}
+	}
+}
+
+func TestParseVersionUnknown(t *testing.T) {
+	if _, err := ParseVersion("command not found"); err == nil {
+		t.Error("ParseVersion accepted output without a version")
+	}
+}
+
+func TestVersionAtLeast(t *testing.T) {
+	cases := []struct {
+		version Version
+		major   int
+		minor   int
+		want    bool
+	}{
+		{Version{6, 1}, 7, 1, false},
+		{Version{7, 0}, 7, 1, false},
+		{Version{7, 1}, 7, 1, true},
+		{Version{8, 0}, 7, 1, true},
+		{LATEST, 7, 1, true},
+	}
+	for _, c := range cases {
+		if got := c.version.AtLeast(c.major, c.minor); got != c.want {
+			t.Errorf("%v.AtLeast(%d, %d) = %v, want %v", c.version, c.major, c.minor, got, c.want)
+		}
+	}
+	if got := LATEST.String(); got != "git" {
+		t.Errorf("LATEST.String() = %q, want git", got)
+	}
+}
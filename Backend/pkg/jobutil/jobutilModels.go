@@ -1,7 +1,22 @@
 package jobutil
 
+const (
+	SummaryStyleNarrator = "narrator"
+	SummaryStylePodcast  = "podcast"
+)
+
 type JobQueueRequest struct {
-	EntryID         string `json:"entryID"`
-	Title           string `json:"title"`
-	BackgroundVideo string `json:"backgroundVideo"`
+	EntryID         string   `json:"entryID"`
+	Title           string   `json:"title"`
+	BackgroundVideo string   `json:"backgroundVideo"`
+	SummaryStyle    string   `json:"summaryStyle"`
+	BackgroundMusic string   `json:"backgroundMusic"`
+	Preset          string   `json:"preset"`
+	Format          string   `json:"format"`
+	PartLength      int      `json:"partLength"`
+	Overlays        []string `json:"overlays"`
+	// Course is shown with the title on the intro card
+	Course string `json:"course"`
+	// Subtitles is burned into the video or muxed as a caption track, see videoutil.SUBTITLE_MODES
+	Subtitles string `json:"subtitles"`
 }
@@ -37,33 +37,34 @@ func BuildTranscriptLinkURL(assetID string) string {
 	return fmt.Sprintf("https://cdnapi.kaltura.com/api_v3/index.php/service/attachment_attachmentAsset/action/serve/attachmentAssetId/%s", assetID)
 }
 
-func GetTranscriptLink(entryID string) (string, error) {
+// GetTranscriptAsset finds the lecture's transcript, its CreatedAt is the closest we have to when the lecture was released.
+func GetTranscriptAsset(entryID string) (*KalturaTranscriptAsset, error) {
 	// Need to make an API call to the Kaltura API to get the transcript link
 	resp, err := http.Get(BuildQueryURL(entryID))
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		return "", err
+		return nil, fmt.Errorf("kaltura returned status %d", resp.StatusCode)
 	}
 
 	// Parse the response to get the transcript link
 	var rawResponses []json.RawMessage
 	if err := json.NewDecoder(resp.Body).Decode(&rawResponses); err != nil {
-		return "", err
+		return nil, err
 	}
 	var kalturaAttachmentResponse KalturaAttachmentAssetListResponse
 	if err := json.Unmarshal(rawResponses[1], &kalturaAttachmentResponse); err != nil {
-		return "", fmt.Errorf("failed to parse Kaltura Attachment response")
+		return nil, fmt.Errorf("failed to parse Kaltura Attachment response")
 	}
 	if kalturaAttachmentResponse.TotalCount == 0 {
-		return "", fmt.Errorf("no transcript found for entry ID %s", entryID)
+		return nil, fmt.Errorf("no transcript found for entry ID %s", entryID)
 	}
 	for _, asset := range kalturaAttachmentResponse.Objects {
 		if strings.HasSuffix(asset.Filename, ".txt") && strings.Contains(asset.Filename, entryID) {
-			return BuildTranscriptLinkURL(asset.ID), nil
+			return &asset, nil
 		}
 	}
-	return "", fmt.Errorf("no transcript found for entry ID %s", entryID)
+	return nil, fmt.Errorf("no transcript found for entry ID %s", entryID)
 }
@@ -2,45 +2,115 @@ package s3client
 
 import (
 	"context"
+	"fmt"
 	"io"
+	"mime"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 )
 
+const (
+	// Objects larger than PART_SIZE are uploaded and downloaded in parts of this size, this many at a time
+	PART_SIZE          = 16 * 1024 * 1024
+	PART_CONCURRENCY   = 4
+	CHECKSUM_ALGORITHM = types.ChecksumAlgorithmCrc32
+)
+
+// UploadOptions are the metadata stored with an uploaded object.
+type UploadOptions struct {
+	ContentType string
+	// ContentDisposition names the file browsers save the object as, see ContentDisposition
+	ContentDisposition string
+}
+
+// ObjectInfo is what S3 stores about an object besides its content.
+type ObjectInfo struct {
+	// ETag is the hex MD5 of the content for objects uploaded in a single PUT, multipart ETags end in -<parts>
+	ETag         string
+	Size         int64
+	LastModified time.Time
+}
+
 type S3Methods interface {
-	UploadFile(bucket string, key string, file io.ReadSeeker, filetype string) error
-	ReadFile(bucket string, key string) (io.ReadCloser, error)
-	DeleteFile(bucket string, key string) error
+	UploadFile(ctx context.Context, bucket string, key string, file io.Reader, filetype string) error
+	Upload(ctx context.Context, bucket string, key string, body io.Reader, options UploadOptions) error
+	ReadFile(ctx context.Context, bucket string, key string) (io.ReadCloser, error)
+	DownloadFile(ctx context.Context, bucket string, key string, file io.WriterAt) (int64, error)
+	HeadFile(ctx context.Context, bucket string, key string) (ObjectInfo, error)
+	DeleteFile(ctx context.Context, bucket string, key string) error
+	DeletePrefix(ctx context.Context, bucket string, prefix string) error
+	ListFiles(ctx context.Context, bucket string, prefix string) ([]string, error)
+	ListPrefixes(ctx context.Context, bucket string, prefix string) ([]string, error)
+	PresignUpload(ctx context.Context, bucket string, key string, filetype string, expiry time.Duration) (string, error)
 }
 
 type S3Client struct {
-	client *s3.Client
+	client        *s3.Client
+	presignClient *s3.PresignClient
+	uploader      *manager.Uploader
+	downloader    *manager.Downloader
 }
 
 func NewS3Client(awsSession aws.Config) S3Methods {
+	client := s3.NewFromConfig(awsSession)
 	return &S3Client{
-		client: s3.NewFromConfig(awsSession),
+		client:        client,
+		presignClient: s3.NewPresignClient(client),
+		uploader: manager.NewUploader(client, func(u *manager.Uploader) {
+			u.PartSize = PART_SIZE
+			u.Concurrency = PART_CONCURRENCY
+		}),
+		downloader: manager.NewDownloader(client, func(d *manager.Downloader) {
+			d.PartSize = PART_SIZE
+			d.Concurrency = PART_CONCURRENCY
+		}),
 	}
 }
 
-func (sc *S3Client) UploadFile(bucket string, key string, file io.ReadSeeker, filetype string) error {
-	_, err := sc.client.PutObject(context.Background(), &s3.PutObjectInput{
-		Bucket:      aws.String(bucket),
-		Key:         aws.String(key),
-		ContentType: aws.String(filetype),
-		Body:        file,
-	})
+// ContentDisposition builds a Content-Disposition header, e.g. ContentDisposition("attachment", "Lecture 1.mp4").
+// Filenames that are not plain ASCII are encoded as RFC 2231 allows.
+func ContentDisposition(disposition string, filename string) string {
+	return mime.FormatMediaType(disposition, map[string]string{"filename": filename})
+}
+
+// UploadFile uploads a file with only its content type set, see Upload.
+func (sc *S3Client) UploadFile(ctx context.Context, bucket string, key string, file io.Reader, filetype string) error {
+	return sc.Upload(ctx, bucket, key, file, UploadOptions{ContentType: filetype})
+}
+
+// Upload streams body to S3. Bodies larger than PART_SIZE are sent as a multipart upload, which is aborted if it fails,
+// so they never need to fit in memory or a single PUT. Every part carries a CHECKSUM_ALGORITHM checksum S3 verifies.
+func (sc *S3Client) Upload(ctx context.Context, bucket string, key string, body io.Reader, options UploadOptions) error {
+	input := &s3.PutObjectInput{
+		Bucket:            aws.String(bucket),
+		Key:               aws.String(key),
+		Body:              body,
+		ChecksumAlgorithm: CHECKSUM_ALGORITHM,
+	}
+	if options.ContentType != "" {
+		input.ContentType = aws.String(options.ContentType)
+	}
+	if options.ContentDisposition != "" {
+		input.ContentDisposition = aws.String(options.ContentDisposition)
+	}
+	_, err := sc.uploader.Upload(ctx, input)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (sc *S3Client) ReadFile(bucket string, key string) (io.ReadCloser, error) {
-	resp, err := sc.client.GetObject(context.Background(), &s3.GetObjectInput{
-		Bucket: aws.String(bucket),
-		Key:    aws.String(key),
+// ReadFile streams an object, the caller must close it. Whole objects are checked against their stored checksum as they are read.
+func (sc *S3Client) ReadFile(ctx context.Context, bucket string, key string) (io.ReadCloser, error) {
+	resp, err := sc.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket:       aws.String(bucket),
+		Key:          aws.String(key),
+		ChecksumMode: types.ChecksumModeEnabled,
 	})
 	if err != nil {
 		return nil, err
@@ -48,8 +118,37 @@ func (sc *S3Client) ReadFile(bucket string, key string) (io.ReadCloser, error) {
 	return resp.Body, nil
 }
 
-func (sc *S3Client) DeleteFile(bucket string, key string) error {
-	_, err := sc.client.DeleteObject(context.Background(), &s3.DeleteObjectInput{
+// DownloadFile writes an object into file with concurrent ranged reads of PART_SIZE, returning the number of bytes written.
+// Use it instead of ReadFile for large objects that end up on disk anyway.
+func (sc *S3Client) DownloadFile(ctx context.Context, bucket string, key string, file io.WriterAt) (int64, error) {
+	written, err := sc.downloader.Download(ctx, file, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return 0, err
+	}
+	return written, nil
+}
+
+// HeadFile reads an object's metadata without downloading it.
+func (sc *S3Client) HeadFile(ctx context.Context, bucket string, key string) (ObjectInfo, error) {
+	resp, err := sc.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	return ObjectInfo{
+		ETag:         strings.Trim(aws.ToString(resp.ETag), `"`),
+		Size:         aws.ToInt64(resp.ContentLength),
+		LastModified: aws.ToTime(resp.LastModified),
+	}, nil
+}
+
+func (sc *S3Client) DeleteFile(ctx context.Context, bucket string, key string) error {
+	_, err := sc.client.DeleteObject(ctx, &s3.DeleteObjectInput{
 		Bucket: aws.String(bucket),
 		Key:    aws.String(key),
 	})
@@ -58,3 +157,88 @@ func (sc *S3Client) DeleteFile(bucket string, key string) error {
 	}
 	return nil
 }
+
+// DeletePrefix deletes every object whose key starts with prefix.
+func (sc *S3Client) DeletePrefix(ctx context.Context, bucket string, prefix string) error {
+	paginator := s3.NewListObjectsV2Paginator(sc.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		if len(page.Contents) == 0 {
+			continue
+		}
+		// A page holds at most 1000 keys, the most DeleteObjects accepts at once
+		objects := make([]types.ObjectIdentifier, len(page.Contents))
+		for i, object := range page.Contents {
+			objects[i] = types.ObjectIdentifier{Key: object.Key}
+		}
+		output, err := sc.client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(bucket),
+			Delete: &types.Delete{Objects: objects, Quiet: aws.Bool(true)},
+		})
+		if err != nil {
+			return err
+		}
+		if len(output.Errors) > 0 {
+			return fmt.Errorf("failed to delete %d objects under %s: %s", len(output.Errors), prefix, aws.ToString(output.Errors[0].Message))
+		}
+	}
+	return nil
+}
+
+// ListFiles returns the key of every object under prefix.
+func (sc *S3Client) ListFiles(ctx context.Context, bucket string, prefix string) ([]string, error) {
+	paginator := s3.NewListObjectsV2Paginator(sc.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(prefix),
+	})
+	var keys []string
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, object := range page.Contents {
+			keys = append(keys, aws.ToString(object.Key))
+		}
+	}
+	return keys, nil
+}
+
+// ListPrefixes returns the "folders" directly under prefix, e.g. ListPrefixes(bucket, "assets/") returns "assets/<entryID>/".
+func (sc *S3Client) ListPrefixes(ctx context.Context, bucket string, prefix string) ([]string, error) {
+	paginator := s3.NewListObjectsV2Paginator(sc.client, &s3.ListObjectsV2Input{
+		Bucket:    aws.String(bucket),
+		Prefix:    aws.String(prefix),
+		Delimiter: aws.String("/"),
+	})
+	var prefixes []string
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, commonPrefix := range page.CommonPrefixes {
+			prefixes = append(prefixes, aws.ToString(commonPrefix.Prefix))
+		}
+	}
+	return prefixes, nil
+}
+
+// PresignUpload returns a URL that lets the holder PUT a single object of the given content type until it expires.
+func (sc *S3Client) PresignUpload(ctx context.Context, bucket string, key string, filetype string, expiry time.Duration) (string, error) {
+	request, err := sc.presignClient.PresignPutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(bucket),
+		Key:         aws.String(key),
+		ContentType: aws.String(filetype),
+	}, s3.WithPresignExpires(expiry))
+	if err != nil {
+		return "", err
+	}
+	return request.URL, nil
+}
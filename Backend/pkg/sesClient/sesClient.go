@@ -2,6 +2,7 @@ package sesclient
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
 	"os"
@@ -14,8 +15,15 @@ import (
 	"golang.org/x/text/language"
 )
 
+// RETRY_URL is the lecture's page on Kaltura, where the extension can request the video again
+const RETRY_URL = "https://kaf.canvas.umn.edu/media/t/%s"
+
+// ASSET_URL is where a generated video's S3 key is served from
+const ASSET_URL = "https://www.zircon.socialcoding.net/%s"
+
 type SESMethods interface {
-	SendEmail(to string, subject string, entryID string, backgroundVideo string) error
+	SendEmail(ctx context.Context, to string, subject string, videoKeys []string, backgroundVideo string) error
+	SendFailureEmail(ctx context.Context, to string, subject string, entryID string, category string) error
 }
 
 type SESClient struct {
@@ -36,7 +44,38 @@ func TitleVideo(backgroundVideo string) string {
 	return caser.String(replacedVideo)
 }
 
-func (sc *SESClient) SendEmail(to string, subject string, entryID string, backgroundVideo string) error {
+// jobTemplateData is marshalled rather than formatted since lecture titles may contain quotes.
+type jobTemplateData struct {
+	Subject    string
+	VideoTitle string
+	// Videos has one link per file, a video split into parts has one per part
+	Videos []videoLink
+}
+
+type videoLink struct {
+	Label string
+	URL   string
+}
+
+// SendEmail tells the user that their video is ready, videoKeys are the S3 keys of the files to open: the MP4,
+// the master playlist of an HLS video or every part of a split video in order.
+func (sc *SESClient) SendEmail(ctx context.Context, to string, subject string, videoKeys []string, backgroundVideo string) error {
+	data := jobTemplateData{
+		Subject:    subject,
+		VideoTitle: TitleVideo(backgroundVideo),
+	}
+	for i, videoKey := range videoKeys {
+		link := videoLink{Label: "View Video", URL: fmt.Sprintf(ASSET_URL, videoKey)}
+		if len(videoKeys) > 1 {
+			link.Label = fmt.Sprintf("View Part %d", i+1)
+		}
+		data.Videos = append(data.Videos, link)
+	}
+	templateData, err := json.Marshal(data)
+	if err != nil {
+		log.Printf("Error marshalling email template data: %v", err)
+		return err
+	}
 	emailInput := &sesv2.SendEmailInput{
 		Destination: &types.Destination{
 			ToAddresses: []string{
@@ -47,19 +86,11 @@ func (sc *SESClient) SendEmail(to string, subject string, entryID string, backgr
 		Content: &types.EmailContent{
 			Template: &types.Template{
 				TemplateName: aws.String("zircon_job_complete_template"),
-				TemplateData: aws.String(
-					fmt.Sprintf(
-						`{ "Subject": "%s", "VideoTitle": "%s", "VideoType": "%s", "EntryID": "%s" }`,
-						subject,
-						TitleVideo(backgroundVideo),
-						backgroundVideo,
-						entryID,
-					),
-				),
+				TemplateData: aws.String(string(templateData)),
 			},
 		},
 	}
-	_, err := sc.client.SendEmail(context.Background(), emailInput)
+	_, err = sc.client.SendEmail(ctx, emailInput)
 	if err != nil {
 		log.Printf("Error sending email: %v", err)
 		return err
@@ -67,3 +98,46 @@ func (sc *SESClient) SendEmail(to string, subject string, entryID string, backgr
 
 	return nil
 }
+
+// failureTemplateData is marshalled rather than formatted since lecture titles and failure categories may contain quotes.
+type failureTemplateData struct {
+	Subject  string
+	Category string
+	EntryID  string
+	RetryURL string
+}
+
+// SendFailureEmail tells the user that their lecture could not be processed, why, and where to request it again.
+func (sc *SESClient) SendFailureEmail(ctx context.Context, to string, subject string, entryID string, category string) error {
+	templateData, err := json.Marshal(failureTemplateData{
+		Subject:  subject,
+		Category: category,
+		EntryID:  entryID,
+		RetryURL: fmt.Sprintf(RETRY_URL, entryID),
+	})
+	if err != nil {
+		log.Printf("Error marshalling email template data: %v", err)
+		return err
+	}
+	emailInput := &sesv2.SendEmailInput{
+		Destination: &types.Destination{
+			ToAddresses: []string{
+				to,
+			},
+		},
+		FromEmailAddress: aws.String(fmt.Sprintf("Zircon <noreply@%s>", sc.domain)),
+		Content: &types.EmailContent{
+			Template: &types.Template{
+				TemplateName: aws.String("zircon_job_failed_template"),
+				TemplateData: aws.String(string(templateData)),
+			},
+		},
+	}
+	_, err = sc.client.SendEmail(ctx, emailInput)
+	if err != nil {
+		log.Printf("Error sending failure email: %v", err)
+		return err
+	}
+
+	return nil
+}
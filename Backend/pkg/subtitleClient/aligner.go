@@ -0,0 +1,286 @@
+package subtitleclient
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/Kanishk-K/UniteDownloader/Backend/pkg/ffmpeg"
+)
+
+// Silence is a span of audio (in seconds) that ffmpeg's silencedetect filter reported as quiet.
+type Silence struct {
+	Start float64
+	End   float64
+}
+
+func (s Silence) Duration() float64 {
+	return s.End - s.Start
+}
+
+const (
+	SILENCE_NOISE_FLOOR    = "-35dB"
+	SILENCE_MIN_DURATION   = 0.2
+	PUNCTUATION_CHARACTERS = ".,?!:;"
+)
+
+// audioCommand runs ffmpeg or ffprobe so that it is killed once ctx is cancelled, like ffmpeg.Command.Cmd.
+func audioCommand(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	// Don't wait forever on the output pipes of a killed process
+	cmd.WaitDelay = ffmpeg.CANCEL_WAIT_DELAY
+	return cmd
+}
+
+// ProbeAudioDuration returns the duration of the audio file in seconds using ffprobe.
+func ProbeAudioDuration(ctx context.Context, audioPath string) (float64, error) {
+	out, err := audioCommand(
+		ctx,
+		"ffprobe",
+		"-v", "error",
+		"-show_entries", "format=duration",
+		"-of", "default=noprint_wrappers=1:nokey=1",
+		audioPath,
+	).Output()
+	if err != nil {
+		log.Println("Failed to probe audio duration")
+		return 0, err
+	}
+	duration, err := strconv.ParseFloat(strings.TrimSpace(string(out)), 64)
+	if err != nil {
+		log.Println("Failed to parse audio duration")
+		return 0, err
+	}
+	return duration, nil
+}
+
+// DetectSilences runs ffmpeg's silencedetect filter over the audio file and returns every pause it finds.
+func DetectSilences(ctx context.Context, audioPath string) ([]Silence, error) {
+	var stderr bytes.Buffer
+	cmd := audioCommand(
+		ctx,
+		"ffmpeg",
+		"-hide_banner",
+		"-nostats",
+		"-i", audioPath,
+		"-af", fmt.Sprintf("silencedetect=noise=%s:d=%.2f", SILENCE_NOISE_FLOOR, SILENCE_MIN_DURATION),
+		"-f", "null",
+		"-",
+	)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		log.Println("Failed to run silence detection")
+		return nil, err
+	}
+	return parseSilences(stderr.String()), nil
+}
+
+// parseSilences reads the "silence_start: X" / "silence_end: Y" pairs silencedetect writes to stderr.
+func parseSilences(output string) []Silence {
+	var silences []Silence
+	var start float64
+	open := false
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if idx := strings.Index(line, "silence_start: "); idx >= 0 {
+			value, err := strconv.ParseFloat(strings.Fields(line[idx+len("silence_start: "):])[0], 64)
+			if err != nil {
+				continue
+			}
+			start = math.Max(value, 0)
+			open = true
+		} else if idx := strings.Index(line, "silence_end: "); idx >= 0 && open {
+			value, err := strconv.ParseFloat(strings.Fields(line[idx+len("silence_end: "):])[0], 64)
+			if err != nil {
+				continue
+			}
+			silences = append(silences, Silence{Start: start, End: value})
+			open = false
+		}
+	}
+	return silences
+}
+
+// AlignAudio estimates word timestamps for TTS audio that was returned without any.
+// The audio is written to a temporary file so that ffprobe and ffmpeg (which must be on the PATH) can inspect it,
+// so it is only called from the consumer, whose image ships both.
+func AlignAudio(ctx context.Context, audio []byte, text string) ([]WordTimeStamp, error) {
+	audioFp, err := os.CreateTemp("", "align-*.aac")
+	if err != nil {
+		log.Println("Failed to create temp audio file")
+		return nil, err
+	}
+	defer os.Remove(audioFp.Name())
+	defer audioFp.Close()
+	if _, err := audioFp.Write(audio); err != nil {
+		log.Println("Failed to write temp audio file")
+		return nil, err
+	}
+
+	duration, err := ProbeAudioDuration(ctx, audioFp.Name())
+	if err != nil {
+		return nil, err
+	}
+	silences, err := DetectSilences(ctx, audioFp.Name())
+	if err != nil {
+		return nil, err
+	}
+	return EstimateWordTimeStamps(text, duration, silences), nil
+}
+
+// EstimateWordTimeStamps spreads the words of text across the audio duration.
+// Each word receives speaking time proportional to its syllable and character count,
+// and the detected silences are inserted at the word boundaries closest to them.
+// Punctuation is emitted as its own zero-length word, matching what LemonFox returns.
+func EstimateWordTimeStamps(text string, duration float64, silences []Silence) []WordTimeStamp {
+	tokens := tokenize(text)
+	if len(tokens) == 0 || duration <= 0 {
+		return nil
+	}
+
+	// Step 1: Lay the words out on a "speech clock" that excludes all silence.
+	silenceTotal := 0.0
+	for _, silence := range silences {
+		silenceTotal += silence.Duration()
+	}
+	speechTime := duration - silenceTotal
+	if speechTime <= 0 {
+		speechTime = duration
+		silences = nil
+	}
+	totalWeight := 0.0
+	for _, token := range tokens {
+		totalWeight += wordWeight(token)
+	}
+	if totalWeight == 0 {
+		return nil
+	}
+	// boundaries[i] is the speech clock time at which token i starts, the final entry is the end of speech.
+	boundaries := make([]float64, len(tokens)+1)
+	for i, token := range tokens {
+		boundaries[i+1] = boundaries[i] + wordWeight(token)/totalWeight*speechTime
+	}
+
+	// Step 2: Find where each silence falls on the speech clock and snap it to the nearest word boundary.
+	// Boundaries that follow punctuation are preferred since that is where a narrator pauses.
+	type pause struct {
+		boundary int
+		length   float64
+	}
+	pauses := []pause{}
+	elapsedSilence := 0.0
+	for _, silence := range silences {
+		position := silence.Start - elapsedSilence
+		elapsedSilence += silence.Duration()
+		pauses = append(pauses, pause{
+			boundary: nearestBoundary(boundaries, tokens, position),
+			length:   silence.Duration(),
+		})
+	}
+
+	// Step 3: Expand the speech clock back to real time by inserting the pauses.
+	offsets := make([]float64, len(boundaries))
+	for _, p := range pauses {
+		for i := p.boundary; i < len(offsets); i++ {
+			offsets[i] += p.length
+		}
+	}
+	words := make([]WordTimeStamp, 0, len(tokens))
+	for i, token := range tokens {
+		start := boundaries[i] + offsets[i]
+		end := boundaries[i+1] + offsets[i]
+		if isPunctuationToken(token) {
+			// Punctuation belongs to the end of the previous word
+			start = end
+		}
+		words = append(words, WordTimeStamp{
+			Word:      token,
+			StartTime: math.Min(start, duration),
+			EndTime:   math.Min(end, duration),
+		})
+	}
+	return words
+}
+
+// tokenize splits text on whitespace and separates trailing punctuation into its own token.
+func tokenize(text string) []string {
+	var tokens []string
+	for _, field := range strings.Fields(text) {
+		trailing := []string{}
+		for len(field) > 0 && strings.ContainsRune(PUNCTUATION_CHARACTERS, rune(field[len(field)-1])) {
+			trailing = append([]string{field[len(field)-1:]}, trailing...)
+			field = field[:len(field)-1]
+		}
+		if field != "" {
+			tokens = append(tokens, field)
+		}
+		tokens = append(tokens, trailing...)
+	}
+	return tokens
+}
+
+func isPunctuationToken(token string) bool {
+	return len(token) == 1 && strings.Contains(PUNCTUATION_CHARACTERS, token)
+}
+
+// wordWeight approximates how long a word takes to say.
+func wordWeight(token string) float64 {
+	if isPunctuationToken(token) {
+		return 0
+	}
+	letters := 0
+	for _, r := range token {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			letters++
+		}
+	}
+	return float64(countSyllables(token)) + float64(letters)/10
+}
+
+// countSyllables counts vowel groups, dropping a silent trailing "e". Every word has at least one syllable.
+func countSyllables(token string) int {
+	word := strings.ToLower(token)
+	syllables := 0
+	previousVowel := false
+	for _, r := range word {
+		vowel := strings.ContainsRune("aeiouy", r)
+		if vowel && !previousVowel {
+			syllables++
+		}
+		previousVowel = vowel
+	}
+	if strings.HasSuffix(word, "e") && !strings.HasSuffix(word, "le") && syllables > 1 {
+		syllables--
+	}
+	if syllables == 0 {
+		return 1
+	}
+	return syllables
+}
+
+// nearestBoundary returns the word boundary closest to position on the speech clock.
+func nearestBoundary(boundaries []float64, tokens []string, position float64) int {
+	best := 0
+	bestDistance := math.Inf(1)
+	for i, boundary := range boundaries {
+		distance := math.Abs(boundary - position)
+		if i > 0 && isPunctuationToken(tokens[i-1]) {
+			// Favour pausing after punctuation
+			distance /= 2
+		}
+		if distance < bestDistance || (distance == bestDistance && i > 0 && isPunctuationToken(tokens[i-1])) {
+			best = i
+			bestDistance = distance
+		}
+	}
+	return best
+}
@@ -0,0 +1,120 @@
+package subtitleclient
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	cases := map[string][]string{
+		"":                       nil,
+		"Hello world":            {"Hello", "world"},
+		"Hello, world.":          {"Hello", ",", "world", "."},
+		"  spaced\tout\nlines  ": {"spaced", "out", "lines"},
+		"Really?!":               {"Really", "?", "!"},
+		"U.S. law":               {"U.S", ".", "law"},
+		"... wait":               {".", ".", ".", "wait"},
+		"ratio 3:2;":             {"ratio", "3:2", ";"},
+	}
+	for text, want := range cases {
+		if got := tokenize(text); !slices.Equal(got, want) {
+			t.Errorf("tokenize(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
+
+func TestCountSyllables(t *testing.T) {
+	cases := map[string]int{
+		"a":         1,
+		"the":       1,
+		"make":      1,
+		"table":     2,
+		"Hello":     2,
+		"lecture":   2,
+		"syllable":  3,
+		"beautiful": 3,
+		"rhythm":    1,
+		"42":        1,
+		"queue":     1,
+	}
+	for word, want := range cases {
+		if got := countSyllables(word); got != want {
+			t.Errorf("countSyllables(%q) = %d, want %d", word, got, want)
+		}
+	}
+}
+
+func TestParseSilences(t *testing.T) {
+	output := `Input #0, aac, from 'align.aac':
+  Duration: 00:00:05.02, bitrate: 129 kb/s
+[silencedetect @ 0x5581] silence_start: -0.0120
+[silencedetect @ 0x5581] silence_end: 0.3 | silence_duration: 0.312
+[silencedetect @ 0x5581] silence_end: 0.9 | silence_duration: 0.2
+[silencedetect @ 0x5581] silence_start: 2.5
+[silencedetect @ 0x5581] silence_end: 3.25 | silence_duration: 0.75
+[silencedetect @ 0x5581] silence_start: 4.8
+size=N/A time=00:00:05.02 bitrate=N/A speed= 412x`
+	want := []Silence{{Start: 0, End: 0.3}, {Start: 2.5, End: 3.25}}
+	if got := parseSilences(output); !slices.Equal(got, want) {
+		t.Errorf("parseSilences() = %v, want %v", got, want)
+	}
+	if got := parseSilences(""); got != nil {
+		t.Errorf("parseSilences(\"\") = %v, want nil", got)
+	}
+}
+
+func assertWords(t *testing.T, got []WordTimeStamp, want []WordTimeStamp) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d words %v, want %d %v", len(got), got, len(want), want)
+	}
+	for i := range want {
+		if got[i].Word != want[i].Word ||
+			math.Abs(got[i].StartTime-want[i].StartTime) > 1e-9 ||
+			math.Abs(got[i].EndTime-want[i].EndTime) > 1e-9 {
+			t.Errorf("word %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestEstimateWordTimeStamps(t *testing.T) {
+	// "Hello" weighs 2 syllables + 5 letters / 10 and "world" 1 + 5 / 10, so they split 2 seconds 2.5 to 1.5
+	got := EstimateWordTimeStamps("Hello world.", 2, nil)
+	assertWords(t, got, []WordTimeStamp{
+		{Word: "Hello", StartTime: 0, EndTime: 1.25},
+		{Word: "world", StartTime: 1.25, EndTime: 2},
+		{Word: ".", StartTime: 2, EndTime: 2},
+	})
+}
+
+func TestEstimateWordTimeStampsSnapsSilenceToPunctuation(t *testing.T) {
+	// One second of speech per word, the pause lands between them and is placed after the full stop
+	got := EstimateWordTimeStamps("One. Two.", 3, []Silence{{Start: 1, End: 2}})
+	assertWords(t, got, []WordTimeStamp{
+		{Word: "One", StartTime: 0, EndTime: 1},
+		{Word: ".", StartTime: 1, EndTime: 1},
+		{Word: "Two", StartTime: 2, EndTime: 3},
+		{Word: ".", StartTime: 3, EndTime: 3},
+	})
+}
+
+func TestEstimateWordTimeStampsIgnoresSilenceCoveringEverything(t *testing.T) {
+	got := EstimateWordTimeStamps("One Two", 2, []Silence{{Start: 0, End: 2}})
+	assertWords(t, got, []WordTimeStamp{
+		{Word: "One", StartTime: 0, EndTime: 1},
+		{Word: "Two", StartTime: 1, EndTime: 2},
+	})
+}
+
+func TestEstimateWordTimeStampsEmpty(t *testing.T) {
+	if got := EstimateWordTimeStamps("", 5, nil); got != nil {
+		t.Errorf("empty text gave %v", got)
+	}
+	if got := EstimateWordTimeStamps("Hello", 0, nil); got != nil {
+		t.Errorf("zero duration gave %v", got)
+	}
+	if got := EstimateWordTimeStamps("...", 5, nil); got != nil {
+		t.Errorf("punctuation only gave %v", got)
+	}
+}
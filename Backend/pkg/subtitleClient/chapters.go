@@ -0,0 +1,147 @@
+package subtitleclient
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+)
+
+const (
+	// A heading level is only used for chapters if the outline has at least this many of them
+	MIN_CHAPTERS = 2
+	// Chapters that would start closer than this many seconds to the one before are dropped
+	MIN_CHAPTER_LENGTH = 10.0
+)
+
+var headingPattern = regexp.MustCompile(`^(#{1,6})\s+(.+?)\s*#*\s*$`)
+
+// Chapter is a titled section of the narration, in seconds.
+type Chapter struct {
+	Title string
+	Start float64
+	End   float64
+}
+
+// heading is a markdown heading and how far into the outline's text it appears.
+type heading struct {
+	depth  int
+	title  string
+	offset int
+}
+
+// parseOutline finds the headings of a markdown document outside of code blocks along with the length
+// of the text in it, headings excluded.
+func parseOutline(markdown string) ([]heading, int) {
+	var headings []heading
+	length := 0
+	fenced := false
+	for _, line := range strings.Split(markdown, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fenced = !fenced
+		}
+		if !fenced {
+			if match := headingPattern.FindStringSubmatch(trimmed); match != nil {
+				title := strings.TrimSpace(strings.NewReplacer("*", "", "_", "", "`", "").Replace(match[2]))
+				if title != "" {
+					headings = append(headings, heading{depth: len(match[1]), title: title, offset: length})
+				}
+				continue
+			}
+		}
+		length += len(trimmed)
+	}
+	return headings, length
+}
+
+// sentenceStart moves seconds to the start of the nearest sentence, so chapters don't begin mid-sentence.
+func sentenceStart(words []WordTimeStamp, seconds float64) float64 {
+	best := seconds
+	distance := math.MaxFloat64
+	for i, word := range words {
+		if i > 0 && !endsSentence(words[i-1]) {
+			continue
+		}
+		if math.Abs(word.StartTime-seconds) < distance {
+			best, distance = word.StartTime, math.Abs(word.StartTime-seconds)
+		}
+	}
+	return best
+}
+
+// OutlineChapters turns the headings of the lecture notes into chapters of the narration. The narration follows
+// the lecture in the same order as the notes, so each section starts as far into the narration as it does into the
+// notes, moved to the nearest sentence. Only the shallowest heading level used at least MIN_CHAPTERS times counts.
+// words may be empty, duration falls back to the end of the last word.
+func OutlineChapters(markdown string, words []WordTimeStamp, duration float64) []Chapter {
+	if duration <= 0 && len(words) > 0 {
+		duration = words[len(words)-1].EndTime
+	}
+	headings, length := parseOutline(markdown)
+	if duration <= 0 || length == 0 {
+		return nil
+	}
+
+	// Step 1: Pick the heading level the notes are divided by
+	counts := make(map[int]int)
+	for _, heading := range headings {
+		counts[heading.depth]++
+	}
+	depth := 0
+	for level := 1; level <= 6; level++ {
+		if counts[level] >= MIN_CHAPTERS {
+			depth = level
+			break
+		}
+	}
+	if depth == 0 {
+		return nil
+	}
+
+	// Step 2: Place each section in the narration, the first always starts the video
+	var chapters []Chapter
+	for _, heading := range headings {
+		if heading.depth != depth {
+			continue
+		}
+		start := 0.0
+		if len(chapters) > 0 {
+			start = sentenceStart(words, duration*float64(heading.offset)/float64(length))
+			if start-chapters[len(chapters)-1].Start < MIN_CHAPTER_LENGTH || duration-start < MIN_CHAPTER_LENGTH {
+				continue
+			}
+		}
+		chapters = append(chapters, Chapter{Title: heading.title, Start: start})
+	}
+
+	// Step 3: Each chapter runs until the next one
+	for i := range chapters {
+		if i+1 < len(chapters) {
+			chapters[i].End = chapters[i+1].Start
+		} else {
+			chapters[i].End = duration
+		}
+	}
+	if len(chapters) < MIN_CHAPTERS {
+		return nil
+	}
+	return chapters
+}
+
+// GenerateFFMetadata writes the chapters in ffmpeg's metadata format, to be muxed in with -map_chapters.
+func GenerateFFMetadata(chapters []Chapter) string {
+	escape := strings.NewReplacer("\\", "\\\\", "=", "\\=", ";", "\\;", "#", "\\#", "\n", " ")
+	var builder strings.Builder
+	builder.WriteString(";FFMETADATA1\n")
+	for _, chapter := range chapters {
+		fmt.Fprintf(
+			&builder,
+			"[CHAPTER]\nTIMEBASE=1/1000\nSTART=%d\nEND=%d\ntitle=%s\n",
+			int64(math.Round(chapter.Start*1000)),
+			int64(math.Round(chapter.End*1000)),
+			escape.Replace(chapter.Title),
+		)
+	}
+	return builder.String()
+}
@@ -0,0 +1,140 @@
+package subtitleclient
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// MAX_CONCURRENT_TURNS bounds how many dialogue turns are sent to the TTS provider at once.
+const MAX_CONCURRENT_TURNS = 4
+
+// ValidateDialogue ensures the script only uses known speakers and has something to say.
+func ValidateDialogue(dialogue *Dialogue) error {
+	if len(dialogue.Turns) == 0 {
+		return fmt.Errorf("dialogue has no turns")
+	}
+	for i, turn := range dialogue.Turns {
+		if _, ok := SPEAKER_VOICES[turn.Speaker]; !ok {
+			return fmt.Errorf("turn %d has unknown speaker %q", i, turn.Speaker)
+		}
+		if strings.TrimSpace(turn.Text) == "" {
+			return fmt.Errorf("turn %d is empty", i)
+		}
+	}
+	return nil
+}
+
+// SynthesizeDialogue reads every turn with its speaker's voice and joins the clips into a single track.
+// The returned word timestamps are offset onto the joined track and tagged with their speaker.
+func SynthesizeDialogue(ctx context.Context, ttsClient SubtitleGenerationMethods, dialogue *Dialogue) ([]byte, []WordTimeStamp, error) {
+	if err := ValidateDialogue(dialogue); err != nil {
+		return nil, nil, err
+	}
+
+	// Step 1: Synthesize each turn, these are independent so they can run concurrently
+	clips := make([][]byte, len(dialogue.Turns))
+	turnWords := make([][]WordTimeStamp, len(dialogue.Turns))
+	errGroup, groupCtx := errgroup.WithContext(ctx)
+	errGroup.SetLimit(MAX_CONCURRENT_TURNS)
+	for i, turn := range dialogue.Turns {
+		errGroup.Go(func() error {
+			ttsResponse, err := ttsClient.GenerateTTS(turn.Text, SPEAKER_VOICES[turn.Speaker])
+			if err != nil {
+				return err
+			}
+			clips[i], err = ConvertB64ToAudio(ttsResponse.Audio)
+			if err != nil {
+				return err
+			}
+			turnWords[i] = ttsResponse.WordTimeStamps
+			if len(turnWords[i]) == 0 {
+				turnWords[i], err = AlignAudio(groupCtx, clips[i], turn.Text)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+	if err := errGroup.Wait(); err != nil {
+		log.Println("Failed to synthesize dialogue turn")
+		return nil, nil, err
+	}
+
+	// Step 2: Join the clips, every turn is shifted by the length of the clips before it
+	audio, durations, err := ConcatenateAudio(ctx, clips)
+	if err != nil {
+		return nil, nil, err
+	}
+	var words []WordTimeStamp
+	offset := 0.0
+	for i, turn := range dialogue.Turns {
+		for _, word := range turnWords[i] {
+			word.StartTime += offset
+			word.EndTime += offset
+			word.Speaker = turn.Speaker
+			words = append(words, word)
+		}
+		offset += durations[i]
+	}
+	return audio, words, nil
+}
+
+// ConcatenateAudio joins AAC clips end to end with ffmpeg's concat demuxer.
+// It returns the joined audio along with the probed duration of every clip.
+func ConcatenateAudio(ctx context.Context, clips [][]byte) ([]byte, []float64, error) {
+	workingDir, err := os.MkdirTemp("", "concat")
+	if err != nil {
+		log.Println("Failed to create temp directory")
+		return nil, nil, err
+	}
+	defer os.RemoveAll(workingDir)
+
+	durations := make([]float64, len(clips))
+	var concatList strings.Builder
+	for i, clip := range clips {
+		clipPath := filepath.Join(workingDir, fmt.Sprintf("clip-%03d.aac", i))
+		if err := os.WriteFile(clipPath, clip, 0644); err != nil {
+			log.Println("Failed to write audio clip")
+			return nil, nil, err
+		}
+		durations[i], err = ProbeAudioDuration(ctx, clipPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		fmt.Fprintf(&concatList, "file '%s'\n", filepath.Base(clipPath))
+	}
+	listPath := filepath.Join(workingDir, "clips.txt")
+	if err := os.WriteFile(listPath, []byte(concatList.String()), 0644); err != nil {
+		log.Println("Failed to write concat list")
+		return nil, nil, err
+	}
+
+	cmd := audioCommand(
+		ctx,
+		"ffmpeg",
+		"-y",
+		"-f", "concat",
+		"-safe", "0",
+		"-i", filepath.Base(listPath),
+		"-c:a", "aac",
+		"output.aac",
+	)
+	cmd.Dir = workingDir
+	if err := cmd.Run(); err != nil {
+		log.Println("Failed to concatenate audio clips")
+		return nil, nil, err
+	}
+	audio, err := os.ReadFile(filepath.Join(workingDir, "output.aac"))
+	if err != nil {
+		log.Println("Failed to read concatenated audio")
+		return nil, nil, err
+	}
+	return audio, durations, nil
+}
@@ -0,0 +1,128 @@
+package subtitleclient
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+const (
+	// A final part shorter than this fraction of the target is merged into the one before it,
+	// as long as that part stays within PART_MAX_OVERRUN of the target
+	MIN_PART_FRACTION = 0.25
+	PART_MAX_OVERRUN  = 1.1
+	// LAST_PART_LABEL_DURATION keeps the label up until the video ends, -shortest cuts it with the audio
+	LAST_PART_LABEL_DURATION = 60 * 60.0
+)
+
+// Part is a slice of the narration rendered as its own short video.
+type Part struct {
+	// Start and End are seconds into the narration, an End of 0 means the part runs to the end of it
+	Start float64
+	End   float64
+	// Words are timed from the start of the part
+	Words []WordTimeStamp
+}
+
+// boundary is a place between two sentences the narration may be cut.
+type boundary struct {
+	// index is the last word of the sentence before the cut
+	index int
+	// cut is halfway through the pause between the sentences
+	cut float64
+}
+
+func endsSentence(word WordTimeStamp) bool {
+	return strings.HasSuffix(word.Word, ".") || strings.HasSuffix(word.Word, "?") || strings.HasSuffix(word.Word, "!")
+}
+
+// SplitParts cuts the narration at sentence boundaries into parts of at most target seconds.
+// A sentence longer than target becomes a part of its own.
+func SplitParts(words []WordTimeStamp, target float64) []Part {
+	if len(words) == 0 || target <= 0 {
+		return []Part{{Words: words}}
+	}
+	var boundaries []boundary
+	for i := 0; i < len(words)-1; i++ {
+		if endsSentence(words[i]) {
+			boundaries = append(boundaries, boundary{index: i, cut: (words[i].EndTime + words[i+1].StartTime) / 2})
+		}
+	}
+
+	// Step 1: Close a part at the last boundary that fits once the next one would overrun the target
+	var cuts []boundary
+	start := 0.0
+	var candidate *boundary
+	for i := 0; i < len(boundaries); i++ {
+		if boundaries[i].cut-start <= target {
+			candidate = &boundaries[i]
+			continue
+		}
+		if candidate == nil {
+			candidate = &boundaries[i]
+		}
+		cuts = append(cuts, *candidate)
+		start = candidate.cut
+		if candidate.index < boundaries[i].index {
+			// Measure this boundary again from the start of the new part
+			i--
+		}
+		candidate = nil
+	}
+
+	// Step 2: Fold a short tail into the part before it
+	end := words[len(words)-1].EndTime
+	if len(cuts) > 0 && end-start < target*MIN_PART_FRACTION {
+		previousStart := 0.0
+		if len(cuts) > 1 {
+			previousStart = cuts[len(cuts)-2].cut
+		}
+		if end-previousStart <= target*PART_MAX_OVERRUN {
+			cuts = cuts[:len(cuts)-1]
+		}
+	}
+
+	// Step 3: Re-base every part's words on its own start
+	parts := make([]Part, 0, len(cuts)+1)
+	first, start := 0, 0.0
+	for _, cut := range cuts {
+		parts = append(parts, Part{
+			Start: start,
+			End:   cut.cut,
+			Words: shiftWords(words[first:cut.index+1], -start, cut.cut-start),
+		})
+		first, start = cut.index+1, cut.cut
+	}
+	parts = append(parts, Part{
+		Start: start,
+		Words: shiftWords(words[first:], -start, math.MaxFloat64),
+	})
+	return parts
+}
+
+// assTime formats seconds as an ASS timestamp, H:MM:SS.CC.
+func assTime(seconds float64) string {
+	centiseconds := int(math.Round(seconds * 100))
+	return fmt.Sprintf("%d:%02d:%02d.%02d", centiseconds/360000, centiseconds/6000%60, centiseconds/100%60, centiseconds%100)
+}
+
+// GeneratePartASSContent lays out the subtitles of a part and labels it "Part N of M" below the logo.
+func GeneratePartASSContent(part Part, number int, count int, canvas Canvas) string {
+	assContent := GenerateASSContentForCanvas(GenerateSubtitleLines(part.Words), canvas)
+	// The last part runs until the narration ends, however long its closing music is
+	duration := LAST_PART_LABEL_DURATION
+	if part.End > 0 {
+		duration = part.End - part.Start
+	}
+	assContent += fmt.Sprintf(
+		"Dialogue: 1,%s,%s,Default,{\\an8\\pos(%d,%d)\\fs%d}Part %d of %d\n",
+		assTime(0),
+		assTime(duration),
+		canvas.Width/2,
+		canvas.Height/5,
+		canvas.Scale(36),
+		number,
+		count,
+	)
+	return assContent
+}
@@ -0,0 +1,149 @@
+package subtitleclient
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// EBU R128 targets, -16 LUFS is the usual level for speech on mobile devices
+	LOUDNESS_TARGET     = -16.0
+	LOUDNESS_TRUE_PEAK  = -1.5
+	LOUDNESS_RANGE      = 11.0
+	SILENCE_EDGE_BUFFER = 0.15
+)
+
+// MusicTrack is a background music bed that can be mixed under the narration.
+type MusicTrack struct {
+	ID          string  `json:"id"`
+	DisplayName string  `json:"displayName"`
+	S3Key       string  `json:"s3Key"`
+	Volume      float64 `json:"volume"`
+}
+
+// MUSIC_CATALOG lists the music beds users may choose from, keyed by ID. Terraform uploads the tracks from
+// static/music, named after the last part of their S3Key, which is kept out of the repository like the
+// backgrounds. Jobs asking for a track that was not uploaded are rejected when they are submitted.
+var MUSIC_CATALOG = map[string]MusicTrack{
+	"lofi": {
+		ID:          "lofi",
+		DisplayName: "Lo-fi Beats",
+		S3Key:       "music/lofi.mp3",
+		Volume:      0.25,
+	},
+	"piano": {
+		ID:          "piano",
+		DisplayName: "Soft Piano",
+		S3Key:       "music/piano.mp3",
+		Volume:      0.3,
+	},
+	"ambient": {
+		ID:          "ambient",
+		DisplayName: "Ambient Pads",
+		S3Key:       "music/ambient.mp3",
+		Volume:      0.3,
+	},
+}
+
+// ProcessAudio normalizes the loudness of the narration, trims silence from both ends and,
+// when music is given, mixes it underneath with sidechain ducking.
+// The word timestamps are shifted by the amount trimmed from the start so they stay in sync.
+func ProcessAudio(ctx context.Context, audio []byte, words []WordTimeStamp, music []byte, musicTrack *MusicTrack) ([]byte, []WordTimeStamp, error) {
+	workingDir, err := os.MkdirTemp("", "postprocess")
+	if err != nil {
+		log.Println("Failed to create temp directory")
+		return nil, nil, err
+	}
+	defer os.RemoveAll(workingDir)
+
+	inputPath := filepath.Join(workingDir, "input.aac")
+	if err := os.WriteFile(inputPath, audio, 0644); err != nil {
+		log.Println("Failed to write audio")
+		return nil, nil, err
+	}
+	duration, err := ProbeAudioDuration(ctx, inputPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	silences, err := DetectSilences(ctx, inputPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	trimStart, trimEnd := trimBounds(silences, duration)
+
+	// Step 1: Trim and normalize the narration
+	filter := fmt.Sprintf(
+		"[0:a]atrim=start=%.3f:end=%.3f,asetpts=PTS-STARTPTS,loudnorm=I=%.1f:TP=%.1f:LRA=%.1f[voice]",
+		trimStart, trimEnd, LOUDNESS_TARGET, LOUDNESS_TRUE_PEAK, LOUDNESS_RANGE,
+	)
+	args := []string{"-y", "-i", filepath.Base(inputPath)}
+
+	// Step 2: Optionally loop the music bed under the narration, ducking it whenever someone speaks
+	if music != nil && musicTrack != nil {
+		musicPath := filepath.Join(workingDir, "music"+filepath.Ext(musicTrack.S3Key))
+		if err := os.WriteFile(musicPath, music, 0644); err != nil {
+			log.Println("Failed to write music")
+			return nil, nil, err
+		}
+		args = append(args, "-stream_loop", "-1", "-i", filepath.Base(musicPath))
+		filter += fmt.Sprintf(
+			";[voice]asplit=2[voice_main][voice_key];[1:a]volume=%.2f[music];[music][voice_key]sidechaincompress=threshold=0.03:ratio=8:attack=20:release=400[ducked];[voice_main][ducked]amix=inputs=2:duration=first:normalize=0[output]",
+			musicTrack.Volume,
+		)
+	} else {
+		filter += ";[voice]anull[output]"
+	}
+	args = append(args,
+		"-filter_complex", filter,
+		"-map", "[output]",
+		"-c:a", "aac",
+		"-b:a", "128k",
+		// loudnorm upsamples to 192kHz internally, bring it back down
+		"-ar", "44100",
+		"output.aac",
+	)
+	cmd := audioCommand(ctx, "ffmpeg", args...)
+	cmd.Dir = workingDir
+	if err := cmd.Run(); err != nil {
+		log.Println("Failed to post-process audio")
+		return nil, nil, err
+	}
+	processed, err := os.ReadFile(filepath.Join(workingDir, "output.aac"))
+	if err != nil {
+		log.Println("Failed to read processed audio")
+		return nil, nil, err
+	}
+	return processed, shiftWords(words, -trimStart, trimEnd-trimStart), nil
+}
+
+// trimBounds finds where speech starts and ends, leaving a small buffer of silence on either side.
+func trimBounds(silences []Silence, duration float64) (float64, float64) {
+	start, end := 0.0, duration
+	for _, silence := range silences {
+		if silence.Start <= SILENCE_EDGE_BUFFER {
+			start = math.Max(silence.End-SILENCE_EDGE_BUFFER, 0)
+		}
+		if silence.End >= duration-SILENCE_EDGE_BUFFER {
+			end = math.Min(silence.Start+SILENCE_EDGE_BUFFER, duration)
+		}
+	}
+	if end <= start {
+		return 0, duration
+	}
+	return start, end
+}
+
+// shiftWords moves every word by offset seconds, clamping the result to [0, limit].
+func shiftWords(words []WordTimeStamp, offset float64, limit float64) []WordTimeStamp {
+	shifted := make([]WordTimeStamp, len(words))
+	for i, word := range words {
+		word.StartTime = math.Min(math.Max(word.StartTime+offset, 0), limit)
+		word.EndTime = math.Min(math.Max(word.EndTime+offset, 0), limit)
+		shifted[i] = word
+	}
+	return shifted
+}
@@ -0,0 +1,52 @@
+package subtitleclient
+
+import (
+	"math"
+	"testing"
+)
+
+func TestTrimBounds(t *testing.T) {
+	cases := []struct {
+		name     string
+		silences []Silence
+		start    float64
+		end      float64
+	}{
+		{"no silence", nil, 0, 10},
+		{"leading and trailing", []Silence{{Start: 0, End: 1}, {Start: 9, End: 10}}, 0.85, 9.15},
+		{"only in the middle", []Silence{{Start: 4, End: 5}}, 0, 10},
+		{"leading within the buffer", []Silence{{Start: 0.1, End: 0.1}}, 0, 10},
+		{"starts just inside the buffer", []Silence{{Start: 0.15, End: 2}}, 1.85, 10},
+		{"starts after the buffer", []Silence{{Start: 0.2, End: 2}}, 0, 10},
+		{"trailing past the end", []Silence{{Start: 8, End: 10.5}}, 0, 8.15},
+		{"all silence", []Silence{{Start: 0, End: 10}}, 0, 10},
+	}
+	for _, c := range cases {
+		start, end := trimBounds(c.silences, 10)
+		if math.Abs(start-c.start) > 1e-9 || math.Abs(end-c.end) > 1e-9 {
+			t.Errorf("%s: trimBounds() = (%v, %v), want (%v, %v)", c.name, start, end, c.start, c.end)
+		}
+	}
+}
+
+func TestShiftWords(t *testing.T) {
+	words := []WordTimeStamp{
+		{Word: "Hello", StartTime: 0.5, EndTime: 1.5},
+		{Word: "world", StartTime: 1.5, EndTime: 2.5},
+		{Word: "again", StartTime: 9.5, EndTime: 10},
+	}
+	got := shiftWords(words, -1, 8)
+	want := []WordTimeStamp{
+		{Word: "Hello", StartTime: 0, EndTime: 0.5},
+		{Word: "world", StartTime: 0.5, EndTime: 1.5},
+		{Word: "again", StartTime: 8, EndTime: 8},
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("word %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+	if words[0].StartTime != 0.5 {
+		t.Error("shiftWords changed its input")
+	}
+}
@@ -0,0 +1,37 @@
+package subtitleclient
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// srtTime formats seconds as an SRT timestamp, HH:MM:SS,mmm.
+func srtTime(seconds float64) string {
+	milliseconds := int(math.Round(math.Max(seconds, 0) * 1000))
+	return fmt.Sprintf("%02d:%02d:%02d,%03d", milliseconds/3600000, milliseconds/60000%60, milliseconds/1000%60, milliseconds%1000)
+}
+
+// GenerateSRTContent writes the subtitle lines as plain SRT cues, for players that show captions themselves.
+// There is no karaoke highlighting, each line is shown while it is read.
+func GenerateSRTContent(lines []LineTimeStamp) string {
+	var builder strings.Builder
+	for i, line := range lines {
+		var text strings.Builder
+		for j, word := range line.Line {
+			if j > 0 && !isPunctuation(word) {
+				text.WriteString(" ")
+			}
+			text.WriteString(word.Word)
+		}
+		fmt.Fprintf(
+			&builder,
+			"%d\n%s --> %s\n%s\n\n",
+			i+1,
+			srtTime(line.Line[0].StartTime),
+			srtTime(line.Line[len(line.Line)-1].EndTime),
+			text.String(),
+		)
+	}
+	return builder.String()
+}
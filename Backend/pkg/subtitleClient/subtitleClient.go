@@ -8,10 +8,13 @@ import (
 	"log"
 	"net/http"
 	"os"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/language"
 )
 
 type SubtitleGenerationMethods interface {
-	GenerateTTS(textInput string) (*LemonFoxResponse, error)
+	GenerateTTS(textInput string, voice string) (*LemonFoxResponse, error)
 }
 
 type SubtitleClient struct {
@@ -24,11 +27,11 @@ func NewSubtitleClient() SubtitleGenerationMethods {
 	}
 }
 
-func (sc *SubtitleClient) GenerateTTS(textInput string) (*LemonFoxResponse, error) {
+func (sc *SubtitleClient) GenerateTTS(textInput string, voice string) (*LemonFoxResponse, error) {
 	body, err := json.Marshal(
 		map[string]interface{}{
 			"input": textInput,
-			"voice": "adam",
+			"voice": voice,
 			// "speed":           1.2,
 			"word_timestamps": true,
 			"response_format": "aac",
@@ -88,7 +91,14 @@ func GenerateSubtitleLines(words []WordTimeStamp) []LineTimeStamp {
 			strlen = len(word.Word)
 			continue
 		}
-		if strlen+len(word.Word)+1 <= MAX_CHARS_PER_LINE {
+		if word.Speaker != line[0].Speaker {
+			// A new speaker always starts a new line
+			lines = append(lines, LineTimeStamp{
+				Line: line,
+			})
+			line = []WordTimeStamp{word}
+			strlen = len(word.Word)
+		} else if strlen+len(word.Word)+1 <= MAX_CHARS_PER_LINE {
 			// Add word to line
 			line = append(line, word)
 			strlen += len(word.Word) + 1 // Add 1 for prepended space
@@ -116,7 +126,12 @@ func GenerateSubtitleLines(words []WordTimeStamp) []LineTimeStamp {
 }
 
 func GenerateASSContent(lines []LineTimeStamp) string {
-	/* STATIC HEADER
+	return GenerateASSContentForCanvas(lines, DEFAULT_CANVAS)
+}
+
+// GenerateASSContentForCanvas lays the subtitles out for a video of the given size.
+func GenerateASSContentForCanvas(lines []LineTimeStamp, canvas Canvas) string {
+	/* HEADER (shown for the default 576x1024 canvas, sizes scale with the canvas)
 	* [Script Info]
 	* PlayResX: 576
 	* PlayResY: 1024
@@ -125,14 +140,26 @@ func GenerateASSContent(lines []LineTimeStamp) string {
 	* [V4+ Styles]
 	* Format: Name, Fontname, Fontsize, PrimaryColour, SecondaryColour, OutlineColour, BackColour, Bold, Italic, Underline, StrikeOut, ScaleX, ScaleY, Spacing, Angle, BorderStyle, Outline, Shadow, Alignment, MarginL, MarginR, MarginV, Encoding
 	* Style: Default,Berlin Sans FB,50,&H00FFFFFF,&H000000FF,&H00000000,&H00000000,-1,0,0,0,100,100,0,0,1,4,4,2,10,10,10,1
+	* Style: Host,Berlin Sans FB,50,&H00FFE0B0,&H000000FF,&H00000000,&H00000000,-1,0,0,0,100,100,0,0,1,4,4,2,10,10,10,1
+	* Style: Expert,Berlin Sans FB,50,&H00B0FFC8,&H000000FF,&H00000000,&H00000000,-1,0,0,0,100,100,0,0,1,4,4,2,10,10,10,1
 	*
 	* [Events]
 	* Format: Layer, Start, End, Style, Text
 	 */
-	AASContent := "[Script Info]\nPlayResX: 576\nPlayResY: 1024\nWrapStyle: 0\n\n[V4+ Styles]\nFormat: Name, Fontname, Fontsize, PrimaryColour, SecondaryColour, OutlineColour, BackColour, Bold, Italic, Underline, StrikeOut, ScaleX, ScaleY, Spacing, Angle, BorderStyle, Outline, Shadow, Alignment, MarginL, MarginR, MarginV, Encoding\nStyle: Default,Berlin Sans FB,50,&H00FFFFFF,&H000000FF,&H00000000,&H00000000,-1,0,0,0,100,100,0,0,1,4,4,2,10,10,10,1\n\n[Events]\nFormat: Layer, Start, End, Style, Text\n"
+	fontSize, border := canvas.Scale(50), canvas.Scale(4)
+	AASContent := fmt.Sprintf("[Script Info]\nPlayResX: %d\nPlayResY: %d\nWrapStyle: 0\n\n[V4+ Styles]\nFormat: Name, Fontname, Fontsize, PrimaryColour, SecondaryColour, OutlineColour, BackColour, Bold, Italic, Underline, StrikeOut, ScaleX, ScaleY, Spacing, Angle, BorderStyle, Outline, Shadow, Alignment, MarginL, MarginR, MarginV, Encoding\n", canvas.Width, canvas.Height)
+	for _, style := range [][2]string{{"Default", "&H00FFFFFF"}, {"Host", "&H00FFE0B0"}, {"Expert", "&H00B0FFC8"}} {
+		AASContent += fmt.Sprintf("Style: %s,Berlin Sans FB,%d,%s,&H000000FF,&H00000000,&H00000000,-1,0,0,0,100,100,0,0,1,%d,%d,2,10,10,10,1\n", style[0], fontSize, style[1], border, border)
+	}
+	AASContent += "\n[Events]\nFormat: Layer, Start, End, Style, Text\n"
 	for _, line := range lines {
 		lineStart, lineEnd := line.Duration()
-		AASContent += fmt.Sprintf("Dialogue: 0,%s,%s,Default,{\\an5\\pos(288,512)\\fscx60\\fscy60\\alpha&HFF&\\t(0,35,\\alpha&H00&)\\t(0,35,\\fscx90\\fscy90)\\t(35,75,\\fscx70\\fscy70)}", lineStart, lineEnd)
+		// Podcast lines are coloured by speaker, narration is always white
+		style, baseColor := "Default", BASE_COLOR
+		if color, ok := SPEAKER_COLORS[line.Speaker()]; ok {
+			style, baseColor = cases.Title(language.English).String(line.Speaker()), color
+		}
+		AASContent += fmt.Sprintf("Dialogue: 0,%s,%s,%s,{\\an5\\pos(%d,%d)\\fscx60\\fscy60\\alpha&HFF&\\t(0,35,\\alpha&H00&)\\t(0,35,\\fscx90\\fscy90)\\t(35,75,\\fscx70\\fscy70)}", lineStart, lineEnd, style, canvas.Width/2, canvas.Height/2)
 		for i, word := range line.Line {
 			// Format should generate as follows: {\1c&HFFFFFF&\t(start,start,HIGHLIGHT_COLOR)\t(end,end,\1c&HFFFFFF&)}Word
 			// start is the time since the beginning of the line (in ms)
@@ -141,14 +168,14 @@ func GenerateASSContent(lines []LineTimeStamp) string {
 			endOffset := int((word.EndTime - line.Line[0].StartTime) * 1000)
 			if i == 0 {
 				// First word in line, make it start with the highlight color
-				AASContent += fmt.Sprintf("{%s\\t(%d,%d,\\1c&HFFFFFF&)}%s", HIGHLIGHT_COLOR, endOffset, endOffset, word.Word)
+				AASContent += fmt.Sprintf("{%s\\t(%d,%d,\\1c%s)}%s", HIGHLIGHT_COLOR, endOffset, endOffset, baseColor, word.Word)
 				continue
 			}
 			if !isPunctuation(word) {
 				// Not punctuation, so add a space
 				AASContent += " "
 			}
-			AASContent += fmt.Sprintf("{\\1c&HFFFFFF&\\t(%d,%d,%s)\\t(%d,%d,\\1c&HFFFFFF&)}%s", startOffset, startOffset, HIGHLIGHT_COLOR, endOffset, endOffset, word.Word)
+			AASContent += fmt.Sprintf("{\\1c%s\\t(%d,%d,%s)\\t(%d,%d,\\1c%s)}%s", baseColor, startOffset, startOffset, HIGHLIGHT_COLOR, endOffset, endOffset, baseColor, word.Word)
 		}
 		AASContent += "\n"
 	}
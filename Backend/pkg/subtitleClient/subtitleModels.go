@@ -1,20 +1,69 @@
 package subtitleclient
 
-import "fmt"
+import (
+	"fmt"
+	"math"
+)
 
 const MAX_CHARS_PER_LINE = 25
 const HIGHLIGHT_COLOR = "\\1c&H639fc5&"
+const BASE_COLOR = "&HFFFFFF&"
+const NARRATOR_VOICE = "adam"
+
+const (
+	SPEAKER_HOST   = "host"
+	SPEAKER_EXPERT = "expert"
+)
+
+// SPEAKER_VOICES maps each podcast speaker to the LemonFox voice that reads their turns.
+var SPEAKER_VOICES = map[string]string{
+	SPEAKER_HOST:   "sarah",
+	SPEAKER_EXPERT: "adam",
+}
+
+// SPEAKER_COLORS maps each podcast speaker to the base colour of their subtitle lines.
+var SPEAKER_COLORS = map[string]string{
+	SPEAKER_HOST:   "&HFFE0B0&",
+	SPEAKER_EXPERT: "&HB0FFC8&",
+}
+
+// Canvas is the frame size subtitles are laid out for, it becomes the ASS PlayRes.
+type Canvas struct {
+	Width  int
+	Height int
+}
+
+// DEFAULT_CANVAS is the vertical frame the subtitle sizes were designed against.
+var DEFAULT_CANVAS = Canvas{Width: 576, Height: 1024}
+
+// Scale converts a size designed for DEFAULT_CANVAS to this canvas, keeping text legible on wide frames.
+func (c Canvas) Scale(size int) int {
+	scale := math.Min(float64(c.Width)/float64(DEFAULT_CANVAS.Width), float64(c.Height)/float64(DEFAULT_CANVAS.Height))
+	return int(math.Round(float64(size) * scale))
+}
 
 type WordTimeStamp struct {
 	Word      string  `json:"word"`
 	StartTime float64 `json:"start"`
 	EndTime   float64 `json:"end"`
+	Speaker   string  `json:"speaker,omitempty"`
 }
 
 type LineTimeStamp struct {
 	Line []WordTimeStamp `json:"line"`
 }
 
+// DialogueTurn is a single turn of a two-voice podcast script.
+type DialogueTurn struct {
+	Speaker string `json:"speaker"`
+	Text    string `json:"text"`
+}
+
+// Dialogue is the podcast script generated by the LLM and stored as Dialogue.json.
+type Dialogue struct {
+	Turns []DialogueTurn `json:"turns"`
+}
+
 func (w WordTimeStamp) Duration() (string, string) {
 	// Returns the duration of the word in HH:MM:SS.MS format
 	// Times are given in seconds
@@ -54,3 +103,8 @@ type LemonFoxResponse struct {
 	Audio          string          `json:"audio"`
 	WordTimeStamps []WordTimeStamp `json:"word_timestamps"`
 }
+
+// Speaker returns who reads the line, lines never mix speakers.
+func (l LineTimeStamp) Speaker() string {
+	return l.Line[0].Speaker
+}
@@ -0,0 +1,177 @@
+package tasks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	dynamo "github.com/Kanishk-K/UniteDownloader/Backend/pkg/dynamoClient"
+	"github.com/Kanishk-K/UniteDownloader/Backend/pkg/ffmpeg"
+	s3client "github.com/Kanishk-K/UniteDownloader/Backend/pkg/s3Client"
+	"github.com/Kanishk-K/UniteDownloader/Backend/pkg/videoutil"
+	"github.com/hibiken/asynq"
+)
+
+const BackgroundIngestTask = "backgroundIngest"
+
+type BackgroundIngestPayload struct {
+	UserID       string `json:"userID"`
+	BackgroundID string `json:"backgroundID"`
+}
+
+type IngestBackgroundProcess struct {
+	s3Client     s3client.S3Methods
+	dynamoClient dynamo.DynamoMethods
+}
+
+func NewIngestBackgroundProcess(s3Client s3client.S3Methods, dynamoClient dynamo.DynamoMethods) *IngestBackgroundProcess {
+	return &IngestBackgroundProcess{s3Client, dynamoClient}
+}
+
+func NewBackgroundIngestTask(userID string, backgroundID string) (*asynq.Task, error) {
+	taskInfo := BackgroundIngestPayload{
+		UserID:       userID,
+		BackgroundID: backgroundID,
+	}
+	payload, err := json.Marshal(taskInfo)
+	if err != nil {
+		return nil, err
+	}
+	return asynq.NewTask(BackgroundIngestTask, payload), nil
+}
+
+// HandleBackgroundIngestTask checks a user's uploaded clip and transcodes it into a private background.
+func (p *IngestBackgroundProcess) HandleBackgroundIngestTask(ctx context.Context, t *asynq.Task) error {
+	var payload BackgroundIngestPayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		return err
+	}
+	background, err := p.dynamoClient.GetUserBackground(ctx, payload.UserID, payload.BackgroundID)
+	if err != nil {
+		return err
+	}
+	if background == nil || background.Status != dynamo.BackgroundStatusPending {
+		log.Printf("Background %s for %s is not awaiting ingest", payload.BackgroundID, payload.UserID)
+		return fmt.Errorf("background is not pending: %w", asynq.SkipRetry)
+	}
+	uploadKey := videoutil.UserUploadKey(payload.UserID, payload.BackgroundID)
+
+	workingDir, err := os.MkdirTemp("", payload.BackgroundID)
+	if err != nil {
+		log.Printf("Error creating temp directory: %v", err)
+		return err
+	}
+	defer os.RemoveAll(workingDir)
+
+	// Step 1: Download the upload, refusing anything over the size limit
+	uploadPath := filepath.Join(workingDir, "upload")
+	uploadFp, err := os.Create(uploadPath)
+	if err != nil {
+		log.Printf("Error creating upload file: %v", err)
+		return err
+	}
+	defer uploadFp.Close()
+	uploadReader, err := p.s3Client.ReadFile(ctx, BUCKET, uploadKey)
+	if err != nil {
+		log.Printf("Error reading upload from S3: %v", err)
+		return err
+	}
+	written, err := io.Copy(uploadFp, io.LimitReader(uploadReader, videoutil.MAX_UPLOAD_SIZE+1))
+	uploadReader.Close()
+	if err != nil {
+		log.Printf("Error putting bytes into upload file: %v", err)
+		return err
+	}
+	if written > videoutil.MAX_UPLOAD_SIZE {
+		return p.reject(ctx, payload, fmt.Sprintf("video must be smaller than %d MB", videoutil.MAX_UPLOAD_SIZE/1024/1024))
+	}
+
+	// Step 2: Check the clip is something we can render on
+	probe, err := videoutil.ProbeVideo(ctx, uploadPath)
+	if err != nil {
+		return p.reject(ctx, payload, "file is not a readable video")
+	}
+	if err := videoutil.ValidateUpload(probe); err != nil {
+		return p.reject(ctx, payload, err.Error())
+	}
+
+	// Step 3: Transcode into the mezzanine format
+	command := ffmpeg.New()
+	command.Input(filepath.Base(uploadPath))
+	command.Output("output.mp4").With(videoutil.MezzanineArgs()...)
+	log.Printf("Transcoding background %s for %s", payload.BackgroundID, payload.UserID)
+	if err := ffmpeg.Run(command.Cmd(ctx, workingDir)); err != nil {
+		if ctx.Err() != nil {
+			// Cancelled or timed out, the upload stays pending so asynq can run it again
+			return ctx.Err()
+		}
+		log.Printf("Error in running ffmpeg command: %v", err)
+		var exitErr *ffmpeg.ExitError
+		if errors.As(err, &exitErr) && exitErr.Transient() && canRetry(ctx) {
+			return err
+		}
+		return p.reject(ctx, payload, "video could not be converted")
+	}
+	outputPath := filepath.Join(workingDir, "output.mp4")
+	outputProbe, err := videoutil.ProbeVideo(ctx, outputPath)
+	if err != nil {
+		return err
+	}
+
+	// Step 4: Store the clip and make it selectable
+	outputFp, err := os.Open(outputPath)
+	if err != nil {
+		log.Printf("Failed to open output Mp4 file: %v", err)
+		return err
+	}
+	defer outputFp.Close()
+	s3Key := videoutil.UserBackgroundKey(payload.UserID, payload.BackgroundID)
+	err = p.s3Client.UploadFile(ctx, BUCKET, s3Key, outputFp, "video/mp4")
+	if err != nil {
+		log.Printf("Failed to upload background to S3: %v", err)
+		return err
+	}
+	err = p.dynamoClient.CompleteUserBackground(
+		ctx,
+		payload.UserID,
+		payload.BackgroundID,
+		s3Key,
+		outputProbe.Duration,
+		videoutil.AspectRatio(outputProbe.Width, outputProbe.Height),
+	)
+	if err != nil {
+		// Don't leave a clip behind that no record points to, even if the task was cancelled
+		p.deleteObject(context.WithoutCancel(ctx), s3Key)
+		return err
+	}
+	p.deleteUpload(ctx, uploadKey)
+	log.Printf("Completed background %s for %s", payload.BackgroundID, payload.UserID)
+	return nil
+}
+
+// reject marks the upload as unusable so the extension can tell the user why, retrying would not help.
+func (p *IngestBackgroundProcess) reject(ctx context.Context, payload BackgroundIngestPayload, reason string) error {
+	log.Printf("Rejecting background %s for %s: %s", payload.BackgroundID, payload.UserID, reason)
+	err := p.dynamoClient.RejectUserBackground(ctx, payload.UserID, payload.BackgroundID, reason)
+	if err != nil {
+		return err
+	}
+	p.deleteUpload(ctx, videoutil.UserUploadKey(payload.UserID, payload.BackgroundID))
+	return fmt.Errorf("background rejected: %s: %w", reason, asynq.SkipRetry)
+}
+
+// deleteUpload removes the original clip, the bucket lifecycle rule cleans up anything missed here.
+func (p *IngestBackgroundProcess) deleteUpload(ctx context.Context, uploadKey string) {
+	p.deleteObject(ctx, uploadKey)
+}
+
+func (p *IngestBackgroundProcess) deleteObject(ctx context.Context, key string) {
+	if err := p.s3Client.DeleteFile(ctx, BUCKET, key); err != nil {
+		log.Printf("Failed to delete %s: %v", key, err)
+	}
+}
@@ -0,0 +1,141 @@
+package tasks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	cognitoclient "github.com/Kanishk-K/UniteDownloader/Backend/pkg/cognitoClient"
+	dynamo "github.com/Kanishk-K/UniteDownloader/Backend/pkg/dynamoClient"
+	"github.com/Kanishk-K/UniteDownloader/Backend/pkg/ffmpeg"
+	sesclient "github.com/Kanishk-K/UniteDownloader/Backend/pkg/sesClient"
+	"github.com/hibiken/asynq"
+)
+
+// FailureInvalidRequest is used when the request itself can never be rendered, e.g. an unknown preset.
+const FailureInvalidRequest = "invalid_request"
+
+// FailureNarration is used when the video never started because the job's narration failed.
+const FailureNarration = "narration"
+
+// FAILURE_CATEGORIES describes each failure class to the user in the failure email.
+var FAILURE_CATEGORIES = map[string]string{
+	ffmpeg.FailureMissingInput: "The lecture's narration or subtitles could not be found.",
+	ffmpeg.FailureInvalidInput: "The lecture's narration or background video could not be read.",
+	ffmpeg.FailureBadSubtitles: "The subtitles for the lecture could not be drawn.",
+	ffmpeg.FailureDiskFull:     "Our video server ran out of space.",
+	ffmpeg.FailureEncoder:      "The video could not be encoded.",
+	ffmpeg.FailureKilled:       "The video took too long to render.",
+	ffmpeg.FailureUnknown:      "Something unexpected went wrong while making the video.",
+	FailureInvalidRequest:      "The requested video options are no longer available.",
+	FailureNarration:           "The narration for the lecture could not be generated.",
+}
+
+// failureCategory describes a failure class to the user.
+func failureCategory(class string) string {
+	if category, ok := FAILURE_CATEGORIES[class]; ok {
+		return category
+	}
+	return FAILURE_CATEGORIES[ffmpeg.FailureUnknown]
+}
+
+// recordedFailure is returned by failVideo so the handler knows the failure was already recorded.
+type recordedFailure struct {
+	err error
+}
+
+func (rf *recordedFailure) Error() string { return rf.err.Error() }
+func (rf *recordedFailure) Unwrap() error { return rf.err }
+
+// canRetry reports whether asynq will run the task again if it fails now.
+func canRetry(ctx context.Context) bool {
+	retryCount, ok := asynq.GetRetryCount(ctx)
+	if !ok {
+		return false
+	}
+	maxRetry, ok := asynq.GetMaxRetry(ctx)
+	if !ok {
+		return false
+	}
+	return retryCount < maxRetry
+}
+
+// failVideo records why the video failed on its VideoRequests item and in the task result, which asynq keeps
+// in the archive. Transient failures are returned as is so asynq retries them, deterministic ones skip retrying.
+func (p *GenerateVideoProcess) failVideo(ctx context.Context, t *asynq.Task, payload VideoGenerationPayload, class string, transient bool, err error) error {
+	detail := err.Error()
+	var exitErr *ffmpeg.ExitError
+	if errors.As(err, &exitErr) {
+		detail = exitErr.LastLine()
+		log.Printf("ffmpeg stderr for %s:\n%s", payload.EntryID, exitErr.Tail)
+	}
+	retryCount, _ := asynq.GetRetryCount(ctx)
+	failure := dynamo.VideoFailure{
+		Class:     class,
+		Detail:    detail,
+		Retryable: transient && canRetry(ctx),
+		Attempt:   retryCount + 1,
+		FailedOn:  time.Now().Format("2006-01-02 15:04:05"),
+	}
+	log.Printf("Video %s for %s failed (%s, retryable: %t): %s", payload.VideoID, payload.EntryID, failure.Class, failure.Retryable, failure.Detail)
+	if recordErr := p.dynamoClient.RecordVideoFailure(ctx, payload.EntryID, payload.VideoID, failure); recordErr != nil {
+		log.Printf("Failed to record video failure: %v", recordErr)
+	}
+	if result, marshalErr := json.Marshal(failure); marshalErr == nil {
+		if _, writeErr := t.ResultWriter().Write(result); writeErr != nil {
+			log.Printf("Failed to write task failure: %v", writeErr)
+		}
+	}
+	if !failure.Retryable {
+		// asynq archives the task after this, it's the last chance to tell the user
+		p.notifyFailure(ctx, payload, class)
+	}
+	if transient || errors.Is(err, asynq.SkipRetry) {
+		return &recordedFailure{err}
+	}
+	return &recordedFailure{fmt.Errorf("%v: %w", err, asynq.SkipRetry)}
+}
+
+// notifyFailure emails the user who requested the video that it failed for good. The failure is already
+// recorded, so the email is best effort.
+func (p *GenerateVideoProcess) notifyFailure(ctx context.Context, payload VideoGenerationPayload, class string) {
+	sendFailureEmail(ctx, p.cognitoClient, p.sesClient, p.dynamoClient, payload.RequestedBy, payload.EntryID, class)
+}
+
+// sendFailureEmail tells a user that a video they requested from the job failed with the given class.
+func sendFailureEmail(ctx context.Context, cognitoClient cognitoclient.CognitoMethods, sesClient sesclient.SESMethods, dynamoClient dynamo.DynamoMethods, requestedBy string, entryID string, class string) {
+	email, err := cognitoClient.GetEmailFromUsername(ctx, requestedBy)
+	if err != nil {
+		log.Printf("Failed to get email from username: %v", err)
+		return
+	}
+	title := entryID
+	if job, err := dynamoClient.GetJob(ctx, entryID); err != nil {
+		log.Printf("Failed to get job for the failure email: %v", err)
+	} else if job != nil {
+		title = job.Title
+	}
+	err = sesClient.SendFailureEmail(ctx, email, title, entryID, failureCategory(class))
+	if err != nil {
+		log.Printf("Failed to send failure email: %v", err)
+	}
+}
+
+// failEncode handles ffmpeg exiting early. A cancelled task is returned as is so asynq runs it again,
+// anything else is recorded as a video failure.
+func (p *GenerateVideoProcess) failEncode(ctx context.Context, t *asynq.Task, payload VideoGenerationPayload, err error) error {
+	if ctx.Err() != nil {
+		log.Printf("Video generation for %s stopped: %v", payload.EntryID, ctx.Err())
+		return ctx.Err()
+	}
+	log.Printf("Error in running ffmpeg command: %v", err)
+	var exitErr *ffmpeg.ExitError
+	if errors.As(err, &exitErr) {
+		return p.failVideo(ctx, t, payload, exitErr.Class, exitErr.Transient(), err)
+	}
+	// ffmpeg could not be started at all
+	return p.failVideo(ctx, t, payload, ffmpeg.FailureUnknown, true, err)
+}
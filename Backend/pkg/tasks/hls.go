@@ -0,0 +1,50 @@
+package tasks
+
+import (
+	"context"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/Kanishk-K/UniteDownloader/Backend/pkg/videoutil"
+)
+
+// uploadHLS uploads the playlists and segments ffmpeg wrote into HLS_DIR under the video's HLS prefix.
+// The master playlist is uploaded last so players never find a stream with missing renditions.
+func (p *GenerateVideoProcess) uploadHLS(ctx context.Context, workingDir string, payload VideoGenerationPayload) error {
+	hlsDir := filepath.Join(workingDir, videoutil.HLS_DIR)
+	prefix := videoutil.HLSPrefix(payload.EntryID, payload.VideoID)
+	err := filepath.WalkDir(hlsDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || entry.Name() == videoutil.HLS_MASTER_PLAYLIST {
+			return nil
+		}
+		return p.uploadHLSFile(ctx, hlsDir, path, prefix)
+	})
+	if err != nil {
+		log.Printf("Failed to upload HLS stream to S3: %v", err)
+		return err
+	}
+	err = p.uploadHLSFile(ctx, hlsDir, filepath.Join(hlsDir, videoutil.HLS_MASTER_PLAYLIST), prefix)
+	if err != nil {
+		log.Printf("Failed to upload HLS master playlist to S3: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (p *GenerateVideoProcess) uploadHLSFile(ctx context.Context, hlsDir string, path string, prefix string) error {
+	relative, err := filepath.Rel(hlsDir, path)
+	if err != nil {
+		return err
+	}
+	fp, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer fp.Close()
+	return p.s3Client.UploadFile(ctx, BUCKET, prefix+filepath.ToSlash(relative), fp, videoutil.HLSContentType(path))
+}
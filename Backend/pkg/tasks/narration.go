@@ -0,0 +1,266 @@
+package tasks
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	dynamo "github.com/Kanishk-K/UniteDownloader/Backend/pkg/dynamoClient"
+	"github.com/Kanishk-K/UniteDownloader/Backend/pkg/jobutil"
+	s3client "github.com/Kanishk-K/UniteDownloader/Backend/pkg/s3Client"
+	subtitleclient "github.com/Kanishk-K/UniteDownloader/Backend/pkg/subtitleClient"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/hibiken/asynq"
+)
+
+const NarrationTask = "narration"
+
+type NarrationPayload struct {
+	EntryID string `json:"entryID"`
+}
+
+// NarrationProcess synthesizes a job's summary or dialogue into the audio, timestamps and subtitles its videos
+// are made from.
+type NarrationProcess struct {
+	s3Client     s3client.S3Methods
+	dynamoClient dynamo.DynamoMethods
+	ttsClient    subtitleclient.SubtitleGenerationMethods
+}
+
+func NewNarrationProcess(s3Client s3client.S3Methods, dynamoClient dynamo.DynamoMethods, ttsClient subtitleclient.SubtitleGenerationMethods) *NarrationProcess {
+	return &NarrationProcess{s3Client, dynamoClient, ttsClient}
+}
+
+func NewNarrationTask(entryID string) (*asynq.Task, error) {
+	payload, err := json.Marshal(NarrationPayload{EntryID: entryID})
+	if err != nil {
+		return nil, err
+	}
+	return asynq.NewTask(NarrationTask, payload), nil
+}
+
+// NarrationTaskID identifies the narration task of a job, so it is only queued once.
+func NarrationTaskID(entryID string) string {
+	return fmt.Sprintf("%s:%s", entryID, STAGE_NARRATION)
+}
+
+// NarrationOptions are the options every narration task is queued with. Every video of the job waits on it,
+// so it goes ahead of them.
+func NarrationOptions(entryID string) []asynq.Option {
+	return []asynq.Option{
+		asynq.Queue(QueueHigh),
+		asynq.MaxRetry(2),
+		asynq.TaskID(NarrationTaskID(entryID)),
+		asynq.Retention(time.Hour * 24),
+	}
+}
+
+func (p *NarrationProcess) HandleNarrationTask(ctx context.Context, t *asynq.Task) error {
+	var payload NarrationPayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		return fmt.Errorf("%v: %w", err, asynq.SkipRetry)
+	}
+	job, err := p.dynamoClient.GetJob(ctx, payload.EntryID)
+	if err != nil {
+		return err
+	}
+	if job == nil {
+		return fmt.Errorf("job %s does not exist: %w", payload.EntryID, asynq.SkipRetry)
+	}
+	summaryStyle := job.SummaryStyle
+	if summaryStyle == "" {
+		summaryStyle = jobutil.SummaryStyleNarrator
+	}
+	log.Printf("Generating %s narration for entryID: %s\n", summaryStyle, job.EntryID)
+	err = p.generateNarration(ctx, job, summaryStyle)
+	if err != nil {
+		return err
+	}
+	// subtitlesGenerated tells readers of the job that its narration is ready
+	return p.dynamoClient.GenerateSubtitles(ctx, job.EntryID, "")
+}
+
+// readObject reads an entire object from the job's asset folder.
+func (p *NarrationProcess) readObject(ctx context.Context, entryID string, name string) ([]byte, error) {
+	object, err := p.s3Client.ReadFile(ctx, BUCKET, assetKey(entryID, name))
+	if err != nil {
+		log.Printf("Failed to read %s from S3: %v", name, err)
+		return nil, err
+	}
+	defer object.Close()
+	objectBytes, err := io.ReadAll(object)
+	if err != nil {
+		log.Printf("Failed to read %s from S3: %v", name, err)
+		return nil, err
+	}
+	return objectBytes, nil
+}
+
+// uploadTTSResponse keeps a copy of the synthesized audio and timestamps for debugging.
+func (p *NarrationProcess) uploadTTSResponse(ctx context.Context, entryID string, ttsResponse *subtitleclient.LemonFoxResponse) error {
+	ttsResponseBytes, err := json.Marshal(ttsResponse)
+	if err != nil {
+		log.Printf("Failed to marshal TTS response: %v", err)
+		return err
+	}
+	err = p.s3Client.UploadFile(ctx, BUCKET, assetKey(entryID, "TTSResponse.json"), bytes.NewReader(ttsResponseBytes), "application/json")
+	if err != nil {
+		log.Printf("Failed to upload TTS response: %v", err)
+		return err
+	}
+	return nil
+}
+
+// synthesizeNarration reads Summary.txt with a single narrator voice.
+func (p *NarrationProcess) synthesizeNarration(ctx context.Context, entryID string) ([]byte, []subtitleclient.WordTimeStamp, error) {
+	summaryBytes, err := p.readObject(ctx, entryID, "Summary.txt")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Generate TTS
+	ttsResponse, err := p.ttsClient.GenerateTTS(string(summaryBytes), subtitleclient.NARRATOR_VOICE)
+	if err != nil {
+		log.Printf("Failed to generate TTS: %v", err)
+		return nil, nil, err
+	}
+	err = p.uploadTTSResponse(ctx, entryID, ttsResponse)
+	if err != nil {
+		return nil, nil, err
+	}
+	decodedAudio, err := subtitleclient.ConvertB64ToAudio(ttsResponse.Audio)
+	if err != nil {
+		log.Printf("Failed to decode audio: %v", err)
+		return nil, nil, err
+	}
+	if len(ttsResponse.WordTimeStamps) == 0 {
+		// The TTS provider only returned audio, estimate the timestamps from the audio itself
+		log.Printf("No word timestamps returned for entryID: %s, aligning audio\n", entryID)
+		ttsResponse.WordTimeStamps, err = subtitleclient.AlignAudio(ctx, decodedAudio, string(summaryBytes))
+		if err != nil {
+			log.Printf("Failed to align audio: %v", err)
+			return nil, nil, err
+		}
+	}
+	return decodedAudio, ttsResponse.WordTimeStamps, nil
+}
+
+// synthesizeDialogue reads Dialogue.json with a different voice for each speaker.
+func (p *NarrationProcess) synthesizeDialogue(ctx context.Context, entryID string) ([]byte, []subtitleclient.WordTimeStamp, error) {
+	dialogueBytes, err := p.readObject(ctx, entryID, "Dialogue.json")
+	if err != nil {
+		return nil, nil, err
+	}
+	var dialogue subtitleclient.Dialogue
+	err = json.Unmarshal(dialogueBytes, &dialogue)
+	if err != nil {
+		log.Printf("Failed to decode dialogue: %v", err)
+		return nil, nil, fmt.Errorf("%v: %w", err, asynq.SkipRetry)
+	}
+	audio, words, err := subtitleclient.SynthesizeDialogue(ctx, p.ttsClient, &dialogue)
+	if err != nil {
+		log.Printf("Failed to synthesize dialogue: %v", err)
+		return nil, nil, err
+	}
+	err = p.uploadTTSResponse(ctx, entryID, &subtitleclient.LemonFoxResponse{
+		Audio:          base64.StdEncoding.EncodeToString(audio),
+		WordTimeStamps: words,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return audio, words, nil
+}
+
+// postProcess runs the audio stage, reading the job's music bed from S3 when one was chosen.
+func (p *NarrationProcess) postProcess(ctx context.Context, musicID string, audio []byte, words []subtitleclient.WordTimeStamp) ([]byte, []subtitleclient.WordTimeStamp, error) {
+	var music []byte
+	var musicTrack *subtitleclient.MusicTrack
+	if musicID != "" {
+		track, ok := subtitleclient.MUSIC_CATALOG[musicID]
+		if !ok {
+			return nil, nil, fmt.Errorf("background music is not in the catalog %s: %w", musicID, asynq.SkipRetry)
+		}
+		musicReader, err := p.s3Client.ReadFile(ctx, BUCKET, track.S3Key)
+		if err != nil {
+			var noSuchKey *types.NoSuchKey
+			if errors.As(err, &noSuchKey) {
+				// Retrying won't help until the track is uploaded
+				log.Printf("Music track %s has not been uploaded to %s", musicID, track.S3Key)
+				return nil, nil, fmt.Errorf("music track %s is missing from %s: %w", musicID, track.S3Key, asynq.SkipRetry)
+			}
+			log.Printf("Failed to read music from S3: %v", err)
+			return nil, nil, err
+		}
+		defer musicReader.Close()
+		music, err = io.ReadAll(musicReader)
+		if err != nil {
+			log.Printf("Failed to read music from S3: %v", err)
+			return nil, nil, err
+		}
+		musicTrack = &track
+	}
+	processedAudio, processedWords, err := subtitleclient.ProcessAudio(ctx, audio, words, music, musicTrack)
+	if err != nil {
+		log.Printf("Failed to post-process audio: %v", err)
+		return nil, nil, err
+	}
+	return processedAudio, processedWords, nil
+}
+
+// generateNarration synthesizes the job's audio and uploads it with its timestamps and subtitles.
+func (p *NarrationProcess) generateNarration(ctx context.Context, job *dynamo.JobDocument, summaryStyle string) error {
+	var err error
+
+	var audio []byte
+	var words []subtitleclient.WordTimeStamp
+	if summaryStyle == jobutil.SummaryStylePodcast {
+		audio, words, err = p.synthesizeDialogue(ctx, job.EntryID)
+	} else {
+		audio, words, err = p.synthesizeNarration(ctx, job.EntryID)
+	}
+	if err != nil {
+		return err
+	}
+	if len(words) == 0 {
+		return fmt.Errorf("no word timestamps available for entryID: %s", job.EntryID)
+	}
+
+	// Normalize the audio and mix in the music bed before the subtitles are timed against it
+	audio, words, err = p.postProcess(ctx, job.BackgroundMusic, audio, words)
+	if err != nil {
+		return err
+	}
+
+	// Upload the audio to S3
+	err = p.s3Client.UploadFile(ctx, BUCKET, assetKey(job.EntryID, "Audio.aac"), bytes.NewReader(audio), "audio/aac")
+	if err != nil {
+		log.Printf("Failed to upload audio: %v", err)
+		return err
+	}
+	// Keep the final timestamps so the consumer can lay the subtitles out for other output presets
+	wordBytes, err := json.Marshal(words)
+	if err != nil {
+		log.Printf("Failed to marshal timestamps: %v", err)
+		return err
+	}
+	err = p.s3Client.UploadFile(ctx, BUCKET, assetKey(job.EntryID, "Timestamps.json"), bytes.NewReader(wordBytes), "application/json")
+	if err != nil {
+		log.Printf("Failed to upload timestamps: %v", err)
+		return err
+	}
+	lines := subtitleclient.GenerateSubtitleLines(words)
+	assContent := subtitleclient.GenerateASSContent(lines)
+	err = p.s3Client.UploadFile(ctx, BUCKET, assetKey(job.EntryID, "Subtitle.ass"), bytes.NewReader([]byte(assContent)), "application/x-ass")
+	if err != nil {
+		log.Printf("Failed to upload subtitles: %v", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package tasks
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/Kanishk-K/UniteDownloader/Backend/pkg/videoutil"
+)
+
+// readJobText reads the job metadata written on the intro card and used to name downloads.
+func (p *GenerateVideoProcess) readJobText(ctx context.Context, payload VideoGenerationPayload) (videoutil.OverlayText, error) {
+	job, err := p.dynamoClient.GetJob(ctx, payload.EntryID)
+	if err != nil {
+		log.Printf("Failed to read job: %v", err)
+		return videoutil.OverlayText{}, err
+	}
+	if job == nil {
+		return videoutil.OverlayText{}, fmt.Errorf("job %s does not exist", payload.EntryID)
+	}
+	return videoutil.OverlayText{Title: job.Title, Course: job.Course}, nil
+}
+
+// writeOverlays writes the overlays into name in workingDir, returning "" when there is nothing to draw.
+func writeOverlays(workingDir string, name string, preset videoutil.Preset, overlays videoutil.Overlays) (string, error) {
+	if (overlays.Outro || overlays.Progress) && overlays.Duration <= 0 {
+		// The narration could not be probed, so there is no telling where the video ends
+		log.Printf("Video length is unknown, skipping the outro and progress bar")
+		overlays.Outro, overlays.Progress = false, false
+	}
+	if overlays.Empty() {
+		return "", nil
+	}
+	err := os.WriteFile(filepath.Join(workingDir, name), []byte(videoutil.GenerateOverlayASS(preset, overlays)), 0644)
+	if err != nil {
+		log.Printf("Error writing overlay file: %v", err)
+		return "", err
+	}
+	return name, nil
+}
@@ -0,0 +1,111 @@
+package tasks
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/Kanishk-K/UniteDownloader/Backend/pkg/ffmpeg"
+	s3client "github.com/Kanishk-K/UniteDownloader/Backend/pkg/s3Client"
+	subtitleclient "github.com/Kanishk-K/UniteDownloader/Backend/pkg/subtitleClient"
+	"github.com/Kanishk-K/UniteDownloader/Backend/pkg/videoutil"
+	"github.com/hibiken/asynq"
+)
+
+// encodeParts splits the narration at sentence boundaries into short-form parts and renders and uploads each one
+// labelled "Part N of M". It returns the first part, which the previews are taken from, and how many parts there are.
+func (p *GenerateVideoProcess) encodeParts(ctx context.Context, t *asynq.Task, payload VideoGenerationPayload, inputs encodeInputs, progress *progressReporter) (string, int, error) {
+	// Step 1: Find where the narration can be cut
+	words, err := p.readTimestamps(ctx, payload.EntryID)
+	if err != nil {
+		log.Printf("Failed to read timestamps for %s: %v", payload.EntryID, err)
+		if ctx.Err() != nil {
+			return "", 0, ctx.Err()
+		}
+		// Jobs from before Timestamps.json existed can't be split
+		return "", 0, p.failVideo(ctx, t, payload, FailureInvalidRequest, false, fmt.Errorf("no word timings to split the narration: %w", err))
+	}
+	if len(words) == 0 {
+		return "", 0, p.failVideo(ctx, t, payload, FailureInvalidRequest, false, fmt.Errorf("narration has no words to split"))
+	}
+	parts := subtitleclient.SplitParts(words, float64(payload.PartLength))
+	log.Printf("Splitting %s into %d parts", payload.EntryID, len(parts))
+
+	// Step 2: Render and upload every part, the background carries on from one part to the next
+	canvas := subtitleclient.Canvas{Width: inputs.preset.Width, Height: inputs.preset.Height}
+	backgroundOffset := inputs.background.RandomOffset()
+	for i, part := range parts {
+		number := i + 1
+		subtitles := fmt.Sprintf("part-%02d.ass", number)
+		err = os.WriteFile(filepath.Join(inputs.workingDir, subtitles), []byte(subtitleclient.GeneratePartASSContent(part, number, len(parts), canvas)), 0644)
+		if err != nil {
+			log.Printf("Error writing subtitle file: %v", err)
+			return "", 0, err
+		}
+
+		// The intro opens the first part, the outro closes the last and every part has its own progress bar
+		duration := part.End - part.Start
+		if part.End == 0 {
+			duration = inputs.duration - part.Start
+		}
+		overlays, err := writeOverlays(inputs.workingDir, fmt.Sprintf("overlays-%02d.ass", number), inputs.preset, videoutil.Overlays{
+			Intro:    number == 1 && videoutil.HasOverlay(payload.VideoOptions, videoutil.OVERLAY_INTRO),
+			Outro:    number == len(parts) && videoutil.HasOverlay(payload.VideoOptions, videoutil.OVERLAY_OUTRO),
+			Progress: videoutil.HasOverlay(payload.VideoOptions, videoutil.OVERLAY_PROGRESS),
+			Text:     inputs.jobText,
+			Duration: duration,
+		})
+		if err != nil {
+			return "", 0, err
+		}
+
+		command := ffmpeg.New().WithProgress()
+		command.Input(inputs.backgroundVideo).Loop().Seek(inputs.background.LoopOffset(backgroundOffset, part.Start))
+		command.Input(inputs.audio)
+		command.Input(inputs.logo)
+		graph := videoutil.NarratedLayout(inputs.preset, "0", "2", subtitles, overlays, p.ffmpegVersion)
+		videoutil.AddPartAudio(graph, "1:a", part.Start, part.End)
+		command.FilterGraph(graph)
+		output := fmt.Sprintf("part-%02d.mp4", number)
+		command.Output(output).
+			Map(videoutil.LAYOUT_OUTPUT).
+			Map(videoutil.PART_AUDIO).
+			With(inputs.preset.PartEncoderArgs()...).
+			With("-shortest")
+
+		// Progress is reported against the whole narration
+		err = ffmpeg.RunWithProgress(command.Cmd(ctx, inputs.workingDir), func(partProgress ffmpeg.Progress) {
+			partProgress.OutTime += part.Start
+			progress.Report(partProgress)
+		})
+		if err != nil {
+			p.deleteVideoAssets(ctx, payload)
+			return "", 0, p.failEncode(ctx, t, payload, err)
+		}
+		err = p.uploadPart(ctx, inputs.workingDir, output, videoutil.PartKey(payload.EntryID, payload.VideoID, number), videoutil.DownloadName(inputs.jobText.Title, number))
+		if err != nil {
+			log.Printf("Failed to upload part %d to S3: %v", number, err)
+			p.deleteVideoAssets(ctx, payload)
+			return "", 0, err
+		}
+		if number > 1 {
+			// Only the first part is kept for the previews
+			os.Remove(filepath.Join(inputs.workingDir, output))
+		}
+	}
+	return "part-01.mp4", len(parts), nil
+}
+
+func (p *GenerateVideoProcess) uploadPart(ctx context.Context, workingDir string, file string, key string, name string) error {
+	fp, err := os.Open(filepath.Join(workingDir, file))
+	if err != nil {
+		return err
+	}
+	defer fp.Close()
+	return p.s3Client.Upload(ctx, BUCKET, key, fp, s3client.UploadOptions{
+		ContentType:        "video/mp4",
+		ContentDisposition: s3client.ContentDisposition("inline", name),
+	})
+}
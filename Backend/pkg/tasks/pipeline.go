@@ -0,0 +1,553 @@
+package tasks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	cognitoclient "github.com/Kanishk-K/UniteDownloader/Backend/pkg/cognitoClient"
+	dynamo "github.com/Kanishk-K/UniteDownloader/Backend/pkg/dynamoClient"
+	"github.com/Kanishk-K/UniteDownloader/Backend/pkg/ffmpeg"
+	"github.com/Kanishk-K/UniteDownloader/Backend/pkg/jobutil"
+	s3client "github.com/Kanishk-K/UniteDownloader/Backend/pkg/s3Client"
+	sesclient "github.com/Kanishk-K/UniteDownloader/Backend/pkg/sesClient"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/hibiken/asynq"
+)
+
+const (
+	PipelineAdvanceTask = "pipelineAdvance"
+	PipelineRetryTask   = "pipelineRetry"
+)
+
+// A job has a single narration stage and a stage for every video requested from it, named by VideoStageName
+const (
+	STAGE_NARRATION    = "narration"
+	VIDEO_STAGE_PREFIX = "video:"
+)
+
+type PipelinePayload struct {
+	EntryID string `json:"entryID"`
+	// Stage is the stage to run again, it is only set on retries
+	Stage string `json:"stage,omitempty"`
+}
+
+// PipelineProcess runs each job as a DAG of stages stored on its Jobs item. Every stage is an asynq task, when
+// one finishes the pipeline is advanced and each stage whose dependencies are done and inputs exist is queued.
+type PipelineProcess struct {
+	s3Client      s3client.S3Methods
+	dynamoClient  dynamo.DynamoMethods
+	sesClient     sesclient.SESMethods
+	cognitoClient cognitoclient.CognitoMethods
+	queue         Enqueuer
+	inspector     *asynq.Inspector
+}
+
+func NewPipelineProcess(s3Client s3client.S3Methods, dynamoClient dynamo.DynamoMethods, sesClient sesclient.SESMethods, cognitoClient cognitoclient.CognitoMethods, queue Enqueuer, inspector *asynq.Inspector) *PipelineProcess {
+	return &PipelineProcess{s3Client, dynamoClient, sesClient, cognitoClient, queue, inspector}
+}
+
+func NewPipelineAdvanceTask(entryID string) (*asynq.Task, error) {
+	payload, err := json.Marshal(PipelinePayload{EntryID: entryID})
+	if err != nil {
+		return nil, err
+	}
+	return asynq.NewTask(PipelineAdvanceTask, payload), nil
+}
+
+// NewPipelineRetryTask runs the stage again along with the stages its failure blocked.
+func NewPipelineRetryTask(entryID string, stage string) (*asynq.Task, error) {
+	payload, err := json.Marshal(PipelinePayload{EntryID: entryID, Stage: stage})
+	if err != nil {
+		return nil, err
+	}
+	return asynq.NewTask(PipelineRetryTask, payload), nil
+}
+
+// AdvancePipeline queues a task that starts whichever of the job's stages are ready. Advancing is idempotent,
+// so it is safe to call whenever a stage may have become ready.
+func AdvancePipeline(ctx context.Context, queue Enqueuer, entryID string) error {
+	task, err := NewPipelineAdvanceTask(entryID)
+	if err != nil {
+		return err
+	}
+	_, err = queue.EnqueueContext(ctx, task, asynq.Queue(QueueHigh), asynq.MaxRetry(5))
+	if err != nil {
+		log.Printf("Could not advance the pipeline for %s: %v", entryID, err)
+		return err
+	}
+	return nil
+}
+
+func assetKey(entryID string, name string) string {
+	return fmt.Sprintf("assets/%s/%s", entryID, name)
+}
+
+// VideoStageName is the pipeline stage that renders a video request.
+func VideoStageName(videoID string) string {
+	return VIDEO_STAGE_PREFIX + videoID
+}
+
+// NarrationStage reads the job's script aloud, every video of the job is made from its outputs.
+func NarrationStage(entryID string, summaryStyle string) dynamo.PipelineStage {
+	script := "Summary.txt"
+	if summaryStyle == jobutil.SummaryStylePodcast {
+		script = "Dialogue.json"
+	}
+	return dynamo.PipelineStage{
+		Status:    dynamo.StageStatusPending,
+		Inputs:    []string{assetKey(entryID, script)},
+		Outputs:   []string{assetKey(entryID, "Audio.aac"), assetKey(entryID, "Timestamps.json"), assetKey(entryID, "Subtitle.ass")},
+		UpdatedOn: time.Now().Format(dynamo.DATE_FORMAT),
+	}
+}
+
+// VideoStage renders a video request once the narration is done.
+func VideoStage(videoRequest dynamo.VideoRequestDocument) dynamo.PipelineStage {
+	return dynamo.PipelineStage{
+		Status:    dynamo.StageStatusPending,
+		DependsOn: []string{STAGE_NARRATION},
+		Inputs:    []string{assetKey(videoRequest.EntryID, "Audio.aac"), assetKey(videoRequest.EntryID, "Subtitle.ass")},
+		Outputs:   []string{outputKey(videoRequest)},
+		UpdatedOn: time.Now().Format(dynamo.DATE_FORMAT),
+	}
+}
+
+// stageTaskID is the ID of the asynq task that runs the stage.
+func stageTaskID(entryID string, name string) string {
+	if videoID, ok := strings.CutPrefix(name, VIDEO_STAGE_PREFIX); ok {
+		return VideoGenerationTaskID(entryID, videoID)
+	}
+	return NarrationTaskID(entryID)
+}
+
+// StartPipeline adds the stages a new video request needs to its job's pipeline and advances it. A narration that
+// failed is run again, as is the video's own stage if it failed or was made before and has since expired.
+func StartPipeline(ctx context.Context, dynamoClient dynamo.DynamoMethods, queue Enqueuer, job *dynamo.JobDocument, videoRequest dynamo.VideoRequestDocument) error {
+	// Step 1: Add the stages, ones already on the job are kept
+	videoStage := VideoStageName(videoRequest.RequestedVideo)
+	stages := []struct {
+		name  string
+		stage dynamo.PipelineStage
+	}{
+		{STAGE_NARRATION, NarrationStage(job.EntryID, job.SummaryStyle)},
+		{videoStage, VideoStage(videoRequest)},
+	}
+	for _, s := range stages {
+		err := dynamoClient.AddPipelineStage(ctx, job.EntryID, s.name, s.stage)
+		if err != nil {
+			return err
+		}
+	}
+	// Step 2: Run the stages that already finished again when the new request needs them to
+	if narration, ok := job.Pipeline[STAGE_NARRATION]; ok && narration.Status == dynamo.StageStatusFailed {
+		err := resetStage(ctx, dynamoClient, job, STAGE_NARRATION)
+		if err != nil {
+			return err
+		}
+	}
+	if video, ok := job.Pipeline[videoStage]; ok && (video.Status == dynamo.StageStatusFailed || video.Status == dynamo.StageStatusDone) {
+		err := resetStage(ctx, dynamoClient, job, videoStage)
+		if err != nil {
+			return err
+		}
+	}
+	// Step 3: Start whichever stages are ready
+	return AdvancePipeline(ctx, queue, job.EntryID)
+}
+
+// resetStage sets the stage back to pending along with every stage its failure blocked, stages that depend on it
+// and finished are left alone. A stage that is queued or running can't be reset.
+func resetStage(ctx context.Context, dynamoClient dynamo.DynamoMethods, job *dynamo.JobDocument, name string) error {
+	finished := []string{dynamo.StageStatusPending, dynamo.StageStatusDone, dynamo.StageStatusFailed}
+	err := dynamoClient.UpdatePipelineStage(ctx, job.EntryID, name, dynamo.StageStatusPending, "", finished)
+	if err != nil {
+		return err
+	}
+	for _, dependent := range dependents(job.Pipeline, name) {
+		err = dynamoClient.UpdatePipelineStage(ctx, job.EntryID, dependent, dynamo.StageStatusPending, "", []string{dynamo.StageStatusFailed})
+		var ccfe *types.ConditionalCheckFailedException
+		if err != nil && !errors.As(err, &ccfe) {
+			return err
+		}
+	}
+	log.Printf("Reset stage %s of %s", name, job.EntryID)
+	return nil
+}
+
+// dependents lists every stage that depends on the named one, directly or through other stages.
+func dependents(pipeline map[string]dynamo.PipelineStage, name string) []string {
+	found := make(map[string]bool)
+	next := []string{name}
+	for len(next) > 0 {
+		current := next[0]
+		next = next[1:]
+		for candidate, stage := range pipeline {
+			if !found[candidate] && candidate != name && slices.Contains(stage.DependsOn, current) {
+				found[candidate] = true
+				next = append(next, candidate)
+			}
+		}
+	}
+	return slices.Sorted(maps.Keys(found))
+}
+
+func (p *PipelineProcess) HandlePipelineAdvanceTask(ctx context.Context, t *asynq.Task) error {
+	var payload PipelinePayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		return fmt.Errorf("%v: %w", err, asynq.SkipRetry)
+	}
+	return p.advance(ctx, payload.EntryID)
+}
+
+func (p *PipelineProcess) HandlePipelineRetryTask(ctx context.Context, t *asynq.Task) error {
+	var payload PipelinePayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		return fmt.Errorf("%v: %w", err, asynq.SkipRetry)
+	}
+	job, err := p.dynamoClient.GetJob(ctx, payload.EntryID)
+	if err != nil {
+		return err
+	}
+	if job == nil || job.Pipeline[payload.Stage].Status == "" {
+		return fmt.Errorf("job %s has no stage %q: %w", payload.EntryID, payload.Stage, asynq.SkipRetry)
+	}
+	err = resetStage(ctx, p.dynamoClient, job, payload.Stage)
+	if err != nil {
+		var ccfe *types.ConditionalCheckFailedException
+		if errors.As(err, &ccfe) {
+			return fmt.Errorf("stage %s of %s is still running: %w", payload.Stage, payload.EntryID, asynq.SkipRetry)
+		}
+		return err
+	}
+	return p.advance(ctx, payload.EntryID)
+}
+
+// advance starts every pending stage whose dependencies are done, and fails the ones a failed dependency blocks.
+func (p *PipelineProcess) advance(ctx context.Context, entryID string) error {
+	job, err := p.dynamoClient.GetJob(ctx, entryID)
+	if err != nil {
+		return err
+	}
+	if job == nil {
+		log.Printf("Job %s no longer exists, nothing to advance", entryID)
+		return nil
+	}
+	// Stages skipped or failed here can make others ready, so keep going until nothing changes
+	for progressed := true; progressed; {
+		progressed = false
+		for _, name := range slices.Sorted(maps.Keys(job.Pipeline)) {
+			stage := job.Pipeline[name]
+			if stage.Status != dynamo.StageStatusPending {
+				continue
+			}
+			ready, blockedBy := dependenciesDone(job.Pipeline, stage)
+			if blockedBy != "" {
+				stage.Status = dynamo.StageStatusFailed
+				p.blockStage(ctx, job, name, blockedBy)
+			} else if ready {
+				stage.Status, err = p.startStage(ctx, job, name, stage)
+				if err != nil {
+					return err
+				}
+				if stage.Status == "" {
+					// Another advance took the stage, the stages after it wait on that one
+					stage.Status = dynamo.StageStatusQueued
+				}
+			}
+			if stage.Status != dynamo.StageStatusPending {
+				job.Pipeline[name] = stage
+				progressed = true
+			}
+		}
+	}
+	return nil
+}
+
+// dependenciesDone reports whether every stage the stage depends on is done, or the first one that failed.
+func dependenciesDone(pipeline map[string]dynamo.PipelineStage, stage dynamo.PipelineStage) (bool, string) {
+	ready := true
+	for _, dependency := range stage.DependsOn {
+		switch pipeline[dependency].Status {
+		case dynamo.StageStatusDone:
+		case dynamo.StageStatusFailed, "":
+			return false, dependency
+		default:
+			ready = false
+		}
+	}
+	return ready, ""
+}
+
+// startStage queues the stage's task and returns the status the stage moved to.
+func (p *PipelineProcess) startStage(ctx context.Context, job *dynamo.JobDocument, name string, stage dynamo.PipelineStage) (string, error) {
+	pending := []string{dynamo.StageStatusPending}
+	// Step 1: A stage that has never run is skipped if its outputs are already there, e.g. jobs narrated before the pipeline
+	if stage.Attempts == 0 && len(stage.Outputs) > 0 {
+		missing, err := p.firstMissing(ctx, stage.Outputs)
+		if err != nil {
+			return stage.Status, err
+		}
+		if missing == "" {
+			log.Printf("Stage %s of %s already has its outputs, skipping it", name, job.EntryID)
+			return p.moveStage(ctx, job.EntryID, name, dynamo.StageStatusDone, "", pending)
+		}
+	}
+	// Step 2: Ensure the inputs exist
+	missing, err := p.firstMissing(ctx, stage.Inputs)
+	if err != nil {
+		return stage.Status, err
+	}
+	if missing != "" {
+		log.Printf("Stage %s of %s is missing its input %s", name, job.EntryID, missing)
+		status, err := p.moveStage(ctx, job.EntryID, name, dynamo.StageStatusFailed, "missing input "+missing, pending)
+		if err == nil && status == dynamo.StageStatusFailed {
+			p.failVideoStage(ctx, job.EntryID, name, ffmpeg.FailureMissingInput, "missing input "+missing)
+		}
+		return status, err
+	}
+	// Step 3: Build the stage's task
+	task, options, err := p.stageTask(ctx, job, name)
+	if err != nil {
+		return stage.Status, err
+	}
+	if task == nil {
+		log.Printf("Stage %s of %s has nothing left to run", name, job.EntryID)
+		return p.moveStage(ctx, job.EntryID, name, dynamo.StageStatusFailed, "the video request was removed", pending)
+	}
+	// Step 4: Claim the stage so a concurrent advance doesn't queue it too
+	status, err := p.moveStage(ctx, job.EntryID, name, dynamo.StageStatusQueued, "", pending)
+	if err != nil || status != dynamo.StageStatusQueued {
+		return status, err
+	}
+	// Step 5: Queue it, a task left behind by an earlier run of the stage is cleared first
+	taskID := stageTaskID(job.EntryID, name)
+	_, err = p.queue.EnqueueContext(ctx, task, options...)
+	if errors.Is(err, asynq.ErrTaskIDConflict) {
+		err = ClearFinishedTask(p.inspector, taskID)
+		if err == nil {
+			_, err = p.queue.EnqueueContext(ctx, task, options...)
+		}
+		if errors.Is(err, asynq.ErrTaskIDConflict) {
+			// The task is still waiting or running, it picks the stage up when it runs
+			log.Printf("Task %s is already queued for stage %s", taskID, name)
+			err = nil
+		}
+	}
+	if err != nil {
+		log.Printf("Could not queue stage %s of %s: %v", name, job.EntryID, err)
+		if _, resetErr := p.moveStage(ctx, job.EntryID, name, dynamo.StageStatusPending, "", []string{dynamo.StageStatusQueued}); resetErr != nil {
+			log.Printf("Could not release stage %s of %s: %v", name, job.EntryID, resetErr)
+		}
+		return dynamo.StageStatusPending, err
+	}
+	log.Printf("Queued stage %s of %s", name, job.EntryID)
+	return dynamo.StageStatusQueued, nil
+}
+
+// stageTask builds the task that runs the stage, or nil if the stage no longer has anything to run.
+func (p *PipelineProcess) stageTask(ctx context.Context, job *dynamo.JobDocument, name string) (*asynq.Task, []asynq.Option, error) {
+	videoID, isVideo := strings.CutPrefix(name, VIDEO_STAGE_PREFIX)
+	if !isVideo {
+		task, err := NewNarrationTask(job.EntryID)
+		return task, NarrationOptions(job.EntryID), err
+	}
+	videoRequest, err := p.dynamoClient.GetVideoRequest(ctx, job.EntryID, videoID)
+	if err != nil || videoRequest == nil {
+		return nil, nil, err
+	}
+	if videoRequest.BackgroundVideo == "" {
+		// Requests made before output presets only stored the background video
+		videoRequest.BackgroundVideo = videoRequest.RequestedVideo
+	}
+	task, err := NewVideoGenerationTask(videoRequest.EntryID, videoRequest.RequestedBy, videoRequest.RequestedVideo, videoRequest.VideoOptions)
+	if err != nil {
+		return nil, nil, err
+	}
+	queue := videoRequest.Priority
+	if queue == "" {
+		queue = QueueLow
+	}
+	return task, VideoGenerationOptions(videoRequest.EntryID, videoRequest.RequestedVideo, queue), nil
+}
+
+// moveStage moves a stage out of one of the from statuses and returns its new status. If another worker moved
+// it first, the stage is left alone and its status reported as unknown.
+func (p *PipelineProcess) moveStage(ctx context.Context, entryID string, name string, status string, detail string, from []string) (string, error) {
+	err := p.dynamoClient.UpdatePipelineStage(ctx, entryID, name, status, detail, from)
+	var ccfe *types.ConditionalCheckFailedException
+	if errors.As(err, &ccfe) {
+		log.Printf("Stage %s of %s was moved by someone else", name, entryID)
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return status, nil
+}
+
+// blockStage fails a stage whose dependency failed, telling the user when it is a video they requested.
+func (p *PipelineProcess) blockStage(ctx context.Context, job *dynamo.JobDocument, name string, dependency string) {
+	detail := fmt.Sprintf("stage %s failed", dependency)
+	if reason := job.Pipeline[dependency].Error; reason != "" {
+		detail += ": " + reason
+	}
+	log.Printf("Stage %s of %s is blocked, %s", name, job.EntryID, detail)
+	status, err := p.moveStage(ctx, job.EntryID, name, dynamo.StageStatusFailed, detail, []string{dynamo.StageStatusPending})
+	if err != nil {
+		log.Printf("Could not fail stage %s of %s: %v", name, job.EntryID, err)
+		return
+	}
+	if status == dynamo.StageStatusFailed {
+		p.failVideoStage(ctx, job.EntryID, name, FailureNarration, detail)
+	}
+}
+
+// failVideoStage records why a video stage failed before its task ran, so the user can see it and request the
+// video again, and emails them about it.
+func (p *PipelineProcess) failVideoStage(ctx context.Context, entryID string, name string, class string, detail string) {
+	videoID, isVideo := strings.CutPrefix(name, VIDEO_STAGE_PREFIX)
+	if !isVideo {
+		return
+	}
+	videoRequest, err := p.dynamoClient.GetVideoRequest(ctx, entryID, videoID)
+	if err != nil || videoRequest == nil {
+		return
+	}
+	failure := dynamo.VideoFailure{
+		Class:     class,
+		Detail:    detail,
+		Retryable: false,
+		FailedOn:  time.Now().Format(dynamo.DATE_FORMAT),
+	}
+	if err := p.dynamoClient.RecordVideoFailure(ctx, entryID, videoID, failure); err != nil {
+		log.Printf("Failed to record video failure: %v", err)
+	}
+	sendFailureEmail(ctx, p.cognitoClient, p.sesClient, p.dynamoClient, videoRequest.RequestedBy, entryID, class)
+}
+
+// firstMissing returns the first of the keys with nothing stored under it, or "" if all of them exist.
+func (p *PipelineProcess) firstMissing(ctx context.Context, keys []string) (string, error) {
+	for _, key := range keys {
+		found, err := p.s3Client.ListFiles(ctx, BUCKET, key)
+		if err != nil {
+			log.Printf("Failed to look for %s: %v", key, err)
+			return "", err
+		}
+		if len(found) == 0 {
+			return key, nil
+		}
+	}
+	return "", nil
+}
+
+// StageHandler wraps the handler of a stage's task. The stage is marked running while the task runs, and done or
+// failed once asynq will not run it again, after which the pipeline is advanced. Tasks queued before the pipeline
+// existed have no stage and run untracked.
+func (p *PipelineProcess) StageHandler(stageOf func(t *asynq.Task) (string, string, error), handler asynq.HandlerFunc) asynq.HandlerFunc {
+	return func(ctx context.Context, t *asynq.Task) error {
+		entryID, name, err := stageOf(t)
+		if err != nil {
+			return handler(ctx, t)
+		}
+		running := []string{dynamo.StageStatusQueued, dynamo.StageStatusRunning}
+		status, err := p.moveStage(ctx, entryID, name, dynamo.StageStatusRunning, "", running)
+		if err != nil {
+			return err
+		}
+		if status != dynamo.StageStatusRunning {
+			log.Printf("Task %s is not tracked by the pipeline of %s", t.Type(), entryID)
+			return handler(ctx, t)
+		}
+
+		err = handler(ctx, t)
+		if err != nil && (ctx.Err() != nil || (!errors.Is(err, asynq.SkipRetry) && canRetry(ctx))) {
+			// asynq runs the task again, the stage stays running until then
+			return err
+		}
+		// The stage is finished either way, record it even if the task was cancelled in the meantime
+		finishCtx := context.WithoutCancel(ctx)
+		if err != nil {
+			p.finishStage(finishCtx, entryID, name, dynamo.StageStatusFailed, err.Error())
+			return err
+		}
+		p.finishStage(finishCtx, entryID, name, dynamo.StageStatusDone, "")
+		return nil
+	}
+}
+
+// finishStage records how a running stage ended and advances the pipeline. A stage that says it succeeded but
+// did not write its outputs is failed.
+func (p *PipelineProcess) finishStage(ctx context.Context, entryID string, name string, status string, detail string) {
+	if status == dynamo.StageStatusDone {
+		job, err := p.dynamoClient.GetJob(ctx, entryID)
+		if err != nil {
+			log.Printf("Could not read the pipeline of %s: %v", entryID, err)
+			return
+		}
+		if job != nil {
+			missing, err := p.firstMissing(ctx, job.Pipeline[name].Outputs)
+			if err != nil {
+				return
+			}
+			if missing != "" {
+				status, detail = dynamo.StageStatusFailed, "did not write "+missing
+			}
+		}
+	}
+	log.Printf("Stage %s of %s is %s", name, entryID, status)
+	_, err := p.moveStage(ctx, entryID, name, status, detail, []string{dynamo.StageStatusRunning})
+	if err != nil {
+		log.Printf("Could not finish stage %s of %s: %v", name, entryID, err)
+		return
+	}
+	_ = AdvancePipeline(ctx, p.queue, entryID)
+}
+
+// NarrationStageOf names the stage a narration task runs.
+func NarrationStageOf(t *asynq.Task) (string, string, error) {
+	var payload NarrationPayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		return "", "", err
+	}
+	return payload.EntryID, STAGE_NARRATION, nil
+}
+
+// VideoStageOf names the stage a video generation task runs.
+func VideoStageOf(t *asynq.Task) (string, string, error) {
+	var payload VideoGenerationPayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		return "", "", err
+	}
+	if payload.VideoID == "" {
+		// Tasks queued before output presets are keyed by their background video
+		payload.VideoID = payload.BackgroundVideo
+	}
+	return payload.EntryID, VideoStageName(payload.VideoID), nil
+}
+
+// ClearFinishedTask deletes the archived or completed task left by an earlier run, so the task can be queued
+// again. A task that is still waiting or running is left alone and reported as a conflict.
+func ClearFinishedTask(inspector *asynq.Inspector, taskID string) error {
+	for _, queue := range QueuesByPriority {
+		info, err := inspector.GetTaskInfo(queue, taskID)
+		if errors.Is(err, asynq.ErrTaskNotFound) || errors.Is(err, asynq.ErrQueueNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if info.State != asynq.TaskStateArchived && info.State != asynq.TaskStateCompleted {
+			return fmt.Errorf("task %s is still %s: %w", taskID, info.State, asynq.ErrTaskIDConflict)
+		}
+		log.Printf("Deleting %s task %s left by an earlier run\n", info.State, taskID)
+		return inspector.DeleteTask(queue, taskID)
+	}
+	return nil
+}
@@ -0,0 +1,536 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+
+	cognitoclient "github.com/Kanishk-K/UniteDownloader/Backend/pkg/cognitoClient"
+	dynamo "github.com/Kanishk-K/UniteDownloader/Backend/pkg/dynamoClient"
+	"github.com/Kanishk-K/UniteDownloader/Backend/pkg/ffmpeg"
+	s3client "github.com/Kanishk-K/UniteDownloader/Backend/pkg/s3Client"
+	sesclient "github.com/Kanishk-K/UniteDownloader/Backend/pkg/sesClient"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/hibiken/asynq"
+)
+
+const testEntryID = "1_abc"
+
+// fakeDynamo keeps a single job and its video requests in memory, methods the pipeline doesn't use panic.
+type fakeDynamo struct {
+	dynamo.DynamoMethods
+	job           *dynamo.JobDocument
+	videoRequests map[string]*dynamo.VideoRequestDocument
+	failures      map[string]dynamo.VideoFailure
+}
+
+func (d *fakeDynamo) GetJob(ctx context.Context, entryID string) (*dynamo.JobDocument, error) {
+	if d.job == nil || d.job.EntryID != entryID {
+		return nil, nil
+	}
+	job := *d.job
+	job.Pipeline = maps.Clone(d.job.Pipeline)
+	return &job, nil
+}
+
+func (d *fakeDynamo) AddPipelineStage(ctx context.Context, entryID string, name string, stage dynamo.PipelineStage) error {
+	if _, ok := d.job.Pipeline[name]; !ok {
+		d.job.Pipeline[name] = stage
+	}
+	return nil
+}
+
+func (d *fakeDynamo) UpdatePipelineStage(ctx context.Context, entryID string, name string, status string, detail string, from []string) error {
+	stage, ok := d.job.Pipeline[name]
+	if !ok || (from != nil && !slices.Contains(from, stage.Status)) {
+		return &types.ConditionalCheckFailedException{}
+	}
+	if status == dynamo.StageStatusRunning {
+		stage.Attempts++
+	}
+	stage.Status, stage.Error = status, detail
+	d.job.Pipeline[name] = stage
+	return nil
+}
+
+func (d *fakeDynamo) GetVideoRequest(ctx context.Context, entryID string, videoID string) (*dynamo.VideoRequestDocument, error) {
+	return d.videoRequests[videoID], nil
+}
+
+func (d *fakeDynamo) RecordVideoFailure(ctx context.Context, entryID string, videoID string, failure dynamo.VideoFailure) error {
+	d.failures[videoID] = failure
+	return nil
+}
+
+// fakeS3 lists the keys in files.
+type fakeS3 struct {
+	s3client.S3Methods
+	files []string
+}
+
+func (s *fakeS3) ListFiles(ctx context.Context, bucket string, prefix string) ([]string, error) {
+	var found []string
+	for _, key := range s.files {
+		if strings.HasPrefix(key, prefix) {
+			found = append(found, key)
+		}
+	}
+	return found, nil
+}
+
+type fakeCognito struct{ cognitoclient.CognitoMethods }
+
+func (fakeCognito) GetEmailFromUsername(ctx context.Context, username string) (string, error) {
+	return username + "@example.com", nil
+}
+
+type fakeSES struct {
+	sesclient.SESMethods
+	sent []string
+}
+
+func (s *fakeSES) SendFailureEmail(ctx context.Context, to string, subject string, entryID string, category string) error {
+	s.sent = append(s.sent, to)
+	return nil
+}
+
+// stubQueue records the type and queue of every task queued on it, failing them all when err is set.
+type stubQueue struct {
+	queued []string
+	err    error
+}
+
+func (q *stubQueue) EnqueueContext(ctx context.Context, task *asynq.Task, opts ...asynq.Option) (*asynq.TaskInfo, error) {
+	if q.err != nil {
+		return nil, q.err
+	}
+	queue := "default"
+	for _, opt := range opts {
+		if opt.Type() == asynq.QueueOpt {
+			queue = opt.Value().(string)
+		}
+	}
+	q.queued = append(q.queued, task.Type()+"@"+queue)
+	return &asynq.TaskInfo{Type: task.Type(), Queue: queue}, nil
+}
+
+type pipelineFixture struct {
+	dynamo *fakeDynamo
+	s3     *fakeS3
+	ses    *fakeSES
+	queue  *stubQueue
+	p      *PipelineProcess
+}
+
+// newPipelineFixture builds a job with a narration stage and one video stage, "vid", in the given statuses.
+func newPipelineFixture(narration string, video string, files ...string) *pipelineFixture {
+	f := &pipelineFixture{
+		dynamo: &fakeDynamo{
+			job: &dynamo.JobDocument{
+				EntryID: testEntryID,
+				Title:   "Lecture",
+				Pipeline: map[string]dynamo.PipelineStage{
+					STAGE_NARRATION: {
+						Status:  narration,
+						Inputs:  []string{"assets/1_abc/Summary.txt"},
+						Outputs: []string{"assets/1_abc/Audio.aac"},
+					},
+					VideoStageName("vid"): {
+						Status:    video,
+						DependsOn: []string{STAGE_NARRATION},
+						Inputs:    []string{"assets/1_abc/Audio.aac"},
+						Outputs:   []string{"videos/1_abc/vid.mp4"},
+					},
+				},
+			},
+			videoRequests: map[string]*dynamo.VideoRequestDocument{
+				"vid": {EntryID: testEntryID, RequestedVideo: "vid", RequestedBy: "student", Priority: QueueMedium},
+			},
+			failures: make(map[string]dynamo.VideoFailure),
+		},
+		s3:    &fakeS3{files: files},
+		ses:   &fakeSES{},
+		queue: &stubQueue{},
+	}
+	f.p = NewPipelineProcess(f.s3, f.dynamo, f.ses, fakeCognito{}, f.queue, nil)
+	return f
+}
+
+func (f *pipelineFixture) status(name string) string {
+	return f.dynamo.job.Pipeline[name].Status
+}
+
+func TestDependents(t *testing.T) {
+	pipeline := map[string]dynamo.PipelineStage{
+		"a": {},
+		"b": {DependsOn: []string{"a"}},
+		"c": {DependsOn: []string{"b"}},
+		"d": {DependsOn: []string{"a", "c"}},
+		"e": {},
+		"f": {DependsOn: []string{"e"}},
+	}
+	cases := map[string][]string{
+		"a":       {"b", "c", "d"},
+		"b":       {"c", "d"},
+		"c":       {"d"},
+		"d":       {},
+		"e":       {"f"},
+		"missing": {},
+	}
+	for name, want := range cases {
+		if got := dependents(pipeline, name); !slices.Equal(got, want) {
+			t.Errorf("dependents(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestDependenciesDone(t *testing.T) {
+	pipeline := map[string]dynamo.PipelineStage{
+		"done":    {Status: dynamo.StageStatusDone},
+		"done2":   {Status: dynamo.StageStatusDone},
+		"running": {Status: dynamo.StageStatusRunning},
+		"pending": {Status: dynamo.StageStatusPending},
+		"failed":  {Status: dynamo.StageStatusFailed},
+	}
+	cases := []struct {
+		dependsOn []string
+		ready     bool
+		blockedBy string
+	}{
+		{nil, true, ""},
+		{[]string{"done"}, true, ""},
+		{[]string{"done", "done2"}, true, ""},
+		{[]string{"done", "running"}, false, ""},
+		{[]string{"pending"}, false, ""},
+		{[]string{"failed"}, false, "failed"},
+		{[]string{"running", "failed"}, false, "failed"},
+		{[]string{"done", "missing"}, false, "missing"},
+	}
+	for _, c := range cases {
+		ready, blockedBy := dependenciesDone(pipeline, dynamo.PipelineStage{DependsOn: c.dependsOn})
+		if ready != c.ready || blockedBy != c.blockedBy {
+			t.Errorf("dependenciesDone(%v) = %v, %q, want %v, %q", c.dependsOn, ready, blockedBy, c.ready, c.blockedBy)
+		}
+	}
+}
+
+func TestAdvance(t *testing.T) {
+	video := VideoStageName("vid")
+	cases := []struct {
+		name      string
+		narration string
+		video     string
+		files     []string
+		// want are the statuses of the narration and video stages afterwards
+		want    [2]string
+		queued  []string
+		failure string
+	}{
+		{
+			name:      "narration is queued and the video waits",
+			narration: dynamo.StageStatusPending,
+			video:     dynamo.StageStatusPending,
+			files:     []string{"assets/1_abc/Summary.txt"},
+			want:      [2]string{dynamo.StageStatusQueued, dynamo.StageStatusPending},
+			queued:    []string{NarrationTask + "@" + QueueHigh},
+		},
+		{
+			name:      "video is queued on its priority once narration is done",
+			narration: dynamo.StageStatusDone,
+			video:     dynamo.StageStatusPending,
+			files:     []string{"assets/1_abc/Audio.aac"},
+			want:      [2]string{dynamo.StageStatusDone, dynamo.StageStatusQueued},
+			queued:    []string{VideoGenerationTask + "@" + QueueMedium},
+		},
+		{
+			name:      "narration with its outputs is skipped",
+			narration: dynamo.StageStatusPending,
+			video:     dynamo.StageStatusPending,
+			files:     []string{"assets/1_abc/Audio.aac"},
+			want:      [2]string{dynamo.StageStatusDone, dynamo.StageStatusQueued},
+			queued:    []string{VideoGenerationTask + "@" + QueueMedium},
+		},
+		{
+			name:      "failed narration blocks the video",
+			narration: dynamo.StageStatusFailed,
+			video:     dynamo.StageStatusPending,
+			want:      [2]string{dynamo.StageStatusFailed, dynamo.StageStatusFailed},
+			failure:   FailureNarration,
+		},
+		{
+			name:      "missing input fails narration and blocks the video",
+			narration: dynamo.StageStatusPending,
+			video:     dynamo.StageStatusPending,
+			want:      [2]string{dynamo.StageStatusFailed, dynamo.StageStatusFailed},
+			failure:   FailureNarration,
+		},
+		{
+			name:      "missing input fails the video",
+			narration: dynamo.StageStatusDone,
+			video:     dynamo.StageStatusPending,
+			want:      [2]string{dynamo.StageStatusDone, dynamo.StageStatusFailed},
+			failure:   ffmpeg.FailureMissingInput,
+		},
+		{
+			name:      "running stages are left alone",
+			narration: dynamo.StageStatusRunning,
+			video:     dynamo.StageStatusPending,
+			files:     []string{"assets/1_abc/Summary.txt", "assets/1_abc/Audio.aac"},
+			want:      [2]string{dynamo.StageStatusRunning, dynamo.StageStatusPending},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			f := newPipelineFixture(c.narration, c.video, c.files...)
+			if err := f.p.advance(context.Background(), testEntryID); err != nil {
+				t.Fatalf("advance() = %v", err)
+			}
+			got := [2]string{f.status(STAGE_NARRATION), f.status(video)}
+			if got != c.want {
+				t.Errorf("statuses = %v, want %v", got, c.want)
+			}
+			if !slices.Equal(f.queue.queued, c.queued) {
+				t.Errorf("queued = %v, want %v", f.queue.queued, c.queued)
+			}
+			failure, failed := f.dynamo.failures["vid"]
+			if failure.Class != c.failure {
+				t.Errorf("video failure = %q, want %q", failure.Class, c.failure)
+			}
+			if failed && !slices.Equal(f.ses.sent, []string{"student@example.com"}) {
+				t.Errorf("failure emails = %v, want one to the requester", f.ses.sent)
+			}
+			if !failed && len(f.ses.sent) > 0 {
+				t.Errorf("failure emails = %v, want none", f.ses.sent)
+			}
+		})
+	}
+}
+
+func TestAdvanceBlockedDetail(t *testing.T) {
+	f := newPipelineFixture(dynamo.StageStatusFailed, dynamo.StageStatusPending)
+	stage := f.dynamo.job.Pipeline[STAGE_NARRATION]
+	stage.Error = "polly unavailable"
+	f.dynamo.job.Pipeline[STAGE_NARRATION] = stage
+	if err := f.p.advance(context.Background(), testEntryID); err != nil {
+		t.Fatalf("advance() = %v", err)
+	}
+	want := "stage narration failed: polly unavailable"
+	if got := f.dynamo.job.Pipeline[VideoStageName("vid")].Error; got != want {
+		t.Errorf("video error = %q, want %q", got, want)
+	}
+	if got := f.dynamo.failures["vid"].Detail; got != want {
+		t.Errorf("video failure detail = %q, want %q", got, want)
+	}
+}
+
+func TestAdvanceEnqueueError(t *testing.T) {
+	f := newPipelineFixture(dynamo.StageStatusPending, dynamo.StageStatusPending, "assets/1_abc/Summary.txt")
+	f.queue.err = errors.New("redis is down")
+	if err := f.p.advance(context.Background(), testEntryID); err == nil {
+		t.Fatal("advance() = nil, want the enqueue error")
+	}
+	// The claim on the stage is released so the next advance queues it
+	if got := f.status(STAGE_NARRATION); got != dynamo.StageStatusPending {
+		t.Errorf("narration = %s, want %s", got, dynamo.StageStatusPending)
+	}
+}
+
+func TestAdvanceMissingJob(t *testing.T) {
+	f := newPipelineFixture(dynamo.StageStatusPending, dynamo.StageStatusPending)
+	if err := f.p.advance(context.Background(), "1_gone"); err != nil {
+		t.Errorf("advance() = %v, want nil", err)
+	}
+	if len(f.queue.queued) > 0 {
+		t.Errorf("queued = %v, want nothing", f.queue.queued)
+	}
+}
+
+func TestPipelineRetry(t *testing.T) {
+	video := VideoStageName("vid")
+	cases := []struct {
+		name      string
+		narration string
+		video     string
+		stage     string
+		// want are the statuses of the narration and video stages afterwards
+		want    [2]string
+		queued  []string
+		skipped bool
+	}{
+		{
+			name:      "failed narration runs again and unblocks the video",
+			narration: dynamo.StageStatusFailed,
+			video:     dynamo.StageStatusFailed,
+			stage:     STAGE_NARRATION,
+			want:      [2]string{dynamo.StageStatusQueued, dynamo.StageStatusPending},
+			queued:    []string{NarrationTask + "@" + QueueHigh},
+		},
+		{
+			name:      "finished video is left alone when narration runs again",
+			narration: dynamo.StageStatusDone,
+			video:     dynamo.StageStatusDone,
+			stage:     STAGE_NARRATION,
+			want:      [2]string{dynamo.StageStatusQueued, dynamo.StageStatusDone},
+			queued:    []string{NarrationTask + "@" + QueueHigh},
+		},
+		{
+			name:      "failed video runs again",
+			narration: dynamo.StageStatusDone,
+			video:     dynamo.StageStatusFailed,
+			stage:     video,
+			want:      [2]string{dynamo.StageStatusDone, dynamo.StageStatusQueued},
+			queued:    []string{VideoGenerationTask + "@" + QueueMedium},
+		},
+		{
+			name:      "running stage can't be retried",
+			narration: dynamo.StageStatusRunning,
+			video:     dynamo.StageStatusPending,
+			stage:     STAGE_NARRATION,
+			want:      [2]string{dynamo.StageStatusRunning, dynamo.StageStatusPending},
+			skipped:   true,
+		},
+		{
+			name:      "unknown stage can't be retried",
+			narration: dynamo.StageStatusFailed,
+			video:     dynamo.StageStatusFailed,
+			stage:     "video:other",
+			want:      [2]string{dynamo.StageStatusFailed, dynamo.StageStatusFailed},
+			skipped:   true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			f := newPipelineFixture(c.narration, c.video, "assets/1_abc/Summary.txt", "assets/1_abc/Audio.aac")
+			// The stages ran before, so their outputs don't let them be skipped
+			for name, stage := range f.dynamo.job.Pipeline {
+				stage.Attempts = 1
+				f.dynamo.job.Pipeline[name] = stage
+			}
+			task, err := NewPipelineRetryTask(testEntryID, c.stage)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = f.p.HandlePipelineRetryTask(context.Background(), task)
+			if c.skipped != errors.Is(err, asynq.SkipRetry) {
+				t.Errorf("HandlePipelineRetryTask() = %v, skipped want %v", err, c.skipped)
+			}
+			if !c.skipped && err != nil {
+				t.Errorf("HandlePipelineRetryTask() = %v", err)
+			}
+			got := [2]string{f.status(STAGE_NARRATION), f.status(video)}
+			if got != c.want {
+				t.Errorf("statuses = %v, want %v", got, c.want)
+			}
+			if !slices.Equal(f.queue.queued, c.queued) {
+				t.Errorf("queued = %v, want %v", f.queue.queued, c.queued)
+			}
+		})
+	}
+}
+
+func TestStageHandler(t *testing.T) {
+	handlerErr := errors.New("polly failed")
+	cases := []struct {
+		name    string
+		err     error
+		files   []string
+		want    string
+		wantErr string
+	}{
+		{"done", nil, []string{"assets/1_abc/Audio.aac"}, dynamo.StageStatusDone, ""},
+		{"outputs not written", nil, nil, dynamo.StageStatusFailed, "did not write assets/1_abc/Audio.aac"},
+		{"failed", handlerErr, nil, dynamo.StageStatusFailed, handlerErr.Error()},
+		{"skip retry", fmt.Errorf("bad script: %w", asynq.SkipRetry), nil, dynamo.StageStatusFailed, "bad script: skip retry for the task"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			f := newPipelineFixture(dynamo.StageStatusQueued, dynamo.StageStatusPending, c.files...)
+			var ranAs string
+			handler := f.p.StageHandler(NarrationStageOf, func(ctx context.Context, t *asynq.Task) error {
+				ranAs = f.status(STAGE_NARRATION)
+				return c.err
+			})
+			task, err := NewNarrationTask(testEntryID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := handler(context.Background(), task); !errors.Is(err, c.err) {
+				t.Errorf("handler() = %v, want %v", err, c.err)
+			}
+			if ranAs != dynamo.StageStatusRunning {
+				t.Errorf("stage was %s while its task ran, want %s", ranAs, dynamo.StageStatusRunning)
+			}
+			stage := f.dynamo.job.Pipeline[STAGE_NARRATION]
+			if stage.Status != c.want || stage.Error != c.wantErr || stage.Attempts != 1 {
+				t.Errorf("stage = %s %q after %d attempts, want %s %q after 1", stage.Status, stage.Error, stage.Attempts, c.want, c.wantErr)
+			}
+			// The pipeline is advanced whichever way the stage ended
+			if want := []string{PipelineAdvanceTask + "@" + QueueHigh}; !slices.Equal(f.queue.queued, want) {
+				t.Errorf("queued = %v, want %v", f.queue.queued, want)
+			}
+		})
+	}
+}
+
+func TestStageHandlerUntracked(t *testing.T) {
+	// Tasks queued before the pipeline existed have no stage to move
+	f := newPipelineFixture(dynamo.StageStatusDone, dynamo.StageStatusPending)
+	delete(f.dynamo.job.Pipeline, STAGE_NARRATION)
+	ran := false
+	handler := f.p.StageHandler(NarrationStageOf, func(ctx context.Context, t *asynq.Task) error {
+		ran = true
+		return nil
+	})
+	task, err := NewNarrationTask(testEntryID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := handler(context.Background(), task); err != nil {
+		t.Errorf("handler() = %v", err)
+	}
+	if !ran {
+		t.Error("the task did not run")
+	}
+	if len(f.queue.queued) > 0 {
+		t.Errorf("queued = %v, want nothing", f.queue.queued)
+	}
+}
+
+func TestStartPipeline(t *testing.T) {
+	cases := []struct {
+		name      string
+		narration string
+		video     string
+		// want are the statuses of the narration and video stages afterwards
+		want [2]string
+	}{
+		{"new job", "", "", [2]string{dynamo.StageStatusPending, dynamo.StageStatusPending}},
+		{"failed narration runs again", dynamo.StageStatusFailed, dynamo.StageStatusFailed, [2]string{dynamo.StageStatusPending, dynamo.StageStatusPending}},
+		{"expired video runs again", dynamo.StageStatusDone, dynamo.StageStatusDone, [2]string{dynamo.StageStatusDone, dynamo.StageStatusPending}},
+		{"running narration is kept", dynamo.StageStatusRunning, dynamo.StageStatusPending, [2]string{dynamo.StageStatusRunning, dynamo.StageStatusPending}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			f := newPipelineFixture(c.narration, c.video)
+			if c.narration == "" {
+				f.dynamo.job.Pipeline = make(map[string]dynamo.PipelineStage)
+			}
+			job, _ := f.dynamo.GetJob(context.Background(), testEntryID)
+			err := StartPipeline(context.Background(), f.dynamo, f.queue, job, *f.dynamo.videoRequests["vid"])
+			if err != nil {
+				t.Fatalf("StartPipeline() = %v", err)
+			}
+			got := [2]string{f.status(STAGE_NARRATION), f.status(VideoStageName("vid"))}
+			if got != c.want {
+				t.Errorf("statuses = %v, want %v", got, c.want)
+			}
+			if want := []string{PipelineAdvanceTask + "@" + QueueHigh}; !slices.Equal(f.queue.queued, want) {
+				t.Errorf("queued = %v, want %v", f.queue.queued, want)
+			}
+		})
+	}
+}
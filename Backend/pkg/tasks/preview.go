@@ -0,0 +1,79 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+
+	dynamo "github.com/Kanishk-K/UniteDownloader/Backend/pkg/dynamoClient"
+	"github.com/Kanishk-K/UniteDownloader/Backend/pkg/ffmpeg"
+	"github.com/Kanishk-K/UniteDownloader/Backend/pkg/videoutil"
+)
+
+// uploadPreviews renders a poster frame and an animated preview of the encoded video and uploads them next to it.
+// Previews are best effort, a video without them is still listed, so only a cancelled task returns an error.
+func (p *GenerateVideoProcess) uploadPreviews(ctx context.Context, workingDir string, payload VideoGenerationPayload, video string, duration float64) (dynamo.VideoPreviews, error) {
+	var previews dynamo.VideoPreviews
+	offset := videoutil.PreviewOffset(duration)
+
+	// Step 1: The poster is a single frame
+	posterKey := videoutil.PosterKey(payload.EntryID, payload.VideoID)
+	err := p.uploadPreview(ctx, workingDir, videoutil.PosterCommand(video, offset), videoutil.POSTER_FILE, posterKey, "image/jpeg")
+	if err != nil {
+		log.Printf("Failed to create poster for %s: %v", payload.EntryID, err)
+	} else {
+		previews.Poster = posterKey
+	}
+	if ctx.Err() != nil {
+		return previews, ctx.Err()
+	}
+
+	// Step 2: The preview loops a few seconds of the video
+	previewKey := videoutil.PreviewKey(payload.EntryID, payload.VideoID)
+	err = p.uploadPreview(ctx, workingDir, videoutil.PreviewCommand(video, offset), videoutil.PREVIEW_FILE, previewKey, "image/webp")
+	if err != nil {
+		log.Printf("Failed to create preview for %s: %v", payload.EntryID, err)
+	} else {
+		previews.Preview = previewKey
+	}
+	return previews, ctx.Err()
+}
+
+// uploadPreview runs command, which writes file in workingDir, and uploads the file to key.
+func (p *GenerateVideoProcess) uploadPreview(ctx context.Context, workingDir string, command *ffmpeg.Command, file string, key string, contentType string) error {
+	err := ffmpeg.Run(command.Cmd(ctx, workingDir))
+	if err != nil {
+		var exitErr *ffmpeg.ExitError
+		if errors.As(err, &exitErr) {
+			log.Printf("ffmpeg stderr for %s:\n%s", key, exitErr.Tail)
+		}
+		return err
+	}
+	fp, err := os.Open(filepath.Join(workingDir, file))
+	if err != nil {
+		log.Printf("Failed to open %s: %v", file, err)
+		return err
+	}
+	defer fp.Close()
+	return p.s3Client.UploadFile(ctx, BUCKET, key, fp, contentType)
+}
+
+// deleteVideoAssets removes everything uploaded for a video that could not be listed on its job.
+// It runs even if the task was cancelled so a retry starts clean.
+func (p *GenerateVideoProcess) deleteVideoAssets(ctx context.Context, payload VideoGenerationPayload) {
+	ctx = context.WithoutCancel(ctx)
+	for _, key := range videoutil.VideoAssetKeys(payload.EntryID, payload.VideoID) {
+		if err := p.s3Client.DeleteFile(ctx, BUCKET, key); err != nil {
+			log.Printf("Failed to delete unlisted video asset %s: %v", key, err)
+		}
+	}
+	// HLS streams and parts are stored as many files under the video's prefix
+	if payload.Format == videoutil.FORMAT_HLS || payload.PartLength > 0 {
+		prefix := videoutil.VideoPrefix(payload.EntryID, payload.VideoID)
+		if err := p.s3Client.DeletePrefix(ctx, BUCKET, prefix); err != nil {
+			log.Printf("Failed to delete unlisted video files under %s: %v", prefix, err)
+		}
+	}
+}
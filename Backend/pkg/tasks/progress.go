@@ -0,0 +1,91 @@
+package tasks
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"math"
+	"time"
+
+	dynamo "github.com/Kanishk-K/UniteDownloader/Backend/pkg/dynamoClient"
+	"github.com/Kanishk-K/UniteDownloader/Backend/pkg/ffmpeg"
+	"github.com/hibiken/asynq"
+)
+
+const (
+	// The VideoRequests item is only written when progress moves by this many percent, or at most this often
+	PROGRESS_STEP     = 5
+	PROGRESS_INTERVAL = 10 * time.Second
+)
+
+// VideoProgress is written to the asynq task result while the video encodes.
+type VideoProgress struct {
+	Percent int    `json:"percent"`
+	Speed   string `json:"speed,omitempty"`
+}
+
+// progressReporter turns ffmpeg progress reports into a percentage and publishes it
+// to the asynq task result and the video request so the extension can poll it.
+type progressReporter struct {
+	ctx          context.Context
+	task         *asynq.Task
+	dynamoClient dynamo.DynamoMethods
+	entryID      string
+	videoID      string
+	// duration is the expected length of the output in seconds
+	duration      float64
+	lastPercent   int
+	lastPublished time.Time
+}
+
+func newProgressReporter(ctx context.Context, task *asynq.Task, dynamoClient dynamo.DynamoMethods, entryID string, videoID string, duration float64) *progressReporter {
+	return &progressReporter{
+		ctx:          ctx,
+		task:         task,
+		dynamoClient: dynamoClient,
+		entryID:      entryID,
+		videoID:      videoID,
+		duration:     duration,
+		lastPercent:  -1,
+	}
+}
+
+// Report is passed to ffmpeg.RunWithProgress. 100 is only published once the video has been uploaded.
+func (pr *progressReporter) Report(progress ffmpeg.Progress) {
+	if pr.duration <= 0 {
+		return
+	}
+	percent := int(math.Min(progress.OutTime/pr.duration*100, 99))
+	if percent == pr.lastPercent {
+		return
+	}
+	pr.writeResult(VideoProgress{Percent: percent, Speed: progress.Speed})
+	if percent-pr.lastPercent >= PROGRESS_STEP || time.Since(pr.lastPublished) >= PROGRESS_INTERVAL {
+		pr.publish(percent)
+	}
+	pr.lastPercent = percent
+}
+
+// Complete marks the video as finished.
+func (pr *progressReporter) Complete() {
+	pr.writeResult(VideoProgress{Percent: 100})
+	pr.publish(100)
+}
+
+func (pr *progressReporter) writeResult(progress VideoProgress) {
+	result, err := json.Marshal(progress)
+	if err != nil {
+		return
+	}
+	if _, err := pr.task.ResultWriter().Write(result); err != nil {
+		log.Printf("Failed to write task progress: %v", err)
+	}
+}
+
+// publish updates the video request, a failure here should never fail the encode.
+func (pr *progressReporter) publish(percent int) {
+	pr.lastPublished = time.Now()
+	if err := pr.dynamoClient.UpdateVideoProgress(pr.ctx, pr.entryID, pr.videoID, percent); err != nil {
+		log.Printf("Failed to publish progress for %s: %v", pr.entryID, err)
+	}
+}
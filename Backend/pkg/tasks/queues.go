@@ -0,0 +1,22 @@
+package tasks
+
+import (
+	"context"
+
+	"github.com/hibiken/asynq"
+)
+
+// Queues the consumer serves, weighted by CONSUMER_QUEUES
+const (
+	QueueHigh   = "high"
+	QueueMedium = "medium"
+	QueueLow    = "low"
+)
+
+// QueuesByPriority lists the queues from lowest to highest priority.
+var QueuesByPriority = []string{QueueLow, QueueMedium, QueueHigh}
+
+// Enqueuer queues tasks, it is satisfied by *asynq.Client.
+type Enqueuer interface {
+	EnqueueContext(ctx context.Context, task *asynq.Task, opts ...asynq.Option) (*asynq.TaskInfo, error)
+}
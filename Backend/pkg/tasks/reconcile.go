@@ -0,0 +1,307 @@
+package tasks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	dynamo "github.com/Kanishk-K/UniteDownloader/Backend/pkg/dynamoClient"
+	s3client "github.com/Kanishk-K/UniteDownloader/Backend/pkg/s3Client"
+	"github.com/Kanishk-K/UniteDownloader/Backend/pkg/videoutil"
+	"github.com/hibiken/asynq"
+)
+
+const ReconcileTask = "reconcile"
+
+const (
+	// A step that hasn't moved RECONCILE_GRACE after it was requested is treated as lost, queued stages and
+	// videos are picked up long before this
+	RECONCILE_GRACE = 30 * time.Minute
+	// Each run writes its report under this prefix
+	RECONCILE_REPORT_PREFIX = "reports/reconcile/"
+)
+
+// ReconcileReport is what a reconciliation run found and what it did about it.
+type ReconcileReport struct {
+	StartedOn     string `json:"startedOn"`
+	FinishedOn    string `json:"finishedOn"`
+	Jobs          int    `json:"jobs"`
+	VideoRequests int    `json:"videoRequests"`
+	// StagesResumed are pipeline stages whose task was lost or finished without the stage hearing of it
+	StagesResumed []string `json:"stagesResumed"`
+	// VideosRequeued are video requests with no video, no stage and no task, they were added to the pipeline
+	VideosRequeued []string `json:"videosRequeued"`
+	// MissingVideos are videos listed on their job whose files are gone
+	MissingVideos []string `json:"missingVideos"`
+	// OrphanedRequests are video requests whose job no longer exists
+	OrphanedRequests []string `json:"orphanedRequests"`
+	// OrphanedAssets are folders under assets/ with no job
+	OrphanedAssets []string `json:"orphanedAssets"`
+	Errors         []string `json:"errors,omitempty"`
+}
+
+// ReconcileProcess repairs what lost tasks and dropped stream events leave behind. A stage whose task was lost
+// would wait forever, and a video request whose stream event was dropped never reaches the pipeline.
+type ReconcileProcess struct {
+	s3Client     s3client.S3Methods
+	dynamoClient dynamo.DynamoMethods
+	queue        *asynq.Client
+	inspector    *asynq.Inspector
+}
+
+func NewReconcileProcess(s3Client s3client.S3Methods, dynamoClient dynamo.DynamoMethods, queue *asynq.Client, inspector *asynq.Inspector) *ReconcileProcess {
+	return &ReconcileProcess{s3Client, dynamoClient, queue, inspector}
+}
+
+func NewReconcileTask() *asynq.Task {
+	return asynq.NewTask(ReconcileTask, nil)
+}
+
+// reconcileRun holds what a single run has read so far.
+type reconcileRun struct {
+	report ReconcileReport
+	// files are the keys under each job's asset folder, listed when first needed
+	files map[string]map[string]bool
+}
+
+func (r *reconcileRun) fail(format string, args ...any) {
+	message := fmt.Sprintf(format, args...)
+	log.Println(message)
+	r.report.Errors = append(r.report.Errors, message)
+}
+
+func (p *ReconcileProcess) HandleReconcileTask(ctx context.Context, t *asynq.Task) error {
+	run := &reconcileRun{
+		report: ReconcileReport{StartedOn: time.Now().Format(dynamo.DATE_FORMAT)},
+		files:  make(map[string]map[string]bool),
+	}
+
+	// Step 1: Read the tables and asset folders up front so every check works from the same snapshot
+	jobs, err := p.dynamoClient.ScanJobs(ctx)
+	if err != nil {
+		return err
+	}
+	videoRequests, err := p.dynamoClient.ScanVideoRequests(ctx)
+	if err != nil {
+		return err
+	}
+	assetPrefixes, err := p.s3Client.ListPrefixes(ctx, BUCKET, "assets/")
+	if err != nil {
+		log.Printf("Failed to list asset folders: %v", err)
+		return err
+	}
+	run.report.Jobs, run.report.VideoRequests = len(jobs), len(videoRequests)
+	jobsByID := make(map[string]*dynamo.JobDocument, len(jobs))
+	for i := range jobs {
+		jobsByID[jobs[i].EntryID] = &jobs[i]
+	}
+
+	// Step 2: Resume pipelines whose stages were lost
+	for _, job := range jobs {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		p.reconcilePipeline(ctx, run, job)
+	}
+
+	// Step 3: Add video requests to the pipeline that never reached it
+	for _, videoRequest := range videoRequests {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		p.reconcileVideo(ctx, run, jobsByID[videoRequest.EntryID], videoRequest)
+	}
+
+	// Step 4: Report asset folders left behind by jobs that were deleted
+	for _, prefix := range assetPrefixes {
+		entryID := strings.TrimSuffix(strings.TrimPrefix(prefix, "assets/"), "/")
+		if _, ok := jobsByID[entryID]; !ok {
+			run.report.OrphanedAssets = append(run.report.OrphanedAssets, entryID)
+		}
+	}
+
+	// Step 5: Keep the report in S3 and a summary in the task result
+	run.report.FinishedOn = time.Now().Format(dynamo.DATE_FORMAT)
+	return p.writeReport(ctx, t, run.report)
+}
+
+// reconcilePipeline resumes the stages of a job that have not moved in a while. A stage whose task is gone is
+// queued again, one whose task finished without the stage hearing of it is finished, and pending stages are
+// advanced in case the advance that should have started them was lost.
+func (p *ReconcileProcess) reconcilePipeline(ctx context.Context, run *reconcileRun, job dynamo.JobDocument) {
+	advance := false
+	for _, name := range slices.Sorted(maps.Keys(job.Pipeline)) {
+		stage := job.Pipeline[name]
+		if !pastGrace(stage.UpdatedOn) {
+			continue
+		}
+		id := fmt.Sprintf("%s/%s", job.EntryID, name)
+		switch stage.Status {
+		case dynamo.StageStatusPending:
+			advance = true
+		case dynamo.StageStatusQueued, dynamo.StageStatusRunning:
+			info, err := p.taskInfo(stageTaskID(job.EntryID, name))
+			if err != nil {
+				run.fail("Failed to look up the task for %s: %v", id, err)
+				continue
+			}
+			if info != nil && info.State != asynq.TaskStateCompleted && info.State != asynq.TaskStateArchived {
+				continue
+			}
+			status, detail := dynamo.StageStatusPending, ""
+			if info != nil && info.State == asynq.TaskStateCompleted {
+				status = dynamo.StageStatusDone
+			} else if info != nil {
+				status, detail = dynamo.StageStatusFailed, info.LastErr
+			}
+			log.Printf("Stage %s was left %s, moving it to %s", id, stage.Status, status)
+			err = p.dynamoClient.UpdatePipelineStage(ctx, job.EntryID, name, status, detail, []string{stage.Status})
+			if err != nil {
+				run.fail("Failed to resume %s: %v", id, err)
+				continue
+			}
+			advance = true
+		default:
+			continue
+		}
+		run.report.StagesResumed = append(run.report.StagesResumed, id)
+	}
+	if advance {
+		if err := AdvancePipeline(ctx, p.queue, job.EntryID); err != nil {
+			run.fail("Failed to advance the pipeline of %s: %v", job.EntryID, err)
+		}
+	}
+}
+
+// reconcileVideo checks a video request against its job, its files and the queue, adding it to the pipeline if it was lost.
+func (p *ReconcileProcess) reconcileVideo(ctx context.Context, run *reconcileRun, job *dynamo.JobDocument, videoRequest dynamo.VideoRequestDocument) {
+	id := fmt.Sprintf("%s/%s", videoRequest.EntryID, videoRequest.RequestedVideo)
+	if job == nil {
+		run.report.OrphanedRequests = append(run.report.OrphanedRequests, id)
+		return
+	}
+	if slices.Contains(job.VideosAvailable, videoRequest.RequestedVideo) || job.VideoParts[videoRequest.RequestedVideo] > 0 {
+		files, err := p.assetFiles(ctx, run, videoRequest.EntryID)
+		if err != nil {
+			run.fail("Failed to list assets for %s: %v", videoRequest.EntryID, err)
+			return
+		}
+		if !files[outputKey(videoRequest)] {
+			run.report.MissingVideos = append(run.report.MissingVideos, id)
+		}
+		return
+	}
+	if _, ok := job.Pipeline[VideoStageName(videoRequest.RequestedVideo)]; ok {
+		// The pipeline runs the request, its stage was reconciled with the job
+		return
+	}
+	// The user was emailed about videos that failed for good, they can request them again
+	if videoRequest.Failure != nil && !videoRequest.Failure.Retryable {
+		return
+	}
+	if !pastGrace(videoRequest.RequestedOn) {
+		return
+	}
+	queued, err := p.hasTask(VideoGenerationTaskID(videoRequest.EntryID, videoRequest.RequestedVideo))
+	if err != nil {
+		run.fail("Failed to look up the task for %s: %v", id, err)
+		return
+	}
+	if queued {
+		return
+	}
+
+	log.Printf("Video %s never reached the pipeline, adding it", id)
+	err = StartPipeline(ctx, p.dynamoClient, p.queue, job, videoRequest)
+	if err != nil {
+		run.fail("Failed to add %s to the pipeline: %v", id, err)
+		return
+	}
+	run.report.VideosRequeued = append(run.report.VideosRequeued, id)
+}
+
+// hasTask reports whether asynq has the task in any state, including finished tasks it still retains.
+func (p *ReconcileProcess) hasTask(taskID string) (bool, error) {
+	info, err := p.taskInfo(taskID)
+	return info != nil, err
+}
+
+// taskInfo finds the task in whichever queue holds it, it is nil if asynq no longer has the task.
+func (p *ReconcileProcess) taskInfo(taskID string) (*asynq.TaskInfo, error) {
+	for _, queue := range QueuesByPriority {
+		info, err := p.inspector.GetTaskInfo(queue, taskID)
+		if err == nil {
+			return info, nil
+		}
+		if !errors.Is(err, asynq.ErrTaskNotFound) && !errors.Is(err, asynq.ErrQueueNotFound) {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+// assetFiles lists the keys under a job's asset folder once per run.
+func (p *ReconcileProcess) assetFiles(ctx context.Context, run *reconcileRun, entryID string) (map[string]bool, error) {
+	if files, ok := run.files[entryID]; ok {
+		return files, nil
+	}
+	keys, err := p.s3Client.ListFiles(ctx, BUCKET, fmt.Sprintf("assets/%s/", entryID))
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		files[key] = true
+	}
+	run.files[entryID] = files
+	return files, nil
+}
+
+// outputKey is the file a finished video request always has.
+func outputKey(videoRequest dynamo.VideoRequestDocument) string {
+	switch {
+	case videoRequest.PartLength > 0:
+		return videoutil.PartKey(videoRequest.EntryID, videoRequest.RequestedVideo, 1)
+	case videoRequest.Format == videoutil.FORMAT_HLS:
+		return videoutil.HLSMasterKey(videoRequest.EntryID, videoRequest.RequestedVideo)
+	default:
+		return videoutil.VideoKey(videoRequest.EntryID, videoRequest.RequestedVideo)
+	}
+}
+
+// pastGrace reports whether a step requested at the given time should have finished, unreadable times are skipped.
+func pastGrace(requestedOn string) bool {
+	requested, err := time.ParseInLocation(dynamo.DATE_FORMAT, requestedOn, time.Local)
+	if err != nil {
+		return false
+	}
+	return time.Since(requested) > RECONCILE_GRACE
+}
+
+func (p *ReconcileProcess) writeReport(ctx context.Context, t *asynq.Task, report ReconcileReport) error {
+	reportBytes, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		log.Printf("Failed to marshal reconciliation report: %v", err)
+		return err
+	}
+	key := RECONCILE_REPORT_PREFIX + time.Now().UTC().Format("2006-01-02T15-04-05") + ".json"
+	err = p.s3Client.UploadFile(ctx, BUCKET, key, bytes.NewReader(reportBytes), "application/json")
+	if err != nil {
+		log.Printf("Failed to upload reconciliation report: %v", err)
+		return err
+	}
+	log.Printf("Reconciled %d jobs and %d video requests: %d stages resumed, %d videos requeued, %d missing videos, %d orphaned requests, %d orphaned asset folders, %d errors (%s)",
+		report.Jobs, report.VideoRequests, len(report.StagesResumed), len(report.VideosRequeued), len(report.MissingVideos),
+		len(report.OrphanedRequests), len(report.OrphanedAssets), len(report.Errors), key)
+	if _, err := t.ResultWriter().Write([]byte(key)); err != nil {
+		log.Printf("Failed to write task result: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/Kanishk-K/UniteDownloader/Backend/pkg/ffmpeg"
+	subtitleclient "github.com/Kanishk-K/UniteDownloader/Backend/pkg/subtitleClient"
+	"github.com/Kanishk-K/UniteDownloader/Backend/pkg/videoutil"
+	"github.com/hibiken/asynq"
+)
+
+// muxTracks adds chapters from the lecture notes and, if soft, the subtitles as a caption track to the encoded video.
+// It returns the file to upload, which is video itself when there is nothing to add.
+func (p *GenerateVideoProcess) muxTracks(ctx context.Context, t *asynq.Task, payload VideoGenerationPayload, inputs encodeInputs, video string, words []subtitleclient.WordTimeStamp, soft bool) (string, error) {
+	// Step 1: Chapters follow the headings of the notes, a video without them is still usable
+	chapters := ""
+	notes, err := p.readNotes(ctx, payload.EntryID)
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		log.Printf("No notes for %s, the video will not have chapters: %v", payload.EntryID, err)
+	} else if outline := subtitleclient.OutlineChapters(notes, words, inputs.duration); len(outline) > 0 {
+		err = os.WriteFile(filepath.Join(inputs.workingDir, videoutil.CHAPTERS_FILE), []byte(subtitleclient.GenerateFFMetadata(outline)), 0644)
+		if err != nil {
+			log.Printf("Error writing chapters file: %v", err)
+			return "", err
+		}
+		chapters = videoutil.CHAPTERS_FILE
+	}
+
+	// Step 2: Captions are the subtitle lines without the karaoke highlighting
+	captions := ""
+	if soft {
+		srtContent := subtitleclient.GenerateSRTContent(subtitleclient.GenerateSubtitleLines(words))
+		err = os.WriteFile(filepath.Join(inputs.workingDir, videoutil.CAPTIONS_FILE), []byte(srtContent), 0644)
+		if err != nil {
+			log.Printf("Error writing captions file: %v", err)
+			return "", err
+		}
+		captions = videoutil.CAPTIONS_FILE
+	}
+	if chapters == "" && captions == "" {
+		return video, nil
+	}
+
+	// Step 3: Copy the encoded streams into a new file along with the tracks
+	err = ffmpeg.Run(videoutil.MuxCommand(video, captions, chapters, videoutil.MUXED_FILE).Cmd(ctx, inputs.workingDir))
+	if err != nil {
+		var exitErr *ffmpeg.ExitError
+		if errors.As(err, &exitErr) && !soft {
+			// Chapters are best effort, upload the video without them
+			log.Printf("Failed to add chapters for %s:\n%s", payload.EntryID, exitErr.Tail)
+			return video, nil
+		}
+		return "", p.failEncode(ctx, t, payload, err)
+	}
+	return videoutil.MUXED_FILE, nil
+}
+
+// readNotes reads the markdown notes generated for the lecture.
+func (p *GenerateVideoProcess) readNotes(ctx context.Context, entryID string) (string, error) {
+	notesBytes, err := p.s3Client.ReadFile(ctx, BUCKET, fmt.Sprintf("assets/%s/Notes.md", entryID))
+	if err != nil {
+		return "", err
+	}
+	defer notesBytes.Close()
+	notes, err := io.ReadAll(notesBytes)
+	if err != nil {
+		log.Printf("Error reading notes: %v", err)
+		return "", err
+	}
+	return string(notes), nil
+}
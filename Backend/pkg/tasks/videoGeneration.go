@@ -3,18 +3,22 @@ package tasks
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
-	"math/rand/v2"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"time"
 
 	cognitoclient "github.com/Kanishk-K/UniteDownloader/Backend/pkg/cognitoClient"
 	dynamo "github.com/Kanishk-K/UniteDownloader/Backend/pkg/dynamoClient"
+	"github.com/Kanishk-K/UniteDownloader/Backend/pkg/ffmpeg"
 	s3client "github.com/Kanishk-K/UniteDownloader/Backend/pkg/s3Client"
 	sesclient "github.com/Kanishk-K/UniteDownloader/Backend/pkg/sesClient"
+	subtitleclient "github.com/Kanishk-K/UniteDownloader/Backend/pkg/subtitleClient"
+	"github.com/Kanishk-K/UniteDownloader/Backend/pkg/videoutil"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/hibiken/asynq"
 )
@@ -23,9 +27,10 @@ const BUCKET = "lecture-processor"
 const VideoGenerationTask = "videoGeneration"
 
 type VideoGenerationPayload struct {
-	EntryID         string `json:"entryID"`
-	RequestedBy     string `json:"requestedBy"`
-	BackgroundVideo string `json:"backgroundVideo"`
+	EntryID     string `json:"entryID"`
+	RequestedBy string `json:"requestedBy"`
+	VideoID     string `json:"videoID"`
+	dynamo.VideoOptions
 }
 
 type GenerateVideoProcess struct {
@@ -33,17 +38,20 @@ type GenerateVideoProcess struct {
 	dynamoClient  dynamo.DynamoMethods
 	sesClient     sesclient.SESMethods
 	cognitoClient cognitoclient.CognitoMethods
+	assets        *videoutil.AssetCache
+	ffmpegVersion ffmpeg.Version
 }
 
-func NewGenerateVideoProcess(s3Client s3client.S3Methods, dynamoClient dynamo.DynamoMethods, sesClient sesclient.SESMethods, cognitoClient cognitoclient.CognitoMethods) *GenerateVideoProcess {
-	return &GenerateVideoProcess{s3Client, dynamoClient, sesClient, cognitoClient}
+func NewGenerateVideoProcess(s3Client s3client.S3Methods, dynamoClient dynamo.DynamoMethods, sesClient sesclient.SESMethods, cognitoClient cognitoclient.CognitoMethods, assets *videoutil.AssetCache, ffmpegVersion ffmpeg.Version) *GenerateVideoProcess {
+	return &GenerateVideoProcess{s3Client, dynamoClient, sesClient, cognitoClient, assets, ffmpegVersion}
 }
 
-func NewVideoGenerationTask(entryID string, requestedBy string, backgroundVideo string) (*asynq.Task, error) {
+func NewVideoGenerationTask(entryID string, requestedBy string, videoID string, options dynamo.VideoOptions) (*asynq.Task, error) {
 	taskInfo := VideoGenerationPayload{
-		EntryID:         entryID,
-		RequestedBy:     requestedBy,
-		BackgroundVideo: backgroundVideo,
+		EntryID:      entryID,
+		RequestedBy:  requestedBy,
+		VideoID:      videoID,
+		VideoOptions: options,
 	}
 	payload, err := json.Marshal(taskInfo)
 	if err != nil {
@@ -52,156 +60,387 @@ func NewVideoGenerationTask(entryID string, requestedBy string, backgroundVideo
 	return asynq.NewTask(VideoGenerationTask, payload), nil
 }
 
+// VideoGenerationTaskID identifies the task for a video request, so a request queued twice only runs once.
+func VideoGenerationTaskID(entryID string, videoID string) string {
+	return fmt.Sprintf("%s:%s", entryID, videoID)
+}
+
+// VideoGenerationOptions are the options every video generation task is queued with.
+func VideoGenerationOptions(entryID string, videoID string, queue string) []asynq.Option {
+	return []asynq.Option{
+		asynq.Queue(queue),
+		asynq.MaxRetry(3),
+		asynq.TaskID(VideoGenerationTaskID(entryID, videoID)),
+		asynq.Retention(time.Hour * 24 * 7),
+	}
+}
+
 func (p *GenerateVideoProcess) HandleVideoGenerationTask(ctx context.Context, t *asynq.Task) error {
 	var payload VideoGenerationPayload
 	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
 		return err
 	}
+	if payload.VideoID == "" {
+		// Tasks queued before output presets are keyed by their background video
+		payload.VideoID = payload.BackgroundVideo
+	}
+	// A request cancelled by its user is deleted, drop its task along with anything a stopped attempt uploaded
+	videoRequest, err := p.dynamoClient.GetVideoRequest(ctx, payload.EntryID, payload.VideoID)
+	if err != nil {
+		return err
+	}
+	if videoRequest == nil {
+		log.Printf("Video %s for %s was cancelled, dropping the task", payload.VideoID, payload.EntryID)
+		p.deleteVideoAssets(ctx, payload)
+		return fmt.Errorf("video request was cancelled: %w", asynq.SkipRetry)
+	}
+	title, videoKeys, err := p.generateVideo(ctx, t, payload)
+	if err != nil {
+		var recorded *recordedFailure
+		if ctx.Err() == nil && !errors.As(err, &recorded) && !canRetry(ctx) {
+			// Out of retries on an error that wasn't classified, record it so the user hears about it
+			return p.failVideo(ctx, t, payload, ffmpeg.FailureUnknown, true, err)
+		}
+		return err
+	}
+
+	// Get the user's actual email from cognito given the username
+	email, err := p.cognitoClient.GetEmailFromUsername(ctx, payload.RequestedBy)
+	if err != nil {
+		log.Printf("Failed to get email from username: %v", err)
+		return fmt.Errorf("failed to get email from username: %w", asynq.SkipRetry)
+	}
+
+	err = p.sesClient.SendEmail(ctx, email, title, videoKeys, payload.VideoID)
+	if err != nil {
+		log.Printf("Failed to send email: %v", err)
+		return fmt.Errorf("failed to send email: %w", asynq.SkipRetry)
+	}
+
+	return nil
+}
+
+// generateVideo renders and uploads the video and lists it on the job, returning the job's title and the S3 keys
+// the user opens the video from: every part of a split video, the master playlist of an HLS video or the MP4.
+func (p *GenerateVideoProcess) generateVideo(ctx context.Context, t *asynq.Task, payload VideoGenerationPayload) (string, []string, error) {
+	preset, err := videoutil.GetPreset(payload.Preset)
+	if err != nil {
+		log.Printf("Invalid preset for %s: %v", payload.EntryID, err)
+		return "", nil, p.failVideo(ctx, t, payload, FailureInvalidRequest, false, err)
+	}
 
 	workingDir, err := os.MkdirTemp("", payload.EntryID)
 	if err != nil {
 		log.Printf("Error creating temp directory: %v", err)
-		return err
+		return "", nil, err
 	}
 	defer os.RemoveAll(workingDir)
 
+	background, backgroundVideo, err := p.prepareBackground(ctx, payload, workingDir)
+	if err != nil {
+		if errors.Is(err, asynq.SkipRetry) {
+			return "", nil, p.failVideo(ctx, t, payload, FailureInvalidRequest, false, err)
+		}
+		return "", nil, err
+	}
+
 	aacFp, err := os.CreateTemp(workingDir, "audio-*.aac")
 	if err != nil {
 		log.Printf("Error creating temp audio file: %v", err)
-		return err
+		return "", nil, err
 	}
 	defer aacFp.Close()
 	defer os.Remove(aacFp.Name())
 
-	subtitlesFp, err := os.CreateTemp(workingDir, "subtitles-*.ass")
+	_, err = p.s3Client.DownloadFile(ctx, BUCKET, fmt.Sprintf("assets/%s/Audio.aac", payload.EntryID), aacFp)
+	if err != nil {
+		log.Printf("Error downloading audio file from S3: %v", err)
+		return "", nil, err
+	}
+	// The output is cut to the narration with -shortest, so the audio tells us how long the encode has to go
+	audioDuration, err := subtitleclient.ProbeAudioDuration(ctx, aacFp.Name())
 	if err != nil {
-		log.Printf("Error creating temp subtitles file: %v", err)
+		log.Printf("Failed to probe audio duration, progress will not be reported: %v", err)
 	}
-	defer subtitlesFp.Close()
-	defer os.Remove(subtitlesFp.Name())
 
-	aacBytes, err := p.s3Client.ReadFile(BUCKET, fmt.Sprintf("assets/%s/Audio.aac", payload.EntryID))
+	logo, err := p.assets.LogoPath(ctx)
 	if err != nil {
-		log.Printf("Error reading audio file from S3: %v", err)
-		return err
+		return "", nil, err
 	}
-	_, err = io.Copy(aacFp, aacBytes)
+	jobText, err := p.readJobText(ctx, payload)
 	if err != nil {
-		log.Printf("Error putting bytes into aac file: %v", err)
-		return err
+		return "", nil, err
+	}
+	inputs := encodeInputs{
+		preset:          preset,
+		background:      background,
+		backgroundVideo: backgroundVideo,
+		audio:           filepath.Base(aacFp.Name()),
+		logo:            logo,
+		workingDir:      workingDir,
+		duration:        audioDuration,
+		jobText:         jobText,
+	}
+	log.Printf("Generating video for %s", payload.EntryID)
+	progress := newProgressReporter(ctx, t, p.dynamoClient, payload.EntryID, payload.VideoID, audioDuration)
+	// output is the local file the previews are taken from
+	var output string
+	var parts int
+	if payload.PartLength > 0 {
+		output, parts, err = p.encodeParts(ctx, t, payload, inputs, progress)
+	} else {
+		output, err = p.encodeVideo(ctx, t, payload, inputs, progress)
 	}
-	err = aacBytes.Close()
 	if err != nil {
-		log.Printf("Failed to close aac reader: %v", err)
-		return err
+		return "", nil, err
 	}
 
-	subtitleBytes, err := p.s3Client.ReadFile(BUCKET, fmt.Sprintf("assets/%s/Subtitle.ass", payload.EntryID))
+	previews, err := p.uploadPreviews(ctx, workingDir, payload, output, audioDuration)
 	if err != nil {
-		log.Printf("Error reading subtitle file from S3: %v", err)
-		return err
+		log.Printf("Video generation for %s stopped: %v", payload.EntryID, err)
+		p.deleteVideoAssets(ctx, payload)
+		return "", nil, err
 	}
-	_, err = io.Copy(subtitlesFp, subtitleBytes)
-	if err != nil {
-		log.Printf("Error putting bytes into subtitle file: %v", err)
-		return err
+	if previews != (dynamo.VideoPreviews{}) {
+		err = p.dynamoClient.RecordVideoPreviews(ctx, payload.EntryID, payload.VideoID, previews)
+		if err != nil {
+			log.Printf("Failed to record previews for %s: %v", payload.EntryID, err)
+		}
+	}
+
+	var updated *dynamodb.UpdateItemOutput
+	var videoKeys []string
+	if payload.PartLength > 0 {
+		updated, err = p.dynamoClient.AddVideoPartsToJob(ctx, payload.EntryID, payload.VideoID, parts)
+		videoKeys = videoutil.PartKeys(payload.EntryID, payload.VideoID, parts)
+	} else {
+		updated, err = p.dynamoClient.AddVideoToJob(ctx, payload.EntryID, payload.VideoID)
+		if payload.Format == videoutil.FORMAT_HLS {
+			videoKeys = []string{videoutil.HLSMasterKey(payload.EntryID, payload.VideoID)}
+		} else {
+			videoKeys = []string{videoutil.VideoKey(payload.EntryID, payload.VideoID)}
+		}
 	}
-	err = subtitleBytes.Close()
 	if err != nil {
-		log.Printf("Failed to close subtitle reader: %v", err)
-		return err
+		log.Printf("Failed to update job data: %v", err)
+		// The video is not listed on the job, remove it so a retry starts clean even if the task was cancelled
+		p.deleteVideoAssets(ctx, payload)
+		return "", nil, err
 	}
+	log.Printf("Completed video for %s", payload.EntryID)
+	progress.Complete()
+	return updated.Attributes["title"].(*types.AttributeValueMemberS).Value, videoKeys, nil
+}
 
-	dir, err := os.Getwd()
+// encodeInputs are the local files and settings every encode of a video request works from.
+type encodeInputs struct {
+	preset          videoutil.Preset
+	background      videoutil.Background
+	backgroundVideo string
+	// audio is the narration, relative to workingDir
+	audio      string
+	logo       string
+	workingDir string
+	// duration is the length of the narration in seconds, 0 if it could not be probed
+	duration float64
+	// jobText is written on the intro card and names downloads
+	jobText videoutil.OverlayText
+}
+
+// encodeVideo renders the narration as a single MP4 or HLS stream and uploads it, returning the local file to take previews from.
+func (p *GenerateVideoProcess) encodeVideo(ctx context.Context, t *asynq.Task, payload VideoGenerationPayload, inputs encodeInputs, progress *progressReporter) (string, error) {
+	// Single MP4 videos get chapters, and captions if the subtitles are not burned in, muxed in after the encode
+	var words []subtitleclient.WordTimeStamp
+	soft := false
+	if payload.Format != videoutil.FORMAT_HLS {
+		var err error
+		words, err = p.readTimestamps(ctx, payload.EntryID)
+		if err != nil {
+			log.Printf("No timestamps for %s, chapters will not start on sentences: %v", payload.EntryID, err)
+		}
+		soft = payload.Subtitles == videoutil.SUBTITLES_SOFT
+		if soft && len(words) == 0 {
+			// Jobs from before Timestamps.json existed only have the ASS subtitles
+			log.Printf("No timestamps for %s, burning in the subtitles instead", payload.EntryID)
+			soft = false
+		}
+	}
+	subtitles := ""
+	if !soft {
+		subtitlesFp, err := os.CreateTemp(inputs.workingDir, "subtitles-*.ass")
+		if err != nil {
+			log.Printf("Error creating temp subtitles file: %v", err)
+			return "", err
+		}
+		defer subtitlesFp.Close()
+		err = p.writeSubtitles(ctx, payload.EntryID, inputs.preset, subtitlesFp)
+		if err != nil {
+			return "", err
+		}
+		subtitles = filepath.Base(subtitlesFp.Name())
+	}
+	overlays, err := writeOverlays(inputs.workingDir, "overlays.ass", inputs.preset, videoutil.Overlays{
+		Intro:    videoutil.HasOverlay(payload.VideoOptions, videoutil.OVERLAY_INTRO),
+		Outro:    videoutil.HasOverlay(payload.VideoOptions, videoutil.OVERLAY_OUTRO),
+		Progress: videoutil.HasOverlay(payload.VideoOptions, videoutil.OVERLAY_PROGRESS),
+		Text:     inputs.jobText,
+		Duration: inputs.duration,
+	})
 	if err != nil {
-		log.Printf("Failed to get current working directory: %v", err)
-		return err
+		return "", err
 	}
-	backgroundVideo := filepath.Join(dir, "static", fmt.Sprintf("%s.mp4", payload.BackgroundVideo))
-	logoPng := filepath.Join(dir, "static", "logo.png")
-	minuteOffset := rand.IntN(26)
-	secondsOffset := rand.IntN(60)
-	cmd := exec.Command(
-		"ffmpeg",
-		"-y",
-		"-stream_loop",
-		"-1",
-		"-ss",
-		fmt.Sprintf("00:%02d:%02d", minuteOffset, secondsOffset),
-		"-i",
-		backgroundVideo,
-		"-i",
-		filepath.Base(aacFp.Name()),
-		"-i",
-		logoPng,
-		"-filter_complex",
-		// Commented is the FFMPEG 7.1 version. Docker uses 6.1
-		// fmt.Sprintf("ass='%s'[subs];[2]format=rgba,colorchannelmixer=aa=0.3[logo];[logo][0]scale=w=oh*dar:h=rh/12[logo_scaled];[subs][logo_scaled]overlay=x=W-w-10:y=10[output];", filepath.Base(subtitlesFp.Name())),
-		fmt.Sprintf("ass='%s'[subs];[2]format=rgba,colorchannelmixer=aa=0.3[logo];[subs][logo]overlay=main_w-overlay_w-10:10[output];", filepath.Base(subtitlesFp.Name())),
-		"-map",
-		"[output]",
-		"-map",
-		"1:a",
-		"-c:v",
-		"libx264",
-		"-c:a",
-		"copy",
-		"-crf",
-		"30",
-		"-shortest",
-		"output.mp4",
-	)
-	cmd.Dir = workingDir
-	// Put cmd errors into stdout
-	// cmd.Stderr = os.Stderr
-	// cmd.Stdout = os.Stdout
 
-	log.Printf("Generating video for %s", payload.EntryID)
-	err = cmd.Run()
+	command := ffmpeg.New().WithProgress()
+	command.Input(inputs.backgroundVideo).Loop().Seek(inputs.background.RandomOffset())
+	command.Input(inputs.audio)
+	command.Input(inputs.logo)
+	graph := videoutil.NarratedLayout(inputs.preset, "0", "2", subtitles, overlays, p.ffmpegVersion)
+	command.FilterGraph(graph)
+	output := "output.mp4"
+	if payload.Format == videoutil.FORMAT_HLS {
+		renditions := videoutil.AddHLSOutput(command, graph, inputs.preset, "1:a")
+		for _, rendition := range renditions {
+			err = os.MkdirAll(filepath.Join(inputs.workingDir, videoutil.HLS_DIR, rendition.Name), 0755)
+			if err != nil {
+				log.Printf("Error creating HLS directory: %v", err)
+				return "", err
+			}
+		}
+		output = filepath.Join(videoutil.HLS_DIR, renditions[0].Playlist())
+	} else {
+		command.Output(output).
+			Map(videoutil.LAYOUT_OUTPUT).
+			MapStream("1:a").
+			With(inputs.preset.EncoderArgs()...).
+			With("-shortest")
+	}
+
+	err = ffmpeg.RunWithProgress(command.Cmd(ctx, inputs.workingDir), progress.Report)
 	if err != nil {
-		log.Printf("Error in running ffmpeg command: %v", err)
-		return fmt.Errorf("failed to generate video with ffmpeg: %w", asynq.SkipRetry)
+		return "", p.failEncode(ctx, t, payload, err)
 	}
 
-	outputFp, err := os.Open(filepath.Join(workingDir, "output.mp4"))
+	if payload.Format == videoutil.FORMAT_HLS {
+		err = p.uploadHLS(ctx, inputs.workingDir, payload)
+		if err != nil {
+			p.deleteVideoAssets(ctx, payload)
+			return "", err
+		}
+		return output, nil
+	}
+	output, err = p.muxTracks(ctx, t, payload, inputs, output, words, soft)
+	if err != nil {
+		return "", err
+	}
+	outputFp, err := os.Open(filepath.Join(inputs.workingDir, output))
 	if err != nil {
 		log.Printf("Failed to open output Mp4 file: %v", err)
-		return err
+		return "", err
 	}
 	defer outputFp.Close()
-	defer os.Remove(outputFp.Name())
-
-	err = p.s3Client.UploadFile(BUCKET, fmt.Sprintf("assets/%s/%s.mp4", payload.EntryID, payload.BackgroundVideo), outputFp, "video/mp4")
+	err = p.s3Client.Upload(ctx, BUCKET, videoutil.VideoKey(payload.EntryID, payload.VideoID), outputFp, s3client.UploadOptions{
+		ContentType:        "video/mp4",
+		ContentDisposition: s3client.ContentDisposition("inline", videoutil.DownloadName(inputs.jobText.Title, 0)),
+	})
 	if err != nil {
 		log.Printf("Failed to upload video to S3: %v", err)
-		return err
+		return "", err
 	}
+	return output, nil
+}
 
-	log.Printf("Completed video for %s", payload.EntryID)
+// prepareBackground finds the background clip to render on and returns its local path.
+// Catalog clips come from the asset cache, a user's own uploads are downloaded into workingDir.
+func (p *GenerateVideoProcess) prepareBackground(ctx context.Context, payload VideoGenerationPayload, workingDir string) (videoutil.Background, string, error) {
+	if !videoutil.IsUserBackground(payload.BackgroundVideo) {
+		background, ok := p.assets.Catalog().Get(payload.BackgroundVideo)
+		if !ok {
+			log.Printf("Background %s is not in the catalog", payload.BackgroundVideo)
+			return videoutil.Background{}, "", fmt.Errorf("unknown background video: %w", asynq.SkipRetry)
+		}
+		backgroundVideo, err := p.assets.Path(ctx, background)
+		if err != nil {
+			return videoutil.Background{}, "", err
+		}
+		return background, backgroundVideo, nil
+	}
 
-	updated, err := p.dynamoClient.AddVideoToJob(payload.EntryID, payload.BackgroundVideo)
+	// Only the user who uploaded the clip may render on it
+	userBackground, err := p.dynamoClient.GetUserBackground(ctx, payload.RequestedBy, payload.BackgroundVideo)
 	if err != nil {
-		log.Printf("Failed to update job data: %v", err)
-		return err
+		return videoutil.Background{}, "", err
 	}
-
-	// Get the user's actual email from cognito given the username
-	email, err := p.cognitoClient.GetEmailFromUsername(payload.RequestedBy)
+	if userBackground == nil || userBackground.Status != dynamo.BackgroundStatusReady {
+		log.Printf("Background %s is not available to %s", payload.BackgroundVideo, payload.RequestedBy)
+		return videoutil.Background{}, "", fmt.Errorf("unknown background video: %w", asynq.SkipRetry)
+	}
+	background := videoutil.Background{
+		ID:          userBackground.BackgroundID,
+		DisplayName: userBackground.DisplayName,
+		S3Key:       userBackground.S3Key,
+		Duration:    userBackground.Duration,
+		AspectRatio: userBackground.AspectRatio,
+		Enabled:     true,
+	}
+	backgroundFp, err := os.Create(background.LocalPath(workingDir))
 	if err != nil {
-		log.Printf("Failed to get email from username: %v", err)
-		return fmt.Errorf("failed to get email from username: %w", asynq.SkipRetry)
+		log.Printf("Error creating background file: %v", err)
+		return videoutil.Background{}, "", err
 	}
-
-	err = p.sesClient.SendEmail(
-		email,
-		updated.Attributes["title"].(*types.AttributeValueMemberS).Value,
-		payload.EntryID,
-		payload.BackgroundVideo,
-	)
+	defer backgroundFp.Close()
+	_, err = p.s3Client.DownloadFile(ctx, BUCKET, background.S3Key, backgroundFp)
 	if err != nil {
-		log.Printf("Failed to send email: %v", err)
-		return fmt.Errorf("failed to send email: %w", asynq.SkipRetry)
+		log.Printf("Error downloading background from S3: %v", err)
+		return videoutil.Background{}, "", err
 	}
+	return background, backgroundFp.Name(), nil
+}
 
+// writeSubtitles writes the subtitles for the preset's frame size into subtitlesFp.
+// The default canvas uses Subtitle.ass as is, other canvases are laid out again from Timestamps.json.
+func (p *GenerateVideoProcess) writeSubtitles(ctx context.Context, entryID string, preset videoutil.Preset, subtitlesFp *os.File) error {
+	canvas := subtitleclient.Canvas{Width: preset.Width, Height: preset.Height}
+	if canvas != subtitleclient.DEFAULT_CANVAS {
+		words, err := p.readTimestamps(ctx, entryID)
+		if err == nil {
+			assContent := subtitleclient.GenerateASSContentForCanvas(subtitleclient.GenerateSubtitleLines(words), canvas)
+			_, err = subtitlesFp.WriteString(assContent)
+			if err != nil {
+				log.Printf("Error writing subtitle file: %v", err)
+				return err
+			}
+			return nil
+		}
+		// Jobs from before Timestamps.json existed only have the default layout, libass will stretch it
+		log.Printf("No timestamps for %s, falling back to default subtitles: %v", entryID, err)
+	}
+
+	subtitleBytes, err := p.s3Client.ReadFile(ctx, BUCKET, fmt.Sprintf("assets/%s/Subtitle.ass", entryID))
+	if err != nil {
+		log.Printf("Error reading subtitle file from S3: %v", err)
+		return err
+	}
+	defer subtitleBytes.Close()
+	_, err = io.Copy(subtitlesFp, subtitleBytes)
+	if err != nil {
+		log.Printf("Error putting bytes into subtitle file: %v", err)
+		return err
+	}
 	return nil
 }
+
+// readTimestamps reads the word timings of the narration, jobs from before Timestamps.json existed have none.
+func (p *GenerateVideoProcess) readTimestamps(ctx context.Context, entryID string) ([]subtitleclient.WordTimeStamp, error) {
+	timestampBytes, err := p.s3Client.ReadFile(ctx, BUCKET, fmt.Sprintf("assets/%s/Timestamps.json", entryID))
+	if err != nil {
+		return nil, err
+	}
+	defer timestampBytes.Close()
+	var words []subtitleclient.WordTimeStamp
+	err = json.NewDecoder(timestampBytes).Decode(&words)
+	if err != nil {
+		log.Printf("Error decoding timestamps: %v", err)
+		return nil, err
+	}
+	return words, nil
+}
@@ -0,0 +1,301 @@
+package videoutil
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	s3client "github.com/Kanishk-K/UniteDownloader/Backend/pkg/s3Client"
+)
+
+const (
+	// LOGO_KEY is the logo drawn over every video, it is cached with the backgrounds
+	LOGO_KEY = "background/logo.png"
+	// Downloads are written to a temporary file with this prefix and renamed once verified
+	CACHE_DOWNLOAD_PREFIX = ".download-"
+)
+
+// cacheEntry is a local copy that matched its S3 object when it was last checked.
+type cacheEntry struct {
+	etag string
+	size int64
+}
+
+// CacheStatus describes the asset cache for health checks.
+type CacheStatus struct {
+	Backgrounds int       `json:"backgrounds"`
+	Cached      int       `json:"cached"`
+	Missing     []string  `json:"missing,omitempty"`
+	LastSync    time.Time `json:"lastSync"`
+	LastError   string    `json:"lastError,omitempty"`
+}
+
+// AssetCache keeps the background catalog and local copies of its clips and the logo in dir.
+// Copies are checked against the object's ETag, re-downloaded when the object changes and removed once
+// the catalog no longer lists them. Sync refreshes everything, Path fetches a single clip when a task needs it.
+type AssetCache struct {
+	s3Client s3client.S3Methods
+	bucket   string
+	dir      string
+
+	mu       sync.Mutex
+	catalog  *Catalog
+	entries  map[string]cacheEntry
+	keyLocks map[string]*sync.Mutex
+	lastSync time.Time
+	lastErr  error
+}
+
+func NewAssetCache(s3Client s3client.S3Methods, bucket string, dir string, catalog *Catalog) *AssetCache {
+	return &AssetCache{
+		s3Client: s3Client,
+		bucket:   bucket,
+		dir:      dir,
+		catalog:  catalog,
+		entries:  make(map[string]cacheEntry),
+		keyLocks: make(map[string]*sync.Mutex),
+	}
+}
+
+// Catalog is the most recently loaded background catalog.
+func (c *AssetCache) Catalog() *Catalog {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.catalog
+}
+
+// localPath is where the copy of the object at key is kept.
+func (c *AssetCache) localPath(key string) string {
+	return filepath.Join(c.dir, filepath.Base(key))
+}
+
+// Path returns the local copy of a catalog background, downloading it first if it is missing or out of date.
+func (c *AssetCache) Path(ctx context.Context, background Background) (string, error) {
+	return c.ensure(ctx, background.S3Key)
+}
+
+// LogoPath returns the local copy of the logo.
+func (c *AssetCache) LogoPath(ctx context.Context) (string, error) {
+	return c.ensure(ctx, LOGO_KEY)
+}
+
+// lock serializes downloads of the same object.
+func (c *AssetCache) lock(key string) *sync.Mutex {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	lock, ok := c.keyLocks[key]
+	if !ok {
+		lock = &sync.Mutex{}
+		c.keyLocks[key] = lock
+	}
+	return lock
+}
+
+// ensure makes the local copy of key match the object in S3 and returns its path.
+func (c *AssetCache) ensure(ctx context.Context, key string) (string, error) {
+	lock := c.lock(key)
+	lock.Lock()
+	defer lock.Unlock()
+	path := c.localPath(key)
+
+	c.mu.Lock()
+	entry, verified := c.entries[key]
+	c.mu.Unlock()
+	localSize := int64(-1)
+	if stat, err := os.Stat(path); err == nil {
+		localSize = stat.Size()
+	}
+
+	// Step 1: Compare the copy with the object, a verified copy is still used if S3 can't be reached
+	info, err := c.s3Client.HeadFile(ctx, c.bucket, key)
+	if err != nil {
+		if verified && localSize == entry.size {
+			log.Printf("Could not check %s, using the cached copy: %v", key, err)
+			return path, nil
+		}
+		log.Printf("Could not check %s: %v", key, err)
+		return "", err
+	}
+	if verified && entry.etag == info.ETag && localSize == info.Size {
+		return path, nil
+	}
+	// A copy left by an earlier run of the container is kept if it still matches
+	if localSize == info.Size && verifyETag(path, info.ETag) == nil {
+		c.record(key, info)
+		return path, nil
+	}
+
+	// Step 2: Download into a temporary file and only replace the copy once it is complete and verified
+	log.Printf("Downloading %s into the asset cache", key)
+	tempFp, err := os.CreateTemp(c.dir, CACHE_DOWNLOAD_PREFIX+"*")
+	if err != nil {
+		log.Printf("Error creating cache download file: %v", err)
+		return "", err
+	}
+	defer os.Remove(tempFp.Name())
+	written, err := c.s3Client.DownloadFile(ctx, c.bucket, key, tempFp)
+	closeErr := tempFp.Close()
+	if err != nil {
+		log.Printf("Error downloading %s: %v", key, err)
+		return "", err
+	}
+	if closeErr != nil {
+		log.Printf("Error writing %s: %v", key, closeErr)
+		return "", closeErr
+	}
+	if written != info.Size {
+		return "", fmt.Errorf("downloaded %d of %d bytes of %s", written, info.Size, key)
+	}
+	if err := verifyETag(tempFp.Name(), info.ETag); err != nil {
+		log.Printf("Download of %s is corrupt: %v", key, err)
+		return "", err
+	}
+	// Encodes that already opened the old copy keep reading it
+	if err := os.Rename(tempFp.Name(), path); err != nil {
+		log.Printf("Error moving %s into the asset cache: %v", key, err)
+		return "", err
+	}
+	c.record(key, info)
+	return path, nil
+}
+
+func (c *AssetCache) record(key string, info s3client.ObjectInfo) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = cacheEntry{etag: info.ETag, size: info.Size}
+}
+
+// verifyETag checks a file against an ETag. Only single PUT ETags are the MD5 of the content,
+// multipart uploads are checked by size alone.
+func verifyETag(path string, etag string) error {
+	if strings.Contains(etag, "-") {
+		return nil
+	}
+	fp, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer fp.Close()
+	hash := md5.New()
+	if _, err := io.Copy(hash, fp); err != nil {
+		return err
+	}
+	if sum := hex.EncodeToString(hash.Sum(nil)); sum != etag {
+		return fmt.Errorf("checksum %s does not match ETag %s", sum, etag)
+	}
+	return nil
+}
+
+// Sync reloads the catalog, brings the logo and every enabled background up to date and removes copies of
+// clips the catalog no longer lists. Disabled backgrounds are only downloaded when a task asks for them.
+func (c *AssetCache) Sync(ctx context.Context) error {
+	err := c.sync(ctx)
+	c.mu.Lock()
+	c.lastSync, c.lastErr = time.Now(), err
+	c.mu.Unlock()
+	return err
+}
+
+func (c *AssetCache) sync(ctx context.Context) error {
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		log.Printf("Error creating asset cache directory: %v", err)
+		return err
+	}
+
+	// Step 1: Pick up backgrounds added since the last sync, keeping the old catalog if the new one can't be read
+	catalog, err := LoadCatalog(ctx, c.s3Client, c.bucket)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.catalog = catalog
+	c.mu.Unlock()
+
+	// Step 2: Download whatever is missing or changed, one failed clip doesn't stop the others
+	keys := []string{LOGO_KEY}
+	for _, background := range catalog.Enabled() {
+		keys = append(keys, background.S3Key)
+	}
+	var failed []string
+	for _, key := range keys {
+		if _, err := c.ensure(ctx, key); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			failed = append(failed, key)
+		}
+	}
+
+	// Step 3: Remove copies of clips that were taken out of the catalog
+	listed := map[string]bool{filepath.Base(LOGO_KEY): true}
+	for _, background := range catalog.Backgrounds {
+		listed[filepath.Base(background.S3Key)] = true
+	}
+	files, err := os.ReadDir(c.dir)
+	if err != nil {
+		log.Printf("Error listing asset cache: %v", err)
+		return err
+	}
+	for _, file := range files {
+		if file.IsDir() || listed[file.Name()] || strings.HasPrefix(file.Name(), CACHE_DOWNLOAD_PREFIX) {
+			continue
+		}
+		log.Printf("Removing %s from the asset cache", file.Name())
+		if err := os.Remove(filepath.Join(c.dir, file.Name())); err != nil {
+			log.Printf("Failed to remove %s from the asset cache: %v", file.Name(), err)
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to cache %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// Run syncs the cache every interval until ctx is done.
+func (c *AssetCache) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.Sync(ctx); err != nil {
+				log.Printf("Asset cache sync failed: %v", err)
+			}
+		}
+	}
+}
+
+// Status reports how many enabled backgrounds have a verified local copy and how the last sync went.
+func (c *AssetCache) Status() CacheStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	status := CacheStatus{LastSync: c.lastSync}
+	if c.lastErr != nil {
+		status.LastError = c.lastErr.Error()
+	}
+	for _, background := range c.catalog.Enabled() {
+		status.Backgrounds++
+		if _, ok := c.entries[background.S3Key]; ok {
+			status.Cached++
+		} else {
+			status.Missing = append(status.Missing, background.ID)
+		}
+	}
+	return status
+}
+
+// Ready reports whether the cache has synced once and holds every enabled background.
+func (s CacheStatus) Ready() bool {
+	return !s.LastSync.IsZero() && len(s.Missing) == 0
+}
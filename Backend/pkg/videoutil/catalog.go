@@ -0,0 +1,130 @@
+package videoutil
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"math/rand/v2"
+	"path/filepath"
+
+	s3client "github.com/Kanishk-K/UniteDownloader/Backend/pkg/s3Client"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// CATALOG_KEY is where the live background catalog is stored in the bucket.
+const CATALOG_KEY = "background/catalog.json"
+
+// defaultCatalog is the catalog shipped with the code, used until one is uploaded to CATALOG_KEY.
+//
+//go:embed backgrounds.json
+var defaultCatalog []byte
+
+// Background is a clip that narrated videos can be rendered on top of.
+type Background struct {
+	ID          string `json:"id"`
+	DisplayName string `json:"displayName"`
+	S3Key       string `json:"s3Key"`
+	// Duration of the clip in seconds
+	Duration    float64 `json:"duration"`
+	AspectRatio string  `json:"aspectRatio"`
+	License     string  `json:"license"`
+	Enabled     bool    `json:"enabled"`
+}
+
+type Catalog struct {
+	Backgrounds []Background `json:"backgrounds"`
+}
+
+// ParseCatalog decodes and validates a catalog manifest.
+func ParseCatalog(r io.Reader) (*Catalog, error) {
+	var catalog Catalog
+	if err := json.NewDecoder(r).Decode(&catalog); err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	for _, background := range catalog.Backgrounds {
+		if background.ID == "" || background.S3Key == "" {
+			return nil, fmt.Errorf("background is missing an ID or S3 key")
+		}
+		if seen[background.ID] {
+			return nil, fmt.Errorf("background %s is listed twice", background.ID)
+		}
+		if background.Duration <= 0 {
+			return nil, fmt.Errorf("background %s has no duration", background.ID)
+		}
+		seen[background.ID] = true
+	}
+	return &catalog, nil
+}
+
+// DefaultCatalog returns the catalog embedded in the binary.
+func DefaultCatalog() (*Catalog, error) {
+	return ParseCatalog(bytes.NewReader(defaultCatalog))
+}
+
+// LoadCatalog reads the catalog from S3, falling back to the embedded one if none has been uploaded.
+func LoadCatalog(ctx context.Context, s3Client s3client.S3Methods, bucket string) (*Catalog, error) {
+	catalogReader, err := s3Client.ReadFile(ctx, bucket, CATALOG_KEY)
+	if err != nil {
+		var noSuchKey *types.NoSuchKey
+		if errors.As(err, &noSuchKey) {
+			log.Printf("No catalog at %s, using the default catalog", CATALOG_KEY)
+			return DefaultCatalog()
+		}
+		log.Printf("Failed to read background catalog: %v", err)
+		return nil, err
+	}
+	defer catalogReader.Close()
+	return ParseCatalog(catalogReader)
+}
+
+// Get returns the background with the given ID whether or not it is enabled.
+func (c *Catalog) Get(id string) (Background, bool) {
+	for _, background := range c.Backgrounds {
+		if background.ID == id {
+			return background, true
+		}
+	}
+	return Background{}, false
+}
+
+// IsSelectable reports whether users may currently request the background.
+func (c *Catalog) IsSelectable(id string) bool {
+	background, ok := c.Get(id)
+	return ok && background.Enabled
+}
+
+// Enabled lists the backgrounds users may currently request.
+func (c *Catalog) Enabled() []Background {
+	enabled := []Background{}
+	for _, background := range c.Backgrounds {
+		if background.Enabled {
+			enabled = append(enabled, background)
+		}
+	}
+	return enabled
+}
+
+// LocalPath is where the consumer keeps its copy of the clip.
+func (b Background) LocalPath(dir string) string {
+	return filepath.Join(dir, filepath.Base(b.S3Key))
+}
+
+// RandomOffset picks a start time (in seconds) anywhere within the clip.
+func (b Background) RandomOffset() float64 {
+	return rand.Float64() * b.Duration
+}
+
+// LoopOffset is where playback is after seconds more of a clip that started at offset, wrapping at its end.
+func (b Background) LoopOffset(offset float64, seconds float64) float64 {
+	if b.Duration <= 0 {
+		return 0
+	}
+	return math.Mod(offset+seconds, b.Duration)
+}
@@ -0,0 +1,174 @@
+package videoutil
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/Kanishk-K/UniteDownloader/Backend/pkg/ffmpeg"
+)
+
+const (
+	FORMAT_MP4 = "mp4"
+	FORMAT_HLS = "hls"
+)
+
+// FORMATS lists the output formats a user may request, the default is FORMAT_MP4.
+var FORMATS = []string{FORMAT_MP4, FORMAT_HLS}
+
+const (
+	// HLS_DIR is where ffmpeg writes the stream inside the working directory and the folder it is uploaded to
+	HLS_DIR             = "hls"
+	HLS_MASTER_PLAYLIST = "master.m3u8"
+	HLS_SEGMENT_SECONDS = 6
+	// Renditions are capped at this many bits per pixel of a 30fps frame
+	HLS_BITS_PER_PIXEL = 0.1
+	HLS_FRAME_RATE     = 30
+	// Renditions whose short side would be smaller than this are left out of the ladder
+	HLS_MIN_SHORT_SIDE = 240
+)
+
+// HLS_LADDER scales the preset's frame down into the renditions of the stream, largest first.
+var HLS_LADDER = []struct {
+	Name  string
+	Scale float64
+}{
+	{"high", 1},
+	{"medium", 2.0 / 3},
+	{"low", 0.5},
+}
+
+// Rendition is one bitrate of an HLS stream.
+type Rendition struct {
+	Name   string
+	Width  int
+	Height int
+	// MaxRate is the bitrate cap in kbit/s
+	MaxRate int
+}
+
+// Playlist is the rendition's media playlist relative to the master playlist.
+func (r Rendition) Playlist() string {
+	return path.Join(r.Name, "playlist.m3u8")
+}
+
+// HLSPrefix is the S3 prefix every file of a video's HLS stream is stored under.
+func HLSPrefix(entryID string, videoID string) string {
+	return VideoPrefix(entryID, videoID) + HLS_DIR + "/"
+}
+
+// HLSMasterKey is the S3 key of the playlist players should open.
+func HLSMasterKey(entryID string, videoID string) string {
+	return HLSPrefix(entryID, videoID) + HLS_MASTER_PLAYLIST
+}
+
+// HLSContentType returns the content type of a file written by the HLS muxer.
+func HLSContentType(name string) string {
+	switch path.Ext(name) {
+	case ".m3u8":
+		return "application/vnd.apple.mpegurl"
+	case ".ts":
+		return "video/mp2t"
+	default:
+		return "application/octet-stream"
+	}
+}
+
+// GetFormat validates an output format, an empty format selects FORMAT_MP4.
+func GetFormat(format string) (string, error) {
+	if format == "" {
+		return FORMAT_MP4, nil
+	}
+	for _, supported := range FORMATS {
+		if format == supported {
+			return format, nil
+		}
+	}
+	return "", fmt.Errorf("unknown output format %s", format)
+}
+
+// even rounds down to the nearest even number, libx264 needs even frame sizes.
+func even(value float64) int {
+	return int(value) &^ 1
+}
+
+// HLSRenditions scales the preset's frame into the bitrate ladder.
+func (p Preset) HLSRenditions() []Rendition {
+	var renditions []Rendition
+	for _, step := range HLS_LADDER {
+		width := even(float64(p.Width) * step.Scale)
+		height := even(float64(p.Height) * step.Scale)
+		// Always keep the full size rendition, even for presets that are already small
+		if len(renditions) > 0 && min(width, height) < HLS_MIN_SHORT_SIDE {
+			break
+		}
+		renditions = append(renditions, Rendition{
+			Name:    step.Name,
+			Width:   width,
+			Height:  height,
+			MaxRate: int(float64(width*height*HLS_FRAME_RATE) * HLS_BITS_PER_PIXEL / 1000),
+		})
+	}
+	return renditions
+}
+
+// AddHLSOutput splits the layout output into the preset's renditions and writes them, each with a copy of the
+// audio stream, as an HLS stream into HLS_DIR. The graph must already contain the layout.
+func AddHLSOutput(command *ffmpeg.Command, graph *ffmpeg.Graph, preset Preset, audio string) []Rendition {
+	renditions := preset.HLSRenditions()
+	splitPads := make([]string, len(renditions))
+	for i := range renditions {
+		splitPads[i] = fmt.Sprintf("hls_%d", i)
+	}
+	graph.Add(
+		ffmpeg.Pads(LAYOUT_OUTPUT),
+		ffmpeg.Filters(ffmpeg.NewFilter("split", strconv.Itoa(len(renditions)))),
+		splitPads,
+	)
+
+	output := command.Output(path.Join(HLS_DIR, "%v", "playlist.m3u8"))
+	streamMap := make([]string, len(renditions))
+	for i, rendition := range renditions {
+		scaledPad := splitPads[i] + "_scaled"
+		graph.Add(
+			ffmpeg.Pads(splitPads[i]),
+			ffmpeg.Filters(ffmpeg.NewFilter("scale", strconv.Itoa(rendition.Width), strconv.Itoa(rendition.Height))),
+			ffmpeg.Pads(scaledPad),
+		)
+		output.Map(scaledPad)
+		streamMap[i] = fmt.Sprintf("v:%d,a:%d,name:%s", i, i, rendition.Name)
+	}
+	for range renditions {
+		output.MapStream(audio)
+	}
+
+	output.With("-c:v", "libx264", "-preset", preset.EncoderPreset, "-crf", strconv.Itoa(preset.CRF))
+	for i, rendition := range renditions {
+		output.With(
+			fmt.Sprintf("-maxrate:v:%d", i), fmt.Sprintf("%dk", rendition.MaxRate),
+			fmt.Sprintf("-bufsize:v:%d", i), fmt.Sprintf("%dk", rendition.MaxRate*2),
+		)
+	}
+	// Every segment has to start on a keyframe in every rendition so players can switch between them
+	output.With(
+		"-force_key_frames", fmt.Sprintf("expr:gte(t,n_forced*%d)", HLS_SEGMENT_SECONDS),
+		"-sc_threshold", "0",
+	)
+	if preset.AudioBitrate != "" {
+		output.With("-c:a", "aac", "-b:a", preset.AudioBitrate)
+	} else {
+		output.With("-c:a", "copy")
+	}
+	output.With(
+		"-f", "hls",
+		"-hls_time", strconv.Itoa(HLS_SEGMENT_SECONDS),
+		"-hls_playlist_type", "vod",
+		"-hls_flags", "independent_segments",
+		"-hls_segment_filename", path.Join(HLS_DIR, "%v", "segment_%03d.ts"),
+		"-master_pl_name", HLS_MASTER_PLAYLIST,
+		"-var_stream_map", strings.Join(streamMap, " "),
+		"-shortest",
+	)
+	return renditions
+}
@@ -0,0 +1,69 @@
+package videoutil
+
+import (
+	"github.com/Kanishk-K/UniteDownloader/Backend/pkg/ffmpeg"
+)
+
+const (
+	// LAYOUT_OUTPUT is the pad the finished video frames are written to
+	LAYOUT_OUTPUT = "output"
+	// The logo is drawn at this fraction of the frame height, this far from the top right corner
+	LOGO_HEIGHT_FRACTION = "12"
+	LOGO_MARGIN          = "10"
+)
+
+// NarratedLayout draws the subtitles over the background and places a translucent logo in the top right corner.
+// background and logo are the input pads of the two clips, subtitles is the path of the ASS file (empty when
+// the subtitles are muxed as a caption track instead) and
+// overlays (if not empty) the path of an ASS file drawn over everything else, see GenerateOverlayASS.
+//
+// Scaling the logo relative to the frame needs a reference input: ffmpeg 7.1 added reference inputs to scale,
+// older releases (the consumer image ships 6.1) only have scale2ref which 7.1 deprecates.
+func NarratedLayout(preset Preset, background string, logo string, subtitles string, overlays string, version ffmpeg.Version) *ffmpeg.Graph {
+	graph := ffmpeg.NewGraph()
+	filters := preset.ScaleFilter()
+	if subtitles != "" {
+		filters = append(filters, ffmpeg.NewFilter("ass", subtitles))
+	}
+	graph.Add(
+		ffmpeg.Pads(background),
+		filters,
+		ffmpeg.Pads("subs"),
+	)
+	graph.Add(
+		ffmpeg.Pads(logo),
+		ffmpeg.Filters(
+			ffmpeg.NewFilter("format", "rgba"),
+			ffmpeg.NewFilter("colorchannelmixer").Set("aa", "0.3"),
+		),
+		ffmpeg.Pads("logo"),
+	)
+	if version.AtLeast(7, 1) {
+		graph.Add(
+			ffmpeg.Pads("subs"),
+			ffmpeg.Filters(ffmpeg.NewFilter("split", "2")),
+			ffmpeg.Pads("frame", "frame_ref"),
+		)
+		graph.Add(
+			ffmpeg.Pads("logo", "frame_ref"),
+			ffmpeg.Filters(ffmpeg.NewFilter("scale").Set("w", "oh*dar").Set("h", "rh/"+LOGO_HEIGHT_FRACTION)),
+			ffmpeg.Pads("logo_scaled"),
+		)
+	} else {
+		graph.Add(
+			ffmpeg.Pads("logo", "subs"),
+			ffmpeg.Filters(ffmpeg.NewFilter("scale2ref").Set("w", "oh*mdar").Set("h", "ih/"+LOGO_HEIGHT_FRACTION)),
+			ffmpeg.Pads("logo_scaled", "frame"),
+		)
+	}
+	filters = ffmpeg.Filters(ffmpeg.NewFilter("overlay").Set("x", "W-w-"+LOGO_MARGIN).Set("y", LOGO_MARGIN))
+	if overlays != "" {
+		filters = append(filters, ffmpeg.NewFilter("ass", overlays))
+	}
+	graph.Add(
+		ffmpeg.Pads("frame", "logo_scaled"),
+		filters,
+		ffmpeg.Pads(LAYOUT_OUTPUT),
+	)
+	return graph
+}
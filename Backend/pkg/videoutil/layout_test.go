@@ -0,0 +1,76 @@
+package videoutil
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Kanishk-K/UniteDownloader/Backend/pkg/ffmpeg"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// checkGolden compares got with testdata/name, rewriting the file instead when -update is set.
+func checkGolden(t *testing.T, name string, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != string(want) {
+		t.Errorf("%s does not match the golden file\ngot:\n%s\nwant:\n%s", name, got, want)
+	}
+}
+
+// layoutGolden writes one chain per line so a change shows up as a diff of the chain it touches.
+func layoutGolden(graph *ffmpeg.Graph) string {
+	var builder strings.Builder
+	for _, chain := range graph.Chains() {
+		builder.WriteString(chain.String() + "\n")
+	}
+	return builder.String()
+}
+
+func TestNarratedLayout(t *testing.T) {
+	preset := PRESETS[DEFAULT_PRESET]
+	cases := []struct {
+		golden    string
+		version   ffmpeg.Version
+		subtitles string
+		overlays  string
+	}{
+		// The consumer image ships 6.1, which only has scale2ref
+		{"narrated_layout_6.1.golden", ffmpeg.Version{Major: 6, Minor: 1}, "Subtitle.ass", ""},
+		// 7.1 scales the logo with the frame as a reference input
+		{"narrated_layout_7.1.golden", ffmpeg.Version{Major: 7, Minor: 1}, "Subtitle.ass", ""},
+		{"narrated_layout_git.golden", ffmpeg.LATEST, "Subtitle.ass", ""},
+		// Subtitles muxed as a caption track aren't burnt in, overlays go over the logo
+		{"narrated_layout_captions_overlays.golden", ffmpeg.Version{Major: 6, Minor: 1}, "", "Overlays.ass"},
+	}
+	for _, c := range cases {
+		t.Run(c.golden, func(t *testing.T) {
+			graph := NarratedLayout(preset, "0", "1", c.subtitles, c.overlays, c.version)
+			checkGolden(t, c.golden, layoutGolden(graph))
+		})
+	}
+}
+
+func TestNarratedLayoutScaleFilter(t *testing.T) {
+	older := NarratedLayout(PRESETS[DEFAULT_PRESET], "0", "1", "Subtitle.ass", "", ffmpeg.Version{Major: 7, Minor: 0}).String()
+	newer := NarratedLayout(PRESETS[DEFAULT_PRESET], "0", "1", "Subtitle.ass", "", ffmpeg.Version{Major: 7, Minor: 1}).String()
+	if !strings.Contains(older, "scale2ref") {
+		t.Errorf("7.0 layout does not use scale2ref: %s", older)
+	}
+	if strings.Contains(newer, "scale2ref") || !strings.Contains(newer, "[logo][frame_ref]scale=") {
+		t.Errorf("7.1 layout does not scale with a reference input: %s", newer)
+	}
+}
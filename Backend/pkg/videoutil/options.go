@@ -0,0 +1,62 @@
+package videoutil
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	dynamo "github.com/Kanishk-K/UniteDownloader/Backend/pkg/dynamoClient"
+)
+
+const (
+	OVERLAY_INTRO    = "intro"
+	OVERLAY_OUTRO    = "outro"
+	OVERLAY_PROGRESS = "progress"
+)
+
+// OVERLAYS lists the optional overlays drawn over the narration, in the order they are named in video IDs.
+var OVERLAYS = []string{OVERLAY_INTRO, OVERLAY_OUTRO, OVERLAY_PROGRESS}
+
+// VideoID identifies a rendered video within a job, it names both the VideoRequests row and the S3 object.
+// Videos made with the default options keep the IDs they had before those options existed, the background's ID.
+func VideoID(o dynamo.VideoOptions) string {
+	videoID := o.BackgroundVideo
+	if o.Preset != "" && o.Preset != DEFAULT_PRESET {
+		videoID += "_" + o.Preset
+	}
+	if o.Format == FORMAT_HLS {
+		videoID += "_" + FORMAT_HLS
+	}
+	if o.PartLength > 0 {
+		videoID += fmt.Sprintf("_parts%d", o.PartLength)
+	}
+	if len(o.Overlays) > 0 {
+		videoID += "_" + strings.Join(o.Overlays, "-")
+	}
+	if o.Subtitles == SUBTITLES_SOFT {
+		videoID += "_" + SUBTITLES_SOFT
+	}
+	return videoID
+}
+
+// HasOverlay reports whether the overlay was requested.
+func HasOverlay(o dynamo.VideoOptions, overlay string) bool {
+	return slices.Contains(o.Overlays, overlay)
+}
+
+// NormalizeOverlays validates the requested overlays and returns them without duplicates in OVERLAYS order,
+// so the same choice always gives the same video ID.
+func NormalizeOverlays(overlays []string) ([]string, error) {
+	for _, overlay := range overlays {
+		if !slices.Contains(OVERLAYS, overlay) {
+			return nil, fmt.Errorf("unknown overlay %s", overlay)
+		}
+	}
+	var normalized []string
+	for _, overlay := range OVERLAYS {
+		if slices.Contains(overlays, overlay) {
+			normalized = append(normalized, overlay)
+		}
+	}
+	return normalized, nil
+}
@@ -0,0 +1,38 @@
+package videoutil
+
+import (
+	"testing"
+
+	dynamo "github.com/Kanishk-K/UniteDownloader/Backend/pkg/dynamoClient"
+)
+
+func TestVideoID(t *testing.T) {
+	cases := []struct {
+		options dynamo.VideoOptions
+		want    string
+	}{
+		// Videos made before any options existed must keep their IDs
+		{dynamo.VideoOptions{BackgroundVideo: "subway_surfers"}, "subway_surfers"},
+		{dynamo.VideoOptions{BackgroundVideo: "subway_surfers", Preset: DEFAULT_PRESET}, "subway_surfers"},
+		{dynamo.VideoOptions{BackgroundVideo: "subway_surfers", Format: FORMAT_MP4, Subtitles: SUBTITLES_BURNED}, "subway_surfers"},
+		{dynamo.VideoOptions{BackgroundVideo: "subway_surfers", Preset: "vertical"}, "subway_surfers_vertical"},
+		{dynamo.VideoOptions{BackgroundVideo: "minecraft", Format: FORMAT_HLS}, "minecraft_hls"},
+		{dynamo.VideoOptions{BackgroundVideo: "minecraft", PartLength: 60}, "minecraft_parts60"},
+		{
+			dynamo.VideoOptions{
+				BackgroundVideo: "minecraft",
+				Preset:          "square",
+				Format:          FORMAT_HLS,
+				PartLength:      90,
+				Overlays:        []string{OVERLAY_INTRO, OVERLAY_PROGRESS},
+				Subtitles:       SUBTITLES_SOFT,
+			},
+			"minecraft_square_hls_parts90_intro-progress_" + SUBTITLES_SOFT,
+		},
+	}
+	for _, c := range cases {
+		if got := VideoID(c.options); got != c.want {
+			t.Errorf("VideoID(%+v) = %q, want %q", c.options, got, c.want)
+		}
+	}
+}
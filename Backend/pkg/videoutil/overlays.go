@@ -0,0 +1,104 @@
+package videoutil
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+const (
+	// Cards are shown for this many seconds at the start and end of the video
+	INTRO_DURATION = 4.0
+	OUTRO_DURATION = 4.0
+	CARD_FADE_MS   = 400
+	// CARD_ALPHA is how transparent the card's backdrop is, from 00 (opaque) to FF
+	CARD_ALPHA = "&H30&"
+	// PROGRESS_BAR_HEIGHT is a fraction of the frame height
+	PROGRESS_BAR_HEIGHT = 0.008
+	PROGRESS_BAR_COLOR  = "&H639fc5&"
+	ZIRCON_URL          = "zircon.socialcoding.net"
+)
+
+// OverlayText is the job metadata written on the cards.
+type OverlayText struct {
+	Title  string
+	Course string
+}
+
+// Overlays are the cards and progress bar to draw over a video of the given length.
+type Overlays struct {
+	Intro    bool
+	Outro    bool
+	Progress bool
+	Text     OverlayText
+	// Duration is the length of the video in seconds, the outro and progress bar need it
+	Duration float64
+}
+
+// Empty reports whether there is nothing to draw.
+func (o Overlays) Empty() bool {
+	return !o.Intro && !o.Outro && !o.Progress
+}
+
+// assTime formats seconds as an ASS timestamp, H:MM:SS.CC.
+func assTime(seconds float64) string {
+	centiseconds := int(math.Round(math.Max(seconds, 0) * 100))
+	return fmt.Sprintf("%d:%02d:%02d.%02d", centiseconds/360000, centiseconds/6000%60, centiseconds/100%60, centiseconds%100)
+}
+
+// assText keeps user supplied text from being read as override tags or line breaks.
+func assText(text string) string {
+	return strings.NewReplacer("{", "(", "}", ")", "\\", "/", "\n", " ").Replace(text)
+}
+
+// GenerateOverlayASS lays the overlays out for the preset's frame. The file is drawn after the subtitles
+// and the logo, so the cards cover both.
+func GenerateOverlayASS(preset Preset, overlays Overlays) string {
+	width, height := preset.Width, preset.Height
+	// Sizes are designed for the 1024px tall original frame, like the subtitles
+	scale := func(size float64) int {
+		return int(math.Round(size * math.Min(float64(width)/576, float64(height)/1024)))
+	}
+	margin := width / 12
+
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "[Script Info]\nPlayResX: %d\nPlayResY: %d\nWrapStyle: 0\n\n", width, height)
+	builder.WriteString("[V4+ Styles]\nFormat: Name, Fontname, Fontsize, PrimaryColour, SecondaryColour, OutlineColour, BackColour, Bold, Italic, Underline, StrikeOut, ScaleX, ScaleY, Spacing, Angle, BorderStyle, Outline, Shadow, Alignment, MarginL, MarginR, MarginV, Encoding\n")
+	fmt.Fprintf(&builder, "Style: Title,Berlin Sans FB,%d,&H00FFFFFF,&H000000FF,&H00000000,&H00000000,-1,0,0,0,100,100,0,0,1,%d,0,5,%d,%d,10,1\n", scale(56), scale(3), margin, margin)
+	fmt.Fprintf(&builder, "Style: Caption,Berlin Sans FB,%d,&H00D0D0D0,&H000000FF,&H00000000,&H00000000,0,0,0,0,100,100,0,0,1,%d,0,5,%d,%d,10,1\n", scale(34), scale(2), margin, margin)
+	builder.WriteString("\n[Events]\nFormat: Layer, Start, End, Style, Text\n")
+
+	card := func(start float64, end float64, fade string, title string, caption string) {
+		// The backdrop is a translucent rectangle drawn over the whole frame
+		fmt.Fprintf(&builder, "Dialogue: 0,%s,%s,Caption,{\\an7\\pos(0,0)\\p1\\bord0\\shad0\\1c&H000000&\\1a%s%s}m 0 0 l %d 0 %d %d 0 %d{\\p0}\n", assTime(start), assTime(end), CARD_ALPHA, fade, width, width, height, height)
+		fmt.Fprintf(&builder, "Dialogue: 1,%s,%s,Title,{\\an5\\pos(%d,%d)%s}%s\n", assTime(start), assTime(end), width/2, height*9/20, fade, assText(title))
+		if caption != "" {
+			fmt.Fprintf(&builder, "Dialogue: 1,%s,%s,Caption,{\\an8\\pos(%d,%d)%s}%s\n", assTime(start), assTime(end), width/2, height*11/20, fade, assText(caption))
+		}
+	}
+
+	// Step 1: The intro fades out into the video
+	if overlays.Intro {
+		card(0, INTRO_DURATION, fmt.Sprintf("\\fad(0,%d)", CARD_FADE_MS), overlays.Text.Title, overlays.Text.Course)
+	}
+	// Step 2: The outro fades in over the end of the narration
+	if overlays.Outro && overlays.Duration > 0 {
+		card(math.Max(overlays.Duration-OUTRO_DURATION, 0), overlays.Duration, fmt.Sprintf("\\fad(%d,0)", CARD_FADE_MS), "Made with Zircon", ZIRCON_URL)
+	}
+	// Step 3: The progress bar grows from the left by widening its clip over the whole video
+	if overlays.Progress && overlays.Duration > 0 {
+		top := height - int(math.Max(math.Round(float64(height)*PROGRESS_BAR_HEIGHT), 2))
+		fmt.Fprintf(
+			&builder,
+			"Dialogue: 2,%s,%s,Caption,{\\an7\\pos(0,0)\\p1\\bord0\\shad0\\1c%s\\clip(0,%d,0,%d)\\t(0,%d,\\clip(0,%d,%d,%d))}m 0 %d l %d %d %d %d 0 %d{\\p0}\n",
+			assTime(0),
+			assTime(overlays.Duration),
+			PROGRESS_BAR_COLOR,
+			top, height,
+			int(overlays.Duration*1000),
+			top, width, height,
+			top, width, top, width, height, height,
+		)
+	}
+	return builder.String()
+}
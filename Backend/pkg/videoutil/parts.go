@@ -0,0 +1,57 @@
+package videoutil
+
+import (
+	"fmt"
+
+	"github.com/Kanishk-K/UniteDownloader/Backend/pkg/ffmpeg"
+)
+
+const (
+	// Part lengths are in seconds, 0 renders the narration as a single video
+	MIN_PART_LENGTH = 30
+	MAX_PART_LENGTH = 180
+	// PART_AUDIO_BITRATE is used when the preset copies the narration, a trimmed track has to be re-encoded
+	PART_AUDIO_BITRATE = "128k"
+	// PART_AUDIO is the pad the trimmed narration of a part is written to
+	PART_AUDIO = "part_audio"
+)
+
+// VideoPrefix is the S3 prefix of videos stored as more than one file, i.e. HLS streams and parts.
+func VideoPrefix(entryID string, videoID string) string {
+	return fmt.Sprintf("assets/%s/%s/", entryID, videoID)
+}
+
+// PartKey is the S3 key of the nth (1-based) part of a video.
+func PartKey(entryID string, videoID string, part int) string {
+	return fmt.Sprintf("%sparts/part_%02d.mp4", VideoPrefix(entryID, videoID), part)
+}
+
+// PartKeys lists the S3 keys of a video split into count parts, in order.
+func PartKeys(entryID string, videoID string, count int) []string {
+	keys := make([]string, count)
+	for i := range keys {
+		keys[i] = PartKey(entryID, videoID, i+1)
+	}
+	return keys
+}
+
+// AddPartAudio trims the narration to [start, end) seconds and writes it to PART_AUDIO, an end of 0 reads to the end.
+func AddPartAudio(graph *ffmpeg.Graph, audio string, start float64, end float64) {
+	trim := ffmpeg.NewFilter("atrim").Set("start", fmt.Sprintf("%.3f", start))
+	if end > 0 {
+		trim = trim.Set("end", fmt.Sprintf("%.3f", end))
+	}
+	graph.Add(
+		ffmpeg.Pads(audio),
+		ffmpeg.Filters(trim, ffmpeg.NewFilter("asetpts", "PTS-STARTPTS")),
+		ffmpeg.Pads(PART_AUDIO),
+	)
+}
+
+// PartEncoderArgs is EncoderArgs for a part, whose audio is always re-encoded.
+func (p Preset) PartEncoderArgs() []string {
+	if p.AudioBitrate == "" {
+		p.AudioBitrate = PART_AUDIO_BITRATE
+	}
+	return p.EncoderArgs()
+}
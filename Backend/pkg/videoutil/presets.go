@@ -0,0 +1,113 @@
+package videoutil
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/Kanishk-K/UniteDownloader/Backend/pkg/ffmpeg"
+)
+
+// Preset describes the resolution and encoder settings of a rendered video.
+type Preset struct {
+	ID            string `json:"id"`
+	DisplayName   string `json:"displayName"`
+	Width         int    `json:"width"`
+	Height        int    `json:"height"`
+	CRF           int    `json:"crf"`
+	EncoderPreset string `json:"encoderPreset"`
+	// MaxRate caps the bitrate for platforms with upload limits, empty means uncapped
+	MaxRate string `json:"maxRate,omitempty"`
+	// AudioBitrate re-encodes the narration, empty means the AAC track is copied as is
+	AudioBitrate string `json:"audioBitrate,omitempty"`
+}
+
+const DEFAULT_PRESET = "original"
+
+// PRESETS lists every output format a user may request, keyed by ID.
+var PRESETS = map[string]Preset{
+	// The format every video was rendered in before presets existed
+	"original": {
+		ID:            "original",
+		DisplayName:   "Original",
+		Width:         576,
+		Height:        1024,
+		CRF:           30,
+		EncoderPreset: "medium",
+	},
+	"vertical": {
+		ID:            "vertical",
+		DisplayName:   "Vertical 1080p",
+		Width:         1080,
+		Height:        1920,
+		CRF:           23,
+		EncoderPreset: "medium",
+		MaxRate:       "6M",
+	},
+	"landscape": {
+		ID:            "landscape",
+		DisplayName:   "Landscape 720p",
+		Width:         1280,
+		Height:        720,
+		CRF:           23,
+		EncoderPreset: "medium",
+		MaxRate:       "4M",
+	},
+	"square": {
+		ID:            "square",
+		DisplayName:   "Square",
+		Width:         1080,
+		Height:        1080,
+		CRF:           23,
+		EncoderPreset: "medium",
+		MaxRate:       "5M",
+	},
+	"share": {
+		ID:            "share",
+		DisplayName:   "Small Share",
+		Width:         360,
+		Height:        640,
+		CRF:           32,
+		EncoderPreset: "veryfast",
+		MaxRate:       "800k",
+		AudioBitrate:  "64k",
+	},
+}
+
+// GetPreset returns the preset with the given ID, an empty ID selects the default preset.
+func GetPreset(id string) (Preset, error) {
+	if id == "" {
+		id = DEFAULT_PRESET
+	}
+	preset, ok := PRESETS[id]
+	if !ok {
+		return Preset{}, fmt.Errorf("unknown output preset %s", id)
+	}
+	return preset, nil
+}
+
+// ScaleFilter fills the preset's frame with the background, cropping whatever overflows.
+func (p Preset) ScaleFilter() []ffmpeg.Filter {
+	return ffmpeg.Filters(
+		ffmpeg.NewFilter("scale", strconv.Itoa(p.Width), strconv.Itoa(p.Height)).Set("force_original_aspect_ratio", "increase"),
+		ffmpeg.NewFilter("crop", strconv.Itoa(p.Width), strconv.Itoa(p.Height)),
+		ffmpeg.NewFilter("setsar", "1"),
+	)
+}
+
+// EncoderArgs returns the ffmpeg output options for the video and audio streams.
+func (p Preset) EncoderArgs() []string {
+	args := []string{
+		"-c:v", "libx264",
+		"-preset", p.EncoderPreset,
+		"-crf", strconv.Itoa(p.CRF),
+	}
+	if p.MaxRate != "" {
+		args = append(args, "-maxrate", p.MaxRate, "-bufsize", p.MaxRate)
+	}
+	if p.AudioBitrate != "" {
+		args = append(args, "-c:a", "aac", "-b:a", p.AudioBitrate)
+	} else {
+		args = append(args, "-c:a", "copy")
+	}
+	return args
+}
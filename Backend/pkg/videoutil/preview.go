@@ -0,0 +1,108 @@
+package videoutil
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+
+	"github.com/Kanishk-K/UniteDownloader/Backend/pkg/ffmpeg"
+)
+
+const (
+	POSTER_FILE  = "poster.jpg"
+	PREVIEW_FILE = "preview.webp"
+	// Previews fit inside a square of these many pixels so portrait and landscape videos stay small
+	POSTER_SIZE  = 640
+	PREVIEW_SIZE = 320
+	// The animated preview is this many seconds long at this frame rate, looping forever
+	PREVIEW_DURATION = 3.0
+	PREVIEW_FPS      = 10
+	// Frames are taken this far into the video, the first seconds are often still fading in
+	PREVIEW_OFFSET_FRACTION = 0.1
+	PREVIEW_MAX_OFFSET      = 30.0
+)
+
+// VideoKey is the S3 key of a generated video.
+func VideoKey(entryID string, videoID string) string {
+	return fmt.Sprintf("assets/%s/%s.mp4", entryID, videoID)
+}
+
+// DownloadName is the file name a video, or part of one when part is not 0, is saved as from the browser.
+func DownloadName(title string, part int) string {
+	name := strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || unicode.IsControl(r) {
+			return '-'
+		}
+		return r
+	}, strings.TrimSpace(title))
+	if name == "" {
+		name = "Lecture"
+	}
+	if part > 0 {
+		name += fmt.Sprintf(" - Part %d", part)
+	}
+	return name + ".mp4"
+}
+
+// PosterKey is the S3 key of a video's JPEG poster frame.
+func PosterKey(entryID string, videoID string) string {
+	return fmt.Sprintf("assets/%s/%s.jpg", entryID, videoID)
+}
+
+// PreviewKey is the S3 key of a video's looping WebP preview.
+func PreviewKey(entryID string, videoID string) string {
+	return fmt.Sprintf("assets/%s/%s.webp", entryID, videoID)
+}
+
+// VideoAssetKeys lists every object stored for a video, they expire together.
+func VideoAssetKeys(entryID string, videoID string) []string {
+	return []string{
+		VideoKey(entryID, videoID),
+		PosterKey(entryID, videoID),
+		PreviewKey(entryID, videoID),
+	}
+}
+
+// PreviewOffset picks where in a video of the given length the poster and preview are taken from.
+func PreviewOffset(duration float64) float64 {
+	offset := math.Min(duration*PREVIEW_OFFSET_FRACTION, PREVIEW_MAX_OFFSET)
+	// Leave room for the whole preview in short videos
+	if offset+PREVIEW_DURATION > duration {
+		offset = math.Max(duration-PREVIEW_DURATION, 0)
+	}
+	return offset
+}
+
+// fitFilter scales a frame down to fit inside a size x size square, keeping its aspect ratio.
+func fitFilter(size int) ffmpeg.Filter {
+	return ffmpeg.NewFilter("scale").
+		Set("w", fmt.Sprintf("min(%d,iw)", size)).
+		Set("h", fmt.Sprintf("min(%d,ih)", size)).
+		Set("force_original_aspect_ratio", "decrease")
+}
+
+// PosterCommand writes a single JPEG frame of video, taken offset seconds in, to POSTER_FILE.
+func PosterCommand(video string, offset float64) *ffmpeg.Command {
+	command := ffmpeg.New()
+	command.Input(video).Seek(offset)
+	command.Output(POSTER_FILE).
+		With("-vf", fitFilter(POSTER_SIZE).String()).
+		With("-frames:v", "1", "-q:v", "3")
+	return command
+}
+
+// PreviewCommand writes a short silent looping WebP of video, starting offset seconds in, to PREVIEW_FILE.
+func PreviewCommand(video string, offset float64) *ffmpeg.Command {
+	command := ffmpeg.New()
+	command.Input(video).Seek(offset)
+	filters := ffmpeg.Chain{Filters: ffmpeg.Filters(
+		ffmpeg.NewFilter("fps", fmt.Sprint(PREVIEW_FPS)),
+		fitFilter(PREVIEW_SIZE),
+	)}
+	command.Output(PREVIEW_FILE).
+		With("-vf", filters.String()).
+		With("-t", fmt.Sprintf("%.3f", PREVIEW_DURATION), "-an").
+		With("-c:v", "libwebp", "-loop", "0", "-quality", "60", "-compression_level", "4")
+	return command
+}
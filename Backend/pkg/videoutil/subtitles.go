@@ -0,0 +1,60 @@
+package videoutil
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/Kanishk-K/UniteDownloader/Backend/pkg/ffmpeg"
+)
+
+const (
+	// SUBTITLES_BURNED draws the karaoke subtitles into the frames, SUBTITLES_SOFT muxes them as a caption
+	// track players can turn off
+	SUBTITLES_BURNED = "burned"
+	SUBTITLES_SOFT   = "soft"
+)
+
+// SUBTITLE_MODES lists the ways a user may have subtitles rendered, the default is SUBTITLES_BURNED.
+var SUBTITLE_MODES = []string{SUBTITLES_BURNED, SUBTITLES_SOFT}
+
+const (
+	// Files muxed into single MP4 videos after the encode, in the working directory
+	CAPTIONS_FILE = "captions.srt"
+	CHAPTERS_FILE = "chapters.txt"
+	MUXED_FILE    = "muxed.mp4"
+	// CAPTIONS_LANGUAGE is the ISO 639-2 code the caption track is tagged with
+	CAPTIONS_LANGUAGE = "eng"
+)
+
+// GetSubtitles validates a subtitle mode, an empty mode selects SUBTITLES_BURNED.
+func GetSubtitles(mode string) (string, error) {
+	if mode == "" {
+		return SUBTITLES_BURNED, nil
+	}
+	for _, supported := range SUBTITLE_MODES {
+		if mode == supported {
+			return mode, nil
+		}
+	}
+	return "", fmt.Errorf("unknown subtitle mode %s", mode)
+}
+
+// MuxCommand copies the streams of an encoded MP4 into output, adding the captions (SRT) as a mov_text track
+// and the chapters (ffmpeg metadata) when they are not empty. Nothing is encoded again.
+func MuxCommand(video string, captions string, chapters string, output string) *ffmpeg.Command {
+	command := ffmpeg.New()
+	command.Input(video)
+	out := command.Output(output).MapStream("0:v").MapStream("0:a")
+	out.With("-c:v", "copy", "-c:a", "copy")
+	if captions != "" {
+		command.Input(captions)
+		out.MapStream(strconv.Itoa(len(command.Inputs)-1)+":s").
+			With("-c:s", "mov_text", "-metadata:s:s:0", "language="+CAPTIONS_LANGUAGE)
+	}
+	if chapters != "" {
+		command.Input(chapters).Format("ffmetadata")
+		out.With("-map_chapters", strconv.Itoa(len(command.Inputs)-1))
+	}
+	out.With("-movflags", "+faststart")
+	return command
+}
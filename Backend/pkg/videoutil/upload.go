@@ -0,0 +1,170 @@
+package videoutil
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/Kanishk-K/UniteDownloader/Backend/pkg/ffmpeg"
+)
+
+const (
+	// USER_BACKGROUND_PREFIX marks background IDs that belong to a single user rather than the catalog
+	USER_BACKGROUND_PREFIX = "custom_"
+	// Limits on what users may upload, checked again by the consumer before transcoding
+	MAX_UPLOAD_SIZE     = 500 * 1024 * 1024
+	MIN_UPLOAD_DURATION = 10.0
+	MAX_UPLOAD_DURATION = 30 * 60.0
+	MIN_UPLOAD_SIDE     = 360
+	MAX_UPLOAD_SIDE     = 4096
+	// Uploads are normalized to this size on their longest side
+	MEZZANINE_LONG_SIDE = 1920
+)
+
+// UPLOAD_CONTENT_TYPES are the container formats the extension may upload.
+var UPLOAD_CONTENT_TYPES = []string{"video/mp4", "video/quicktime", "video/webm"}
+
+// UPLOAD_CODECS are the video codecs ffmpeg in the consumer image can decode reliably.
+var UPLOAD_CODECS = []string{"h264", "hevc", "vp8", "vp9", "av1", "mpeg4", "prores"}
+
+// NewUserBackgroundID returns a fresh ID for a user upload.
+func NewUserBackgroundID() (string, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return USER_BACKGROUND_PREFIX + hex.EncodeToString(id), nil
+}
+
+// IsUserBackground reports whether the ID refers to a user upload instead of a catalog entry.
+func IsUserBackground(id string) bool {
+	return strings.HasPrefix(id, USER_BACKGROUND_PREFIX)
+}
+
+// UserUploadKey is where the extension uploads the original clip.
+func UserUploadKey(userID string, backgroundID string) string {
+	return fmt.Sprintf("uploads/%s/%s", userID, backgroundID)
+}
+
+// ParseUserUploadKey splits an upload key back into its user and background IDs.
+func ParseUserUploadKey(key string) (string, string, error) {
+	parts := strings.Split(key, "/")
+	if len(parts) != 3 || parts[0] != "uploads" || parts[1] == "" || !IsUserBackground(parts[2]) {
+		return "", "", fmt.Errorf("not a user upload key %s", key)
+	}
+	return parts[1], parts[2], nil
+}
+
+// UserBackgroundKey is where the normalized clip is kept once it has been accepted.
+func UserBackgroundKey(userID string, backgroundID string) string {
+	return fmt.Sprintf("user-backgrounds/%s/%s.mp4", userID, backgroundID)
+}
+
+// VideoProbe is what ffprobe reports about an uploaded clip.
+type VideoProbe struct {
+	Codec    string
+	Width    int
+	Height   int
+	Duration float64
+}
+
+// ProbeVideo reads the first video stream and container duration of the file with ffprobe.
+func ProbeVideo(ctx context.Context, path string) (*VideoProbe, error) {
+	out, err := exec.CommandContext(
+		ctx,
+		"ffprobe",
+		"-v", "error",
+		"-select_streams", "v:0",
+		"-show_entries", "stream=codec_name,width,height:format=duration",
+		"-of", "json",
+		path,
+	).Output()
+	if err != nil {
+		log.Println("Failed to probe video")
+		return nil, err
+	}
+	var probeOutput struct {
+		Streams []struct {
+			CodecName string `json:"codec_name"`
+			Width     int    `json:"width"`
+			Height    int    `json:"height"`
+		} `json:"streams"`
+		Format struct {
+			Duration string `json:"duration"`
+		} `json:"format"`
+	}
+	if err := json.Unmarshal(out, &probeOutput); err != nil {
+		log.Println("Failed to parse ffprobe output")
+		return nil, err
+	}
+	if len(probeOutput.Streams) == 0 {
+		return nil, fmt.Errorf("file has no video stream")
+	}
+	duration, err := strconv.ParseFloat(probeOutput.Format.Duration, 64)
+	if err != nil {
+		return nil, fmt.Errorf("file has no duration")
+	}
+	return &VideoProbe{
+		Codec:    probeOutput.Streams[0].CodecName,
+		Width:    probeOutput.Streams[0].Width,
+		Height:   probeOutput.Streams[0].Height,
+		Duration: duration,
+	}, nil
+}
+
+// ValidateUpload checks a probed upload against the upload limits.
+// The returned error is shown to the user so it should explain what to fix.
+func ValidateUpload(probe *VideoProbe) error {
+	if !slices.Contains(UPLOAD_CODECS, probe.Codec) {
+		return fmt.Errorf("video codec %s is not supported", probe.Codec)
+	}
+	if probe.Duration < MIN_UPLOAD_DURATION || probe.Duration > MAX_UPLOAD_DURATION {
+		return fmt.Errorf("video must be between %.0f seconds and %.0f minutes long", MIN_UPLOAD_DURATION, MAX_UPLOAD_DURATION/60)
+	}
+	if min(probe.Width, probe.Height) < MIN_UPLOAD_SIDE || max(probe.Width, probe.Height) > MAX_UPLOAD_SIDE {
+		return fmt.Errorf("video resolution must be between %dp and %dp", MIN_UPLOAD_SIDE, MAX_UPLOAD_SIDE)
+	}
+	return nil
+}
+
+// MezzanineArgs are the ffmpeg output arguments that normalize an upload so it renders like the catalog clips.
+// The clip keeps its aspect ratio since every output preset scales and crops the background anyway,
+// the audio is dropped and keyframes are kept frequent so random seeks stay cheap.
+func MezzanineArgs() []string {
+	return []string{
+		"-vf", ffmpeg.Chain{Filters: ffmpeg.Filters(
+			ffmpeg.NewFilter("scale").
+				Set("w", fmt.Sprintf("min(%d,iw)", MEZZANINE_LONG_SIDE)).
+				Set("h", fmt.Sprintf("min(%d,ih)", MEZZANINE_LONG_SIDE)).
+				Set("force_original_aspect_ratio", "decrease").
+				Set("force_divisible_by", "2"),
+			ffmpeg.NewFilter("fps", "30"),
+			ffmpeg.NewFilter("format", "yuv420p"),
+		)}.String(),
+		"-c:v", "libx264",
+		"-preset", "medium",
+		"-crf", "20",
+		"-g", "60",
+		"-an",
+		"-movflags", "+faststart",
+	}
+}
+
+// AspectRatio reduces the frame size to a ratio such as "9:16".
+func AspectRatio(width int, height int) string {
+	a, b := width, height
+	for b != 0 {
+		a, b = b, a%b
+	}
+	if a == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d:%d", width/a, height/a)
+}
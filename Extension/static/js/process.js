@@ -77,7 +77,20 @@ chrome.runtime.onMessage.addListener((msg, sender) => {
           for (const videoID of exists.videosAvailable) {
             const videoContainer = generateVideoAvailable(
               payload.entryID,
-              videoID
+              videoID,
+              exists.videoStreams?.[videoID]
+            );
+            existing_container.appendChild(videoContainer);
+          }
+        }
+        if (exists.videoParts) {
+          for (const [videoID, partKeys] of Object.entries(
+            exists.videoParts
+          )) {
+            const videoContainer = generateVideoPartsAvailable(
+              payload.entryID,
+              videoID,
+              partKeys
             );
             existing_container.appendChild(videoContainer);
           }
@@ -168,8 +181,11 @@ document.addEventListener("DOMContentLoaded", () => {
   }, 1000);
 });
 
-function generateVideoAvailable(entryID, videoID) {
-  const hrefLink = `${SERVERHOST}/assets/${entryID}/${videoID}.mp4`;
+// streamKey is the master playlist of an HLS video, which has no MP4 to download
+function generateVideoAvailable(entryID, videoID, streamKey) {
+  const hrefLink = streamKey
+    ? `${SERVERHOST}/${streamKey}`
+    : `${SERVERHOST}/assets/${entryID}/${videoID}.mp4`;
   const videoContainer = document.createElement("div");
   videoContainer.classList.add("content-available");
   videoContainer.innerHTML = `<svg
@@ -184,12 +200,35 @@ function generateVideoAvailable(entryID, videoID) {
   const videoLink = document.createElement("a");
   videoLink.href = hrefLink;
   videoLink.classList.add("brand-color");
-  videoLink.setAttribute("download", `${entryID}_${videoID}.mp4`);
-  videoLink.textContent = `Download ${toTitleCase(videoID)} Video`;
+  if (streamKey) {
+    videoLink.target = "_blank";
+    videoLink.textContent = `Stream ${toTitleCase(videoID)} Video`;
+  } else {
+    videoLink.setAttribute("download", `${entryID}_${videoID}.mp4`);
+    videoLink.textContent = `Download ${toTitleCase(videoID)} Video`;
+  }
   videoContainer.appendChild(videoLink);
   return videoContainer;
 }
 
+// A video split into parts has no file of its own, each part is linked instead
+function generateVideoPartsAvailable(entryID, videoID, partKeys) {
+  const videoContainer = generateVideoAvailable(entryID, videoID);
+  const videoLink = videoContainer.querySelector("a");
+  partKeys.forEach((partKey, i) => {
+    const partLink = videoLink.cloneNode();
+    partLink.href = `${SERVERHOST}/${partKey}`;
+    partLink.setAttribute(
+      "download",
+      `${entryID}_${videoID}_part_${i + 1}.mp4`
+    );
+    partLink.textContent = `Download ${toTitleCase(videoID)} Part ${i + 1}`;
+    videoContainer.appendChild(partLink);
+  });
+  videoLink.remove();
+  return videoContainer;
+}
+
 function toTitleCase(str) {
   return str
     .toLowerCase()
@@ -2,6 +2,8 @@
 # -> Jobs Table
 # -> Video Request Table
 # -> Users Table
+# -> Rate Limits Table
+# -> User Backgrounds Table
 
 # CREATES a DynamoDB table to store metadata on jobs
 resource "aws_dynamodb_table" "jobs-table" {
@@ -25,39 +27,6 @@ resource "aws_dynamodb_table" "jobs-table" {
   }
 }
 
-resource "aws_lambda_event_source_mapping" "invoke-tts" {
-  event_source_arn       = aws_dynamodb_table.jobs-table.stream_arn
-  function_name          = aws_lambda_function.subtitle-lambda.arn
-  starting_position      = "LATEST"
-  batch_size             = 1
-  enabled                = true
-  maximum_retry_attempts = 0
-  filter_criteria {
-    filter {
-      pattern = jsonencode({
-        eventName = ["MODIFY"]
-        dynamodb = {
-          OldImage = {
-            subtitlesGenerated = {
-              BOOL = [false]
-            }
-          }
-          NewImage = {
-            subtitlesGenerated = {
-              BOOL = [true]
-            }
-          }
-        }
-      })
-    }
-  }
-}
-
-resource "aws_lambda_function_event_invoke_config" "tts-noretry" {
-  function_name          = aws_lambda_function.subtitle-lambda.function_name
-  maximum_retry_attempts = 0
-}
-
 # CREATES a DynamoDB table to store metadata on video requests
 resource "aws_dynamodb_table" "video_requests_table" {
   name             = "VideoRequests"
@@ -80,6 +49,23 @@ resource "aws_dynamodb_table" "video_requests_table" {
     name = "requestedVideo"
     type = "S"
   }
+  attribute {
+    name = "requestedBy"
+    type = "S"
+  }
+  attribute {
+    name = "requestedOn"
+    type = "S"
+  }
+  # Counts a user's recent requests when choosing their queue priority
+  global_secondary_index {
+    name            = "requestedBy-index"
+    hash_key        = "requestedBy"
+    range_key       = "requestedOn"
+    projection_type = "KEYS_ONLY"
+    read_capacity   = 5
+    write_capacity  = 5
+  }
   tags = {
     Name        = "zircon-video-requests-table"
     Environment = "prod"
@@ -90,18 +76,47 @@ resource "aws_dynamodb_table" "video_requests_table" {
 }
 
 resource "aws_lambda_event_source_mapping" "invoke-videogen" {
-  event_source_arn       = aws_dynamodb_table.video_requests_table.stream_arn
-  function_name          = aws_lambda_function.queue-lambda.arn
-  starting_position      = "LATEST"
-  batch_size             = 1
-  enabled                = true
-  maximum_retry_attempts = 0
+  event_source_arn        = aws_dynamodb_table.video_requests_table.stream_arn
+  function_name           = aws_lambda_function.queue-lambda.arn
+  starting_position       = "LATEST"
+  batch_size              = 10
+  enabled                 = true
+  function_response_types = ["ReportBatchItemFailures"]
+
+  # Failed records are retried on their own, ones that keep failing are kept in the failure queue
+  maximum_retry_attempts         = 3
+  bisect_batch_on_function_error = true
+  destination_config {
+    on_failure {
+      destination_arn = aws_sqs_queue.stream-failures.arn
+    }
+  }
   filter_criteria {
     filter {
       pattern = jsonencode({
         eventName = ["INSERT"]
       })
     }
+    # A request that failed for good and was replaced by a new one
+    filter {
+      pattern = jsonencode({
+        eventName = ["MODIFY"]
+        dynamodb = {
+          OldImage = {
+            failure = {
+              M = {
+                retryable = {
+                  BOOL = [false]
+                }
+              }
+            }
+          }
+          NewImage = {
+            failure = [{ exists = false }]
+          }
+        }
+      })
+    }
   }
 }
 
@@ -111,16 +126,30 @@ resource "aws_lambda_function_event_invoke_config" "videogen-noretry" {
 }
 
 resource "aws_lambda_event_source_mapping" "invoke-ttl" {
-  event_source_arn       = aws_dynamodb_table.video_requests_table.stream_arn
-  function_name          = aws_lambda_function.ttl_video.arn
-  starting_position      = "LATEST"
-  batch_size             = 1
-  enabled                = true
-  maximum_retry_attempts = 0
+  event_source_arn        = aws_dynamodb_table.video_requests_table.stream_arn
+  function_name           = aws_lambda_function.ttl_video.arn
+  starting_position       = "LATEST"
+  batch_size              = 10
+  enabled                 = true
+  function_response_types = ["ReportBatchItemFailures"]
+
+  # Failed records are retried on their own, ones that keep failing are kept in the failure queue
+  maximum_retry_attempts         = 3
+  bisect_batch_on_function_error = true
+  destination_config {
+    on_failure {
+      destination_arn = aws_sqs_queue.stream-failures.arn
+    }
+  }
   filter_criteria {
     filter {
+      # Only rows expired by the TTL, cancelled and replaced requests are deleted by the API
       pattern = jsonencode({
         eventName = ["REMOVE"]
+        userIdentity = {
+          type        = ["Service"]
+          principalId = ["dynamodb.amazonaws.com"]
+        }
       })
     }
   }
@@ -150,3 +179,54 @@ resource "aws_dynamodb_table" "users-table" {
     prevent_destroy = true
   }
 }
+
+# CREATES a DynamoDB table to store the recent events each user's rate limits are counted from, one per slot
+resource "aws_dynamodb_table" "rate_limits_table" {
+  name           = "RateLimits"
+  billing_mode   = "PROVISIONED"
+  read_capacity  = 5
+  write_capacity = 5
+  hash_key       = "limitKey"
+  range_key      = "slot"
+  ttl {
+    attribute_name = "expiry"
+    enabled        = true
+  }
+  attribute {
+    name = "limitKey"
+    type = "S"
+  }
+  attribute {
+    name = "slot"
+    type = "N"
+  }
+  tags = {
+    Name        = "zircon-rate-limits-table"
+    Environment = "prod"
+  }
+}
+
+# CREATES a DynamoDB table to store backgrounds uploaded by users
+resource "aws_dynamodb_table" "user_backgrounds_table" {
+  name           = "UserBackgrounds"
+  billing_mode   = "PROVISIONED"
+  read_capacity  = 5
+  write_capacity = 5
+  hash_key       = "userID"
+  range_key      = "backgroundID"
+  attribute {
+    name = "userID"
+    type = "S"
+  }
+  attribute {
+    name = "backgroundID"
+    type = "S"
+  }
+  tags = {
+    Name        = "zircon-user-backgrounds-table"
+    Environment = "prod"
+  }
+  lifecycle {
+    prevent_destroy = true
+  }
+}
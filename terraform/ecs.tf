@@ -98,6 +98,15 @@ resource "aws_ecs_task_definition" "ecs-consumer-task-definition" {
     name      = "lecture-analyzer-consumer-container"
     image     = "${aws_ecrpublic_repository.consumer-images.repository_uri}:latest"
     essential = true
+    # The consumer drains in-flight encodes on SIGTERM, give it as long as ECS allows
+    stopTimeout = 120
+    healthCheck = {
+      command     = ["CMD-SHELL", "wget -q -O /dev/null http://localhost:8080/healthz || exit 1"]
+      interval    = 30
+      timeout     = 5
+      retries     = 3
+      startPeriod = 300
+    }
     logConfiguration = {
       logDriver = "awslogs"
       options = {
@@ -118,7 +127,35 @@ resource "aws_ecs_task_definition" "ecs-consumer-task-definition" {
       {
         name  = "COGNITO_POOL"
         value = aws_cognito_user_pool.zircon_user_pool.id
-      }
+      },
+      {
+        name  = "LEMONFOX_API_KEY"
+        value = var.LEMONFOX_API_KEY
+      },
+      {
+        name  = "CONSUMER_CONCURRENCY"
+        value = "1"
+      },
+      {
+        name  = "CONSUMER_QUEUES"
+        value = "high:3,medium:2,low:1"
+      },
+      {
+        name  = "CONSUMER_STRICT_PRIORITY"
+        value = "true"
+      },
+      {
+        name  = "CONSUMER_SHUTDOWN_TIMEOUT"
+        value = "100s"
+      },
+      {
+        name  = "CONSUMER_CACHE_SYNC_INTERVAL"
+        value = "10m"
+      },
+      {
+        name  = "CONSUMER_RECONCILE_SCHEDULE"
+        value = "@every 1h"
+      },
     ]
   }])
 }
@@ -10,7 +10,7 @@ resource "aws_apigatewayv2_api" "zircon-api" {
   name          = "zircon-api"
   protocol_type = "HTTP"
   cors_configuration {
-    allow_methods = ["GET", "POST"]
+    allow_methods = ["GET", "POST", "DELETE"]
     allow_origins = ["*"]
     allow_headers = [
       "Authorization"
@@ -109,3 +109,78 @@ resource "aws_lambda_permission" "health-integration-perm" {
   principal     = "apigateway.amazonaws.com"
   source_arn    = "${aws_apigatewayv2_api.zircon-api.execution_arn}/*"
 }
+
+# Backgrounds Route
+resource "aws_apigatewayv2_route" "backgrounds-route" {
+  api_id             = aws_apigatewayv2_api.zircon-api.id
+  route_key          = "GET /backgrounds"
+  authorization_type = "JWT"
+  authorizer_id      = aws_apigatewayv2_authorizer.cognito_authorizer.id
+  target             = "integrations/${aws_apigatewayv2_integration.backgrounds-integration.id}"
+}
+
+resource "aws_apigatewayv2_integration" "backgrounds-integration" {
+  api_id             = aws_apigatewayv2_api.zircon-api.id
+  integration_type   = "AWS_PROXY"
+  connection_type    = "INTERNET"
+  integration_method = "POST"
+  integration_uri    = aws_lambda_function.backgrounds_lambda.invoke_arn
+}
+
+resource "aws_lambda_permission" "backgrounds-integration-perm" {
+  statement_id  = "AllowAPIGatewayInvoke"
+  action        = "lambda:InvokeFunction"
+  function_name = aws_lambda_function.backgrounds_lambda.function_name
+  principal     = "apigateway.amazonaws.com"
+  source_arn    = "${aws_apigatewayv2_api.zircon-api.execution_arn}/*"
+}
+
+# Upload Route
+resource "aws_apigatewayv2_route" "upload-route" {
+  api_id             = aws_apigatewayv2_api.zircon-api.id
+  route_key          = "POST /backgrounds/upload"
+  authorization_type = "JWT"
+  authorizer_id      = aws_apigatewayv2_authorizer.cognito_authorizer.id
+  target             = "integrations/${aws_apigatewayv2_integration.upload-integration.id}"
+}
+
+resource "aws_apigatewayv2_integration" "upload-integration" {
+  api_id             = aws_apigatewayv2_api.zircon-api.id
+  integration_type   = "AWS_PROXY"
+  connection_type    = "INTERNET"
+  integration_method = "POST"
+  integration_uri    = aws_lambda_function.upload_lambda.invoke_arn
+}
+
+resource "aws_lambda_permission" "upload-integration-perm" {
+  statement_id  = "AllowAPIGatewayInvoke"
+  action        = "lambda:InvokeFunction"
+  function_name = aws_lambda_function.upload_lambda.function_name
+  principal     = "apigateway.amazonaws.com"
+  source_arn    = "${aws_apigatewayv2_api.zircon-api.execution_arn}/*"
+}
+
+# Cancel Video Route
+resource "aws_apigatewayv2_route" "cancel-video-route" {
+  api_id             = aws_apigatewayv2_api.zircon-api.id
+  route_key          = "DELETE /jobs/{entryID}/videos/{video}"
+  authorization_type = "JWT"
+  authorizer_id      = aws_apigatewayv2_authorizer.cognito_authorizer.id
+  target             = "integrations/${aws_apigatewayv2_integration.cancel-video-integration.id}"
+}
+
+resource "aws_apigatewayv2_integration" "cancel-video-integration" {
+  api_id             = aws_apigatewayv2_api.zircon-api.id
+  integration_type   = "AWS_PROXY"
+  connection_type    = "INTERNET"
+  integration_method = "POST"
+  integration_uri    = aws_lambda_function.cancel_lambda.invoke_arn
+}
+
+resource "aws_lambda_permission" "cancel-video-integration-perm" {
+  statement_id  = "AllowAPIGatewayInvoke"
+  action        = "lambda:InvokeFunction"
+  function_name = aws_lambda_function.cancel_lambda.function_name
+  principal     = "apigateway.amazonaws.com"
+  source_arn    = "${aws_apigatewayv2_api.zircon-api.execution_arn}/*"
+}
@@ -47,15 +47,46 @@ data "aws_iam_policy_document" "ecs-consumer-task-s3" {
     actions = ["s3:GetObject"]
     resources = [
       "${aws_s3_bucket.s3_bucket.arn}/assets/*/Audio.aac",
+      "${aws_s3_bucket.s3_bucket.arn}/assets/*/Notes.md",
+      "${aws_s3_bucket.s3_bucket.arn}/assets/*/Summary.txt",
+      "${aws_s3_bucket.s3_bucket.arn}/assets/*/Dialogue.json",
       "${aws_s3_bucket.s3_bucket.arn}/assets/*/Subtitle.ass",
-      "${aws_s3_bucket.s3_bucket.arn}/background/*"
+      "${aws_s3_bucket.s3_bucket.arn}/assets/*/Timestamps.json",
+      "${aws_s3_bucket.s3_bucket.arn}/background/*",
+      "${aws_s3_bucket.s3_bucket.arn}/music/*",
+      "${aws_s3_bucket.s3_bucket.arn}/uploads/*",
+      "${aws_s3_bucket.s3_bucket.arn}/user-backgrounds/*"
     ]
   }
+  # Large outputs are uploaded in parts, a failed upload is aborted
   statement {
     effect  = "Allow"
-    actions = ["s3:PutObject"]
+    actions = ["s3:PutObject", "s3:AbortMultipartUpload"]
     resources = [
-      "${aws_s3_bucket.s3_bucket.arn}/assets/*/*.mp4"
+      "${aws_s3_bucket.s3_bucket.arn}/assets/*/Audio.aac",
+      "${aws_s3_bucket.s3_bucket.arn}/assets/*/Subtitle.ass",
+      "${aws_s3_bucket.s3_bucket.arn}/assets/*/TTSResponse.json",
+      "${aws_s3_bucket.s3_bucket.arn}/assets/*/Timestamps.json",
+      "${aws_s3_bucket.s3_bucket.arn}/assets/*/*.mp4",
+      "${aws_s3_bucket.s3_bucket.arn}/assets/*/*.jpg",
+      "${aws_s3_bucket.s3_bucket.arn}/assets/*/*.webp",
+      "${aws_s3_bucket.s3_bucket.arn}/assets/*/hls/*",
+      "${aws_s3_bucket.s3_bucket.arn}/assets/*/parts/*",
+      "${aws_s3_bucket.s3_bucket.arn}/user-backgrounds/*",
+      "${aws_s3_bucket.s3_bucket.arn}/reports/reconcile/*"
+    ]
+  }
+  statement {
+    effect  = "Allow"
+    actions = ["s3:DeleteObject"]
+    resources = [
+      "${aws_s3_bucket.s3_bucket.arn}/assets/*/*.mp4",
+      "${aws_s3_bucket.s3_bucket.arn}/assets/*/*.jpg",
+      "${aws_s3_bucket.s3_bucket.arn}/assets/*/*.webp",
+      "${aws_s3_bucket.s3_bucket.arn}/assets/*/hls/*",
+      "${aws_s3_bucket.s3_bucket.arn}/assets/*/parts/*",
+      "${aws_s3_bucket.s3_bucket.arn}/uploads/*",
+      "${aws_s3_bucket.s3_bucket.arn}/user-backgrounds/*"
     ]
   }
   statement {
@@ -86,6 +117,24 @@ data "aws_iam_policy_document" "ecs-consumer-task-dynamo" {
     actions = ["dynamodb:UpdateItem"]
     resources = [
       aws_dynamodb_table.jobs-table.arn,
+      aws_dynamodb_table.video_requests_table.arn,
+      aws_dynamodb_table.user_backgrounds_table.arn,
+    ]
+  }
+  statement {
+    actions = ["dynamodb:GetItem"]
+    resources = [
+      aws_dynamodb_table.jobs-table.arn,
+      aws_dynamodb_table.video_requests_table.arn,
+      aws_dynamodb_table.user_backgrounds_table.arn,
+    ]
+  }
+  # Reconciliation reads every job and video request
+  statement {
+    actions = ["dynamodb:Scan"]
+    resources = [
+      aws_dynamodb_table.jobs-table.arn,
+      aws_dynamodb_table.video_requests_table.arn,
     ]
   }
 }
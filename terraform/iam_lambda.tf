@@ -30,7 +30,7 @@ resource "aws_iam_policy" "innerVPC-policy" {
 
 resource "aws_iam_policy_attachment" "innerVPC-lambda-policy" {
   name       = "innerVPC-lambda-policy"
-  roles      = [aws_iam_role.queue-lambda.name, aws_iam_role.health_lambda.name]
+  roles      = [aws_iam_role.queue-lambda.name, aws_iam_role.health_lambda.name, aws_iam_role.ingest_lambda_role.name, aws_iam_role.cancel_lambda_role.name]
   policy_arn = aws_iam_policy.innerVPC-policy.arn
 }
 
@@ -39,11 +39,33 @@ resource "aws_iam_policy_attachment" "submit-cloudwatch" {
   name = "submit-cloudwatch"
   roles = [
     aws_iam_role.submit-job-role.name,
-    aws_iam_role.tts-role.name,
     aws_iam_role.queue-lambda.name,
     aws_iam_role.exists_lambda_role.name,
     aws_iam_role.health_lambda.name,
     aws_iam_role.ttl-role.name,
+    aws_iam_role.backgrounds_lambda_role.name,
+    aws_iam_role.upload_lambda_role.name,
+    aws_iam_role.ingest_lambda_role.name,
+    aws_iam_role.cancel_lambda_role.name,
   ]
   policy_arn = "arn:aws:iam::aws:policy/service-role/AWSLambdaBasicExecutionRole"
 }
+
+data "aws_iam_policy_document" "stream-failures-description" {
+  statement {
+    actions   = ["sqs:SendMessage"]
+    resources = [aws_sqs_queue.stream-failures.arn]
+  }
+}
+
+resource "aws_iam_policy" "stream-failures-policy" {
+  name        = "stream-failures-policy"
+  description = "Allows stream lambdas to send the records they failed to process to the failure queue"
+  policy      = data.aws_iam_policy_document.stream-failures-description.json
+}
+
+resource "aws_iam_policy_attachment" "stream-failures-lambda-policy" {
+  name       = "stream-failures-lambda-policy"
+  roles      = [aws_iam_role.queue-lambda.name, aws_iam_role.ttl-role.name]
+  policy_arn = aws_iam_policy.stream-failures-policy.arn
+}
@@ -0,0 +1,36 @@
+resource "aws_iam_role" "backgrounds_lambda_role" {
+  name               = "backgrounds-lambda-role"
+  assume_role_policy = data.aws_iam_policy_document.lambda-trust-policy.json
+}
+
+data "aws_iam_policy_document" "backgrounds_lambda_description" {
+  statement {
+    actions = ["s3:GetObject"]
+    resources = [
+      "${aws_s3_bucket.s3_bucket.arn}/background/catalog.json",
+    ]
+  }
+  statement {
+    actions = ["dynamodb:Query"]
+    resources = [
+      aws_dynamodb_table.user_backgrounds_table.arn,
+    ]
+  }
+  statement {
+    actions = ["s3:ListBucket"]
+    resources = [
+      aws_s3_bucket.s3_bucket.arn,
+    ]
+  }
+}
+
+resource "aws_iam_policy" "backgrounds_lambda" {
+  name        = "backgrounds-lambda"
+  description = "Allows the backgrounds lambda to read the background catalog and the user's uploads"
+  policy      = data.aws_iam_policy_document.backgrounds_lambda_description.json
+}
+
+resource "aws_iam_role_policy_attachment" "backgrounds_policy_attachment" {
+  role       = aws_iam_role.backgrounds_lambda_role.name
+  policy_arn = aws_iam_policy.backgrounds_lambda.arn
+}
@@ -0,0 +1,38 @@
+resource "aws_iam_role" "cancel_lambda_role" {
+  name               = "cancel-lambda-role"
+  assume_role_policy = data.aws_iam_policy_document.lambda-trust-policy.json
+}
+
+data "aws_iam_policy_document" "cancel_lambda_description" {
+  statement {
+    actions = ["dynamodb:GetItem"]
+    resources = [
+      aws_dynamodb_table.jobs-table.arn,
+      aws_dynamodb_table.video_requests_table.arn,
+    ]
+  }
+  statement {
+    actions = ["dynamodb:DeleteItem"]
+    resources = [
+      aws_dynamodb_table.video_requests_table.arn,
+    ]
+  }
+  statement {
+    actions = ["dynamodb:UpdateItem"]
+    resources = [
+      aws_dynamodb_table.jobs-table.arn,
+      aws_dynamodb_table.users-table.arn,
+    ]
+  }
+}
+
+resource "aws_iam_policy" "cancel_lambda" {
+  name        = "cancel-lambda"
+  description = "Allows the cancel lambda to remove a user's video request and refund their video quota"
+  policy      = data.aws_iam_policy_document.cancel_lambda_description.json
+}
+
+resource "aws_iam_role_policy_attachment" "cancel_policy_attachment" {
+  role       = aws_iam_role.cancel_lambda_role.name
+  policy_arn = aws_iam_policy.cancel_lambda.arn
+}
@@ -10,11 +10,17 @@ data "aws_iam_policy_document" "exists_lambda_description" {
       aws_dynamodb_table.jobs-table.arn,
     ]
   }
+  statement {
+    actions = ["dynamodb:Query"]
+    resources = [
+      aws_dynamodb_table.video_requests_table.arn,
+    ]
+  }
 }
 
 resource "aws_iam_policy" "exists_lambda" {
   name        = "exists-lambda"
-  description = "Allows the exists lambda to access the jobs dynamodb table"
+  description = "Allows the exists lambda to access the jobs and video requests dynamodb tables"
   policy      = data.aws_iam_policy_document.exists_lambda_description.json
 }
 
@@ -0,0 +1,4 @@
+resource "aws_iam_role" "ingest_lambda_role" {
+  name               = "ingest-lambda-role"
+  assume_role_policy = data.aws_iam_policy_document.lambda-trust-policy.json
+}
@@ -2,3 +2,37 @@ resource "aws_iam_role" "queue-lambda" {
   name               = "queue-lambda"
   assume_role_policy = data.aws_iam_policy_document.lambda-trust-policy.json
 }
+
+data "aws_iam_policy_document" "queue-dynamodb-description" {
+  statement {
+    actions = ["dynamodb:GetItem"]
+    resources = [
+      aws_dynamodb_table.jobs-table.arn,
+      aws_dynamodb_table.users-table.arn,
+    ]
+  }
+  statement {
+    actions = ["dynamodb:Query"]
+    resources = [
+      "${aws_dynamodb_table.video_requests_table.arn}/index/requestedBy-index",
+    ]
+  }
+  statement {
+    actions = ["dynamodb:UpdateItem"]
+    resources = [
+      aws_dynamodb_table.jobs-table.arn,
+      aws_dynamodb_table.video_requests_table.arn,
+    ]
+  }
+}
+
+resource "aws_iam_policy" "queue-dynamodb" {
+  name        = "queue-dynamodb"
+  description = "Allows the queue lambda to record a video's priority and add it to its job's pipeline"
+  policy      = data.aws_iam_policy_document.queue-dynamodb-description.json
+}
+
+resource "aws_iam_role_policy_attachment" "lambda-queue-dynamodb-access" {
+  role       = aws_iam_role.queue-lambda.name
+  policy_arn = aws_iam_policy.queue-dynamodb.arn
+}
@@ -9,6 +9,13 @@ data "aws_iam_policy_document" "submit-dynamodb-description" {
     resources = [
       aws_dynamodb_table.jobs-table.arn,
       aws_dynamodb_table.video_requests_table.arn,
+      aws_dynamodb_table.rate_limits_table.arn,
+    ]
+  }
+  statement {
+    actions = ["dynamodb:Query"]
+    resources = [
+      aws_dynamodb_table.rate_limits_table.arn,
     ]
   }
   statement {
@@ -22,6 +29,13 @@ data "aws_iam_policy_document" "submit-dynamodb-description" {
     actions = ["dynamodb:DeleteItem"]
     resources = [
       aws_dynamodb_table.jobs-table.arn,
+      aws_dynamodb_table.rate_limits_table.arn,
+    ]
+  }
+  statement {
+    actions = ["dynamodb:GetItem"]
+    resources = [
+      aws_dynamodb_table.user_backgrounds_table.arn,
     ]
   }
 }
@@ -43,10 +57,32 @@ data "aws_iam_policy_document" "submit-s3-description" {
     resources = [
       "${aws_s3_bucket.s3_bucket.arn}/assets/*/Summary.txt",
       "${aws_s3_bucket.s3_bucket.arn}/assets/*/Notes.md",
+      "${aws_s3_bucket.s3_bucket.arn}/assets/*/Dialogue.json",
     ]
   }
 }
 
+data "aws_iam_policy_document" "submit-catalog-description" {
+  statement {
+    actions = ["s3:GetObject"]
+    resources = [
+      "${aws_s3_bucket.s3_bucket.arn}/background/catalog.json",
+      "${aws_s3_bucket.s3_bucket.arn}/music/*",
+    ]
+  }
+}
+
+resource "aws_iam_policy" "submit-catalog" {
+  name        = "submit-catalog"
+  description = "Allows the submit job lambda to read the background catalog and check the music tracks"
+  policy      = data.aws_iam_policy_document.submit-catalog-description.json
+}
+
+resource "aws_iam_role_policy_attachment" "lambda-submit-catalog" {
+  role       = aws_iam_role.submit-job-role.name
+  policy_arn = aws_iam_policy.submit-catalog.arn
+}
+
 resource "aws_iam_policy" "submit-s3" {
   name        = "submit-s3"
   description = "Allows the submit job lambda to write summaries and notes to the S3 bucket"
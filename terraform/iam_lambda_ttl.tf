@@ -29,8 +29,22 @@ data "aws_iam_policy_document" "ttl-s3-description" {
     actions = ["s3:DeleteObject"]
     resources = [
       "${aws_s3_bucket.s3_bucket.arn}/assets/*/*.mp4",
+      "${aws_s3_bucket.s3_bucket.arn}/assets/*/*.jpg",
+      "${aws_s3_bucket.s3_bucket.arn}/assets/*/*.webp",
+      "${aws_s3_bucket.s3_bucket.arn}/assets/*/hls/*",
+      "${aws_s3_bucket.s3_bucket.arn}/assets/*/parts/*",
     ]
   }
+  # HLS streams are deleted by listing their prefix
+  statement {
+    actions   = ["s3:ListBucket"]
+    resources = [aws_s3_bucket.s3_bucket.arn]
+    condition {
+      test     = "StringLike"
+      variable = "s3:prefix"
+      values   = ["assets/*"]
+    }
+  }
 }
 
 resource "aws_iam_policy" "ttl-s3" {
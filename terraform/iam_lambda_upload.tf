@@ -0,0 +1,31 @@
+resource "aws_iam_role" "upload_lambda_role" {
+  name               = "upload-lambda-role"
+  assume_role_policy = data.aws_iam_policy_document.lambda-trust-policy.json
+}
+
+data "aws_iam_policy_document" "upload_lambda_description" {
+  statement {
+    actions = ["dynamodb:PutItem", "dynamodb:Query"]
+    resources = [
+      aws_dynamodb_table.user_backgrounds_table.arn,
+    ]
+  }
+  # The presigned upload URL is signed with this role so it needs to be able to write the upload itself
+  statement {
+    actions = ["s3:PutObject"]
+    resources = [
+      "${aws_s3_bucket.s3_bucket.arn}/uploads/*",
+    ]
+  }
+}
+
+resource "aws_iam_policy" "upload_lambda" {
+  name        = "upload-lambda"
+  description = "Allows the upload lambda to register user backgrounds and presign their uploads"
+  policy      = data.aws_iam_policy_document.upload_lambda_description.json
+}
+
+resource "aws_iam_role_policy_attachment" "upload_policy_attachment" {
+  role       = aws_iam_role.upload_lambda_role.name
+  policy_arn = aws_iam_policy.upload_lambda.arn
+}
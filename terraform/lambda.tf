@@ -8,6 +8,8 @@
 # -> Job Lambda
 # -> Subtitle Lambda
 # -> Queue Lambda
+# -> Upload Lambda
+# -> Ingest Lambda
 
 locals {
   zip_path = "${path.module}/../backend/bin"
@@ -55,22 +57,6 @@ resource "aws_lambda_function" "job-lambda" {
   }
 }
 
-resource "aws_lambda_function" "subtitle-lambda" {
-  function_name    = "zircon-subtitle-lambda"
-  role             = aws_iam_role.tts-role.arn
-  runtime          = "provided.al2023"
-  handler          = "bootstrap"
-  filename         = "${local.zip_path}/Subtitles.zip"
-  source_code_hash = filebase64sha256("${local.zip_path}/Subtitles.zip")
-  memory_size      = 128
-  timeout          = 30
-  environment {
-    variables = {
-      LEMONFOX_API_KEY = var.LEMONFOX_API_KEY
-    }
-  }
-}
-
 resource "aws_lambda_function" "queue-lambda" {
   function_name    = "zircon-queue-lambda"
   role             = aws_iam_role.queue-lambda.arn
@@ -119,6 +105,25 @@ resource "aws_lambda_function" "health_lambda" {
   }
 }
 
+resource "aws_lambda_function" "cancel_lambda" {
+  function_name    = "zircon-cancel-lambda"
+  role             = aws_iam_role.cancel_lambda_role.arn
+  runtime          = "provided.al2023"
+  handler          = "bootstrap"
+  filename         = "${local.zip_path}/Cancel.zip"
+  source_code_hash = filebase64sha256("${local.zip_path}/Cancel.zip")
+  memory_size      = 128
+  vpc_config {
+    security_group_ids = [aws_security_group.lambda-elasticache-sg.id]
+    subnet_ids         = aws_subnet.public-subnets[*].id
+  }
+  environment {
+    variables = {
+      REDIS_URL = "${aws_elasticache_replication_group.task-queue.primary_endpoint_address}:6379"
+    }
+  }
+}
+
 resource "aws_lambda_function" "ttl_video" {
   function_name    = "zircon-ttl-video-lambda"
   role             = aws_iam_role.ttl-role.arn
@@ -128,3 +133,42 @@ resource "aws_lambda_function" "ttl_video" {
   source_code_hash = filebase64sha256("${local.zip_path}/TTLVideo.zip")
   memory_size      = 128
 }
+
+resource "aws_lambda_function" "backgrounds_lambda" {
+  function_name    = "zircon-backgrounds-lambda"
+  role             = aws_iam_role.backgrounds_lambda_role.arn
+  runtime          = "provided.al2023"
+  handler          = "bootstrap"
+  filename         = "${local.zip_path}/Backgrounds.zip"
+  source_code_hash = filebase64sha256("${local.zip_path}/Backgrounds.zip")
+  memory_size      = 128
+}
+
+resource "aws_lambda_function" "upload_lambda" {
+  function_name    = "zircon-upload-lambda"
+  role             = aws_iam_role.upload_lambda_role.arn
+  runtime          = "provided.al2023"
+  handler          = "bootstrap"
+  filename         = "${local.zip_path}/Upload.zip"
+  source_code_hash = filebase64sha256("${local.zip_path}/Upload.zip")
+  memory_size      = 128
+}
+
+resource "aws_lambda_function" "ingest_lambda" {
+  function_name    = "zircon-ingest-lambda"
+  role             = aws_iam_role.ingest_lambda_role.arn
+  runtime          = "provided.al2023"
+  handler          = "bootstrap"
+  filename         = "${local.zip_path}/Ingest.zip"
+  source_code_hash = filebase64sha256("${local.zip_path}/Ingest.zip")
+  memory_size      = 128
+  vpc_config {
+    security_group_ids = [aws_security_group.lambda-elasticache-sg.id]
+    subnet_ids         = aws_subnet.public-subnets[*].id
+  }
+  environment {
+    variables = {
+      REDIS_URL = "${aws_elasticache_replication_group.task-queue.primary_endpoint_address}:6379"
+    }
+  }
+}
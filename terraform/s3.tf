@@ -21,6 +21,24 @@ resource "aws_s3_object" "static_logo" {
   source_hash = filemd5("${path.module}/../backend/static/logo.png")
 }
 
+# Music beds from subtitleclient.MUSIC_CATALOG, a track that isn't uploaded fails the narration that chose it
+resource "aws_s3_object" "music" {
+  for_each     = fileset("${path.module}/../backend/static/music", "*.mp3")
+  bucket       = aws_s3_bucket.s3_bucket.bucket
+  key          = "music/${each.value}"
+  source       = "${path.module}/../backend/static/music/${each.value}"
+  source_hash  = filemd5("${path.module}/../backend/static/music/${each.value}")
+  content_type = "audio/mpeg"
+}
+
+resource "aws_s3_object" "background_catalog" {
+  bucket       = aws_s3_bucket.s3_bucket.bucket
+  key          = "background/catalog.json"
+  source       = "${path.module}/../backend/pkg/videoutil/backgrounds.json"
+  source_hash  = filemd5("${path.module}/../backend/pkg/videoutil/backgrounds.json")
+  content_type = "application/json"
+}
+
 resource "aws_s3_object" "static_minecraft" {
   bucket      = aws_s3_bucket.s3_bucket.bucket
   key         = "background/minecraft.mp4"
@@ -62,3 +80,66 @@ resource "aws_s3_object" "static_soap" {
   source      = "${path.module}/../backend/static/soap.mp4"
   source_hash = filemd5("${path.module}/../backend/static/soap.mp4")
 }
+
+# Users upload their own backgrounds straight from the extension with a presigned URL
+resource "aws_s3_bucket_cors_configuration" "user_uploads" {
+  bucket = aws_s3_bucket.s3_bucket.bucket
+  cors_rule {
+    allowed_methods = ["PUT"]
+    allowed_origins = ["*"]
+    allowed_headers = ["Content-Type"]
+    max_age_seconds = 3000
+  }
+}
+
+# Uploads are deleted once ingested, this removes any that were abandoned or never processed
+resource "aws_s3_bucket_lifecycle_configuration" "user_uploads" {
+  bucket = aws_s3_bucket.s3_bucket.bucket
+  rule {
+    id     = "expire-user-uploads"
+    status = "Enabled"
+    filter {
+      prefix = "uploads/"
+    }
+    expiration {
+      days = 1
+    }
+  }
+  rule {
+    id     = "expire-reconcile-reports"
+    status = "Enabled"
+    filter {
+      prefix = "reports/reconcile/"
+    }
+    expiration {
+      days = 30
+    }
+  }
+  # Multipart uploads that were never completed or aborted, e.g. when a consumer was killed mid-upload
+  rule {
+    id     = "abort-incomplete-multipart-uploads"
+    status = "Enabled"
+    filter {}
+    abort_incomplete_multipart_upload {
+      days_after_initiation = 1
+    }
+  }
+}
+
+resource "aws_s3_bucket_notification" "user_uploads" {
+  bucket = aws_s3_bucket.s3_bucket.id
+  lambda_function {
+    lambda_function_arn = aws_lambda_function.ingest_lambda.arn
+    events              = ["s3:ObjectCreated:*"]
+    filter_prefix       = "uploads/"
+  }
+  depends_on = [aws_lambda_permission.ingest_s3_perm]
+}
+
+resource "aws_lambda_permission" "ingest_s3_perm" {
+  statement_id  = "AllowS3Invoke"
+  action        = "lambda:InvokeFunction"
+  function_name = aws_lambda_function.ingest_lambda.function_name
+  principal     = "s3.amazonaws.com"
+  source_arn    = aws_s3_bucket.s3_bucket.arn
+}
@@ -1,4 +1,4 @@
-# This file creates the SES email sending service for completed videos
+# This file creates the SES email sending service for completed and failed videos
 
 resource "aws_ses_configuration_set" "ses_configuration_videos" {
   name = "zircon_video_configuration_set"
@@ -19,5 +19,12 @@ resource "aws_ses_template" "zircon_job_complete_template" {
   name    = "zircon_job_complete_template"
   subject = "[Zircon] Your {{VideoTitle}} Video for {{Subject}}!"
   html    = file("${path.module}/../backend/pkg/sesClient/jobTemplate.html")
-  text    = "Your requested video is ready! You can view it by opening this link in your browser:\nhttps://www.zircon.socialcoding.net/assets/{{EntryID}}/{{VideoType}}.mp4"
+  text    = "Your requested video is ready! You can view it by opening these links in your browser:{{#each Videos}}\n{{Label}}: {{URL}}{{/each}}"
+}
+
+resource "aws_ses_template" "zircon_job_failed_template" {
+  name    = "zircon_job_failed_template"
+  subject = "[Zircon] We couldn't finish your request for {{Subject}}"
+  html    = file("${path.module}/../backend/pkg/sesClient/failedTemplate.html")
+  text    = "Sorry! Zircon wasn't able to finish your request for \"{{Subject}}\".\nWhat went wrong: {{Category}}\nYou can request it again from the lecture's page with the Zircon extension:\n{{RetryURL}}"
 }
@@ -0,0 +1,10 @@
+# This file creates the queue that keeps DynamoDB stream records a lambda failed to process
+
+resource "aws_sqs_queue" "stream-failures" {
+  name                      = "zircon-stream-failures"
+  message_retention_seconds = 1209600
+  tags = {
+    Name        = "zircon-stream-failures"
+    Environment = "prod"
+  }
+}